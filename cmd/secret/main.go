@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/rohitsakala/strategies/pkg/secret"
+)
+
+// secret manages the encrypted secrets file of SECRETS_FILE
+// with SECRETS_MASTER_KEY. Values are read from stdin so they
+// don't end up in the shell history.
+//
+//	echo -n $VALUE | go run ./cmd/secret set KITE_PASSWORD
+//	go run ./cmd/secret delete KITE_PASSWORD
+//	go run ./cmd/secret list
+func main() {
+	args := os.Args
+	if len(args) < 2 {
+		log.Fatal("Need one of set, delete or list as argument")
+	}
+
+	store, err := secret.GetStore("file")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fileStore := store.(*secret.FileStore)
+
+	switch args[1] {
+	case "set":
+		if len(args) < 3 {
+			log.Fatal("Need the name of the secret")
+		}
+		value, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && len(value) < 1 {
+			log.Fatal("Need the value of the secret on stdin")
+		}
+		err = fileStore.SetSecret(args[2], strings.TrimRight(value, "\r\n"))
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "delete":
+		if len(args) < 3 {
+			log.Fatal("Need the name of the secret")
+		}
+		err = fileStore.DeleteSecret(args[2])
+		if err != nil {
+			log.Fatal(err)
+		}
+	case "list":
+		names, err := fileStore.Names()
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+	default:
+		log.Fatalf("Unknown command %s", args[1])
+	}
+}
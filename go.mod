@@ -7,6 +7,7 @@ require (
 	github.com/tebeka/selenium v0.9.9
 	github.com/zerodha/gokiteconnect/v4 v4.0.0
 	go.mongodb.org/mongo-driver v1.7.1
+	golang.org/x/crypto v0.0.0-20200302210943-78000ba7a073
 	gopkg.in/alexcesaro/quotedprintable.v3 v3.0.0-20150716171945-2caba252f4dc // indirect
 	gopkg.in/mail.v2 v2.3.1
 )
@@ -4,12 +4,23 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/rohitsakala/strategies/pkg/account"
 	"github.com/rohitsakala/strategies/pkg/authenticator"
 	"github.com/rohitsakala/strategies/pkg/broker"
 	"github.com/rohitsakala/strategies/pkg/database"
+	"github.com/rohitsakala/strategies/pkg/secret"
+	"github.com/rohitsakala/strategies/pkg/sizing"
 	"github.com/rohitsakala/strategies/pkg/strategy"
+	_ "github.com/rohitsakala/strategies/pkg/strategy/callcreditspread"
+	_ "github.com/rohitsakala/strategies/pkg/strategy/ironcondor"
+	_ "github.com/rohitsakala/strategies/pkg/strategy/orb"
+	_ "github.com/rohitsakala/strategies/pkg/strategy/portfolio"
+	_ "github.com/rohitsakala/strategies/pkg/strategy/shortstrangle"
+	_ "github.com/rohitsakala/strategies/pkg/strategy/twelvethirty"
 	"github.com/rohitsakala/strategies/pkg/utils"
 	"github.com/rohitsakala/strategies/pkg/watcher"
 )
@@ -19,36 +30,44 @@ func main() {
 	log.Printf("Getting arguments...")
 	args := os.Args
 	if len(args) < 2 {
-		log.Printf("Need strategy name as argument")
+		log.Printf("Need strategy name as argument, one of %s", strings.Join(strategy.Names(), ", "))
 		os.Exit(1)
 	}
 	log.Printf("Got %s argument.", args[1])
-
-	log.Printf("Connecting to mongo database....")
-	mongoDatabase := database.MongoDatabase{}
-	err := mongoDatabase.Connect()
+	_, err := strategy.GetDefinition(args[1])
 	if err != nil {
-		utils.SendEmail("Twelve Thirty run paniced. Immediate Attention needed", err.Error())
-		fmt.Println(err)
-		panic(err)
+		log.Print(err)
+		os.Exit(1)
 	}
-	log.Printf("Connected to mongo database.")
 
-	log.Printf("Autheticating to kite broker....")
-	googleAuthenticator := authenticator.GetAuthenticator("google")
-	zerodhaBroker, err := broker.GetBroker("zerodha", &mongoDatabase, googleAuthenticator)
+	databaseName := os.Getenv("DATABASE")
+	if databaseName == "" {
+		databaseName = "mongo"
+	}
+	log.Printf("Connecting to %s database....", databaseName)
+	db := database.GetDatabase(databaseName)
+	if db == nil {
+		log.Printf("Unknown database %s", databaseName)
+		os.Exit(1)
+	}
+	err = db.Connect()
 	if err != nil {
 		utils.SendEmail("Twelve Thirty run paniced. Immediate Attention needed", err.Error())
 		fmt.Println(err)
 		panic(err)
 	}
-	err = zerodhaBroker.Authenticate()
+	log.Printf("Connected to %s database.", databaseName)
+
+	secretsName := os.Getenv("SECRETS")
+	if secretsName == "" {
+		secretsName = "env"
+	}
+	secrets, err := secret.GetStore(secretsName)
 	if err != nil {
 		utils.SendEmail("Twelve Thirty run paniced. Immediate Attention needed", err.Error())
 		fmt.Println(err)
 		panic(err)
 	}
-	log.Printf("Authenticated to kite broker.")
 
 	log.Printf("Setting to Indian Standard TimeZone...")
 	IndianTimeZone, err := time.LoadLocation("Asia/Kolkata")
@@ -59,31 +78,92 @@ func main() {
 	}
 	log.Printf("Set to Indian Standard TimeZone.")
 
-	watcher, err := watcher.NewWatcher(zerodhaBroker, *IndianTimeZone)
+	accounts, err := account.GetAccounts()
 	if err != nil {
 		utils.SendEmail("Twelve Thirty run paniced. Immediate Attention needed", err.Error())
 		fmt.Println(err)
 		panic(err)
 	}
 
-	log.Printf("Executing %s pm strategy with args...%s", args[1], args[2])
-	strategy, err := strategy.GetStrategy(args[1], zerodhaBroker, *IndianTimeZone, &mongoDatabase, watcher, args[2], args[3])
+	log.Printf("Executing %s pm strategy with args...%s on %d accounts", args[1], strings.Join(args[2:], " "), len(accounts))
+	errs := make([]error, len(accounts))
+	var wg sync.WaitGroup
+	for i := range accounts {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = runAccount(accounts[i], db, secrets, *IndianTimeZone, args)
+			if errs[i] != nil {
+				log.Printf("Account %s failed: %v", accounts[i], errs[i])
+				utils.SendEmailTo(accounts[i].Email, accounts[i].Subject("Twelve Thirty run paniced. Immediate Attention needed"), errs[i].Error())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	failed := false
+	report := []string{}
+	for i, account := range accounts {
+		if errs[i] != nil {
+			failed = true
+			report = append(report, fmt.Sprintf("%s: failed because %s", account, errs[i]))
+			continue
+		}
+		report = append(report, fmt.Sprintf("%s: succeeded", account))
+	}
+	if len(accounts) > 1 {
+		utils.SendEmail(fmt.Sprintf("%s run report", args[1]), strings.Join(report, "\n"))
+	}
+	if failed {
+		log.Printf("Executed %s pm strategy with failures.\n%s", args[1], strings.Join(report, "\n"))
+		os.Exit(1)
+	}
+	log.Printf("Executed %s pm strategy.", args[1])
+}
+
+// runAccount runs the strategy on the account with its own
+// broker session, so the accounts can run in parallel
+func runAccount(account account.Account, db database.Database, secrets secret.Store, timeZone time.Location, args []string) error {
+	log.Printf("Autheticating account %s to %s broker....", account, account.Broker)
+	accountAuthenticator, err := authenticator.GetAuthenticator(account.Authenticator, account, secrets)
 	if err != nil {
-		utils.SendEmail("Twelve Thirty run paniced. Immediate Attention needed", err.Error())
-		fmt.Println(err)
-		panic(err)
+		return err
 	}
-	err = strategy.Start()
+	accountBroker, err := broker.GetBroker(account, db, accountAuthenticator, secrets)
 	if err != nil {
-		utils.SendEmail("Twelve Thirty run paniced. Immediate Attention needed", err.Error())
-		fmt.Println(err)
-		panic(err)
+		return err
 	}
-	err = strategy.Stop()
+	err = accountBroker.Authenticate()
 	if err != nil {
-		utils.SendEmail("Twelve Thirty run paniced. Immediate Attention needed", err.Error())
-		fmt.Println(err)
-		panic(err)
+		return err
 	}
-	log.Printf("Executed %s pm strategy.", args[1])
+	log.Printf("Authenticated account %s to %s broker.", account, account.Broker)
+
+	watcher, err := watcher.NewWatcher(accountBroker, timeZone)
+	if err != nil {
+		return err
+	}
+
+	sizer, err := sizing.NewSizer(account, accountBroker)
+	if err != nil {
+		return err
+	}
+
+	strategy, err := strategy.GetStrategy(args[1], strategy.Dependencies{
+		Account:  account,
+		Broker:   accountBroker,
+		TimeZone: timeZone,
+		Database: db,
+		Watcher:  watcher,
+		Sizer:    sizer,
+	}, args[2:])
+	if err != nil {
+		return err
+	}
+	err = strategy.Start()
+	if err != nil {
+		return err
+	}
+
+	return strategy.Stop()
 }
@@ -0,0 +1,147 @@
+package account
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	BrokerZerodha = "zerodha"
+	BrokerFyer    = "fyer"
+)
+
+var (
+	namePattern = regexp.MustCompile(`^[a-zA-Z0-9]+$`)
+	// brokers are the ones GetBroker knows
+	brokers = []string{BrokerZerodha, BrokerFyer}
+)
+
+// Account is a profile of a trading account. The settings of
+// a named account come from the environment variables and
+// secrets suffixed with its upper cased name, e.g.
+// KITE_USERID_ALICE, while the default account has no name
+// and no suffix.
+type Account struct {
+	Name   string
+	Broker string
+	// Authenticator gives the TOTP codes of the broker login
+	Authenticator string
+	UserID        string
+	APIKey        string
+	// LotMultiplier scales the lots the strategies trade
+	LotMultiplier int
+	// Email gets the reports of the account
+	Email string
+}
+
+// GetAccounts gives the accounts listed in ACCOUNTS
+// or the default account if there are none
+func GetAccounts() ([]Account, error) {
+	names := []string{""}
+	if value := os.Getenv("ACCOUNTS"); len(value) > 0 {
+		names = []string{}
+		for _, name := range strings.Split(value, ",") {
+			names = append(names, strings.TrimSpace(name))
+		}
+	}
+
+	accounts := []Account{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if len(os.Getenv("ACCOUNTS")) > 0 && !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid account name %q in ACCOUNTS", name)
+		}
+		if seen[strings.ToUpper(name)] {
+			return nil, fmt.Errorf("account %s is listed twice in ACCOUNTS", name)
+		}
+		seen[strings.ToUpper(name)] = true
+
+		account, err := NewAccount(name)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, account)
+	}
+
+	return accounts, nil
+}
+
+// NewAccount reads the settings of the account
+// from the environment variables
+func NewAccount(name string) (Account, error) {
+	var err error
+	account := Account{
+		Name:          name,
+		Broker:        BrokerZerodha,
+		Authenticator: "google",
+		LotMultiplier: 1,
+	}
+
+	if value := account.Getenv("BROKER"); len(value) > 0 {
+		account.Broker = value
+	}
+	if !isKnownBroker(account.Broker) {
+		return Account{}, fmt.Errorf("unknown broker %s in %s, known ones are %s", account.Broker, account.Key("BROKER"), strings.Join(brokers, ", "))
+	}
+	if value := account.Getenv("AUTHENTICATOR"); len(value) > 0 {
+		account.Authenticator = value
+	}
+	account.UserID = account.Getenv("KITE_USERID")
+	account.APIKey = account.Getenv("KITE_APIKEY")
+	if value := account.Getenv("LOT_MULTIPLIER"); len(value) > 0 {
+		account.LotMultiplier, err = strconv.Atoi(value)
+		if err != nil || account.LotMultiplier < 1 {
+			return Account{}, fmt.Errorf("invalid %s %s", account.Key("LOT_MULTIPLIER"), value)
+		}
+	}
+	account.Email = account.Getenv("EMAIL_ADDRESS")
+	if len(account.Email) < 1 {
+		account.Email = os.Getenv("EMAIL_ADDRESS")
+	}
+
+	return account, nil
+}
+
+func isKnownBroker(name string) bool {
+	for _, broker := range brokers {
+		if broker == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Key gives the name of the environment variable or
+// secret of the account, e.g. KITE_PASSWORD_ALICE
+func (a Account) Key(name string) string {
+	if len(a.Name) < 1 {
+		return name
+	}
+
+	return name + "_" + strings.ToUpper(a.Name)
+}
+
+func (a Account) Getenv(name string) string {
+	return os.Getenv(a.Key(name))
+}
+
+// Subject tells the account in the subject of the emails
+func (a Account) Subject(subject string) string {
+	if len(a.Name) < 1 {
+		return subject
+	}
+
+	return fmt.Sprintf("[%s] %s", a.Name, subject)
+}
+
+func (a Account) String() string {
+	if len(a.Name) < 1 {
+		return "default"
+	}
+
+	return a.Name
+}
@@ -1,17 +1,92 @@
 package authenticator
 
 import (
-	"os"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/secret"
+)
+
+// Factory creates the authenticator of the account
+type Factory func(account account.Account, secrets secret.Store) (Authenticator, error)
+
+var (
+	factories      = map[string]Factory{}
+	factoriesMutex = &sync.RWMutex{}
 )
 
-func GetAuthenticator(name string) Authenticator {
-	switch name {
-	case "google":
-		googleAuthenticator := NewGoogleAuthenticator(
-			os.Getenv("GOOGLE_AUTHENTICATOR_SECRET_KEY"),
-		)
-		return &googleAuthenticator
+func init() {
+	Register("google", newGoogleAuthenticator)
+	Register("static", newStaticAuthenticator)
+	Register("manual", newManualAuthenticator)
+}
+
+// Register makes the authenticator available by name
+// to GetAuthenticator, replacing any with the same name
+func Register(name string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+
+	factories[name] = factory
+}
+
+// Names gives the names of the registered authenticators
+func Names() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+
+	names := []string{}
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func GetAuthenticator(name string, account account.Account, secrets secret.Store) (Authenticator, error) {
+	factoriesMutex.RLock()
+	factory, ok := factories[name]
+	factoriesMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown authenticator %s, known ones are %s", name, strings.Join(Names(), ", "))
 	}
 
-	return nil
+	return factory(account, secrets)
+}
+
+func newGoogleAuthenticator(account account.Account, secrets secret.Store) (Authenticator, error) {
+	secretKey, err := secrets.GetSecret(account.Key("GOOGLE_AUTHENTICATOR_SECRET_KEY"))
+	if err != nil {
+		return nil, err
+	}
+	config, err := NewTOTPConfig(account.Getenv)
+	if err != nil {
+		return nil, err
+	}
+	googleAuthenticator, err := NewGoogleAuthenticator(secretKey, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &googleAuthenticator, nil
+}
+
+func newStaticAuthenticator(account account.Account, secrets secret.Store) (Authenticator, error) {
+	code, err := secrets.GetSecret(account.Key("STATIC_TOTP_CODE"))
+	if err != nil {
+		return nil, err
+	}
+	staticAuthenticator := NewStaticAuthenticator(code)
+
+	return &staticAuthenticator, nil
+}
+
+func newManualAuthenticator(account account.Account, secrets secret.Store) (Authenticator, error) {
+	manualAuthenticator := NewManualAuthenticator(account.String())
+
+	return &manualAuthenticator, nil
 }
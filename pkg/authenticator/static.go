@@ -0,0 +1,68 @@
+package authenticator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// StaticAuthenticator gives the same code every time. It is
+// meant for tests and stand in login servers.
+type StaticAuthenticator struct {
+	Code string
+}
+
+func NewStaticAuthenticator(code string) StaticAuthenticator {
+	return StaticAuthenticator{
+		Code: code,
+	}
+}
+
+func (s *StaticAuthenticator) GetTOTP() (string, error) {
+	if len(s.Code) < 1 {
+		return "", errors.New("static TOTP code is empty")
+	}
+
+	return s.Code, nil
+}
+
+// stdinMutex keeps the prompts of accounts running
+// in parallel from interleaving
+var stdinMutex = &sync.Mutex{}
+
+// ManualAuthenticator asks for the code from the authenticator
+// app on the terminal every time one is needed
+type ManualAuthenticator struct {
+	Name   string
+	Input  io.Reader
+	Output io.Writer
+}
+
+func NewManualAuthenticator(name string) ManualAuthenticator {
+	return ManualAuthenticator{
+		Name:   name,
+		Input:  os.Stdin,
+		Output: os.Stdout,
+	}
+}
+
+func (m *ManualAuthenticator) GetTOTP() (string, error) {
+	stdinMutex.Lock()
+	defer stdinMutex.Unlock()
+
+	fmt.Fprintf(m.Output, "Enter TOTP code for %s: ", m.Name)
+	line, err := bufio.NewReader(m.Input).ReadString('\n')
+	if err != nil && !(err == io.EOF && len(line) > 0) {
+		return "", err
+	}
+	code := strings.TrimSpace(line)
+	if len(code) < 1 {
+		return "", errors.New("no TOTP code was entered")
+	}
+
+	return code, nil
+}
@@ -0,0 +1,209 @@
+package authenticator
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SHA1   = "SHA1"
+	SHA256 = "SHA256"
+	SHA512 = "SHA512"
+
+	DefaultPeriod = 30 * time.Second
+	DefaultDigits = 6
+)
+
+// TOTPConfig are the RFC 6238 options of the codes. Skew is
+// the number of periods before and after the current one
+// whose codes are accepted as well when validating.
+type TOTPConfig struct {
+	Period    time.Duration
+	Digits    int
+	Algorithm string
+	Skew      int
+}
+
+func DefaultTOTPConfig() TOTPConfig {
+	return TOTPConfig{
+		Period:    DefaultPeriod,
+		Digits:    DefaultDigits,
+		Algorithm: SHA1,
+		Skew:      1,
+	}
+}
+
+// NewTOTPConfig returns the default config overridden by the
+// TOTP_* environment variables of the given getenv
+func NewTOTPConfig(getenv func(string) string) (TOTPConfig, error) {
+	var err error
+	config := DefaultTOTPConfig()
+	if getenv == nil {
+		getenv = os.Getenv
+	}
+
+	if value := getenv("TOTP_PERIOD"); len(value) > 0 {
+		config.Period, err = time.ParseDuration(value)
+		if err != nil {
+			// plain seconds like authenticator apps take them
+			seconds, atoiErr := strconv.Atoi(value)
+			if atoiErr != nil {
+				return TOTPConfig{}, fmt.Errorf("invalid TOTP_PERIOD %s", value)
+			}
+			config.Period = time.Duration(seconds) * time.Second
+		}
+	}
+	if value := getenv("TOTP_DIGITS"); len(value) > 0 {
+		config.Digits, err = strconv.Atoi(value)
+		if err != nil {
+			return TOTPConfig{}, fmt.Errorf("invalid TOTP_DIGITS %s", value)
+		}
+	}
+	if value := getenv("TOTP_ALGORITHM"); len(value) > 0 {
+		config.Algorithm = strings.ToUpper(strings.Replace(value, "-", "", -1))
+	}
+	if value := getenv("TOTP_SKEW"); len(value) > 0 {
+		config.Skew, err = strconv.Atoi(value)
+		if err != nil {
+			return TOTPConfig{}, fmt.Errorf("invalid TOTP_SKEW %s", value)
+		}
+	}
+
+	return config, config.validate()
+}
+
+func (c TOTPConfig) validate() error {
+	if c.Period < time.Second {
+		return fmt.Errorf("TOTP period %s is less than a second", c.Period)
+	}
+	if c.Digits < 6 || c.Digits > 10 {
+		return fmt.Errorf("TOTP digits %d is not between 6 and 10", c.Digits)
+	}
+	if _, err := c.hash(); err != nil {
+		return err
+	}
+	if c.Skew < 0 {
+		return fmt.Errorf("TOTP skew %d is negative", c.Skew)
+	}
+
+	return nil
+}
+
+func (c TOTPConfig) hash() (func() hash.Hash, error) {
+	switch c.Algorithm {
+	case SHA1, "":
+		return sha1.New, nil
+	case SHA256:
+		return sha256.New, nil
+	case SHA512:
+		return sha512.New, nil
+	}
+
+	return nil, fmt.Errorf("unknown TOTP algorithm %s", c.Algorithm)
+}
+
+// TOTP generates and validates the codes of a secret key
+type TOTP struct {
+	Key    []byte
+	Config TOTPConfig
+}
+
+func NewTOTP(secretKey string, config TOTPConfig) (TOTP, error) {
+	err := config.validate()
+	if err != nil {
+		return TOTP{}, err
+	}
+	key, err := DecodeSecretKey(secretKey)
+	if err != nil {
+		return TOTP{}, err
+	}
+
+	return TOTP{
+		Key:    key,
+		Config: config,
+	}, nil
+}
+
+// Generate gives the code of the period the time is in
+func (t TOTP) Generate(at time.Time) (string, error) {
+	return t.hotp(t.counter(at))
+}
+
+// Validate tells if the code is the one of the period the time
+// is in or of one of the periods within the skew around it
+func (t TOTP) Validate(code string, at time.Time) (bool, error) {
+	counter := t.counter(at)
+	for offset := -t.Config.Skew; offset <= t.Config.Skew; offset++ {
+		if int64(counter)+int64(offset) < 0 {
+			continue
+		}
+		expected, err := t.hotp(uint64(int64(counter) + int64(offset)))
+		if err != nil {
+			return false, err
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (t TOTP) counter(at time.Time) uint64 {
+	return uint64(at.Unix() / int64(t.Config.Period/time.Second))
+}
+
+// hotp is the RFC 4226 code of the counter
+func (t TOTP) hotp(counter uint64) (string, error) {
+	newHash, err := t.Config.hash()
+	if err != nil {
+		return "", err
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+
+	mac := hmac.New(newHash, t.Key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+
+	// the last nibble chooses the 4 bytes to use and the
+	// most significant bit is dropped as per RFC 4226
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint64(1)
+	for i := 0; i < t.Config.Digits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", t.Config.Digits, uint64(value)%modulo), nil
+}
+
+// DecodeSecretKey decodes the base32 secret key the way authenticator
+// apps take it, i.e. in any case, with or without padding and
+// with spaces or dashes between the groups
+func DecodeSecretKey(secretKey string) ([]byte, error) {
+	cleaned := strings.ToUpper(secretKey)
+	cleaned = strings.NewReplacer(" ", "", "-", "", "\t", "", "\n", "").Replace(cleaned)
+	cleaned = strings.TrimRight(cleaned, "=")
+	if len(cleaned) < 1 {
+		return nil, fmt.Errorf("TOTP secret key is empty")
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("TOTP secret key is not valid base32: %v", err)
+	}
+
+	return key, nil
+}
@@ -0,0 +1,161 @@
+package authenticator
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// the seeds of RFC 6238 Appendix B, one per algorithm
+var rfc6238Seeds = map[string]string{
+	SHA1:   "12345678901234567890",
+	SHA256: "12345678901234567890123456789012",
+	SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+}
+
+func newTestTOTP(t *testing.T, algorithm string, digits, skew int) TOTP {
+	secretKey := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte(rfc6238Seeds[algorithm]))
+	totp, err := NewTOTP(secretKey, TOTPConfig{
+		Period:    DefaultPeriod,
+		Digits:    digits,
+		Algorithm: algorithm,
+		Skew:      skew,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return totp
+}
+
+func TestTOTPGenerateRFC6238(t *testing.T) {
+	tests := []struct {
+		unix      int64
+		algorithm string
+		code      string
+	}{
+		{59, SHA1, "94287082"},
+		{59, SHA256, "46119246"},
+		{59, SHA512, "90693936"},
+		{1111111109, SHA1, "07081804"},
+		{1111111109, SHA256, "68084774"},
+		{1111111109, SHA512, "25091201"},
+		{1111111111, SHA1, "14050471"},
+		{1111111111, SHA256, "67062674"},
+		{1111111111, SHA512, "99943326"},
+		{1234567890, SHA1, "89005924"},
+		{1234567890, SHA256, "91819424"},
+		{1234567890, SHA512, "93441116"},
+		{2000000000, SHA1, "69279037"},
+		{2000000000, SHA256, "90698825"},
+		{2000000000, SHA512, "38618901"},
+		{20000000000, SHA1, "65353130"},
+		{20000000000, SHA256, "77737706"},
+		{20000000000, SHA512, "47863826"},
+	}
+	for _, test := range tests {
+		totp := newTestTOTP(t, test.algorithm, 8, 0)
+		code, err := totp.Generate(time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code != test.code {
+			t.Errorf("got %s at %d with %s, want %s", code, test.unix, test.algorithm, test.code)
+		}
+	}
+}
+
+func TestTOTPGenerateSixDigits(t *testing.T) {
+	totp := newTestTOTP(t, SHA1, DefaultDigits, 0)
+
+	code, err := totp.Generate(time.Unix(59, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code != "287082" {
+		t.Errorf("got %s, want 287082", code)
+	}
+}
+
+func TestDecodeSecretKey(t *testing.T) {
+	// the SHA1 seed of RFC 6238 is 32 base32 characters
+	// long and needs no padding, an 11 byte key needs some
+	tests := []struct {
+		name      string
+		secretKey string
+		key       string
+	}{
+		{"unpadded", "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "12345678901234567890"},
+		{"lower case", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "12345678901234567890"},
+		{"grouped", "gezd gnbv gy3t qojq-gezd gnbv gy3t qojq", "12345678901234567890"},
+		{"padding left out", "GEZDGNBVGY3TQOJQGE", "12345678901"},
+		{"padded", "GEZDGNBVGY3TQOJQGE======", "12345678901"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := DecodeSecretKey(test.secretKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(key) != test.key {
+				t.Errorf("got key %q, want %q", key, test.key)
+			}
+		})
+	}
+
+	for _, secretKey := range []string{"", "====", "GEZDGNBVGY3TQOJ1"} {
+		_, err := DecodeSecretKey(secretKey)
+		if err == nil {
+			t.Errorf("got no error decoding %q", secretKey)
+		}
+	}
+}
+
+func TestTOTPValidateSkew(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	tests := []struct {
+		name    string
+		skew    int
+		periods int
+		valid   bool
+	}{
+		{"current period", 0, 0, true},
+		{"previous period without skew", 0, -1, false},
+		{"next period without skew", 0, 1, false},
+		{"previous period", 1, -1, true},
+		{"next period", 1, 1, true},
+		{"two periods back", 1, -2, false},
+		{"two periods ahead", 1, 2, false},
+		{"two periods back with a wider skew", 2, -2, true},
+		{"two periods ahead with a wider skew", 2, 2, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			totp := newTestTOTP(t, SHA1, 8, test.skew)
+			code, err := totp.Generate(at.Add(time.Duration(test.periods) * DefaultPeriod))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			valid, err := totp.Validate(code, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if valid != test.valid {
+				t.Errorf("got valid %t, want %t", valid, test.valid)
+			}
+		})
+	}
+}
+
+func TestTOTPValidateNearEpoch(t *testing.T) {
+	totp := newTestTOTP(t, SHA1, 8, 1)
+
+	valid, err := totp.Validate("94287082", time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !valid {
+		t.Error("got the code of the next period invalid in the first period")
+	}
+}
@@ -0,0 +1,175 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/avast/retry-go"
+	"github.com/rohitsakala/strategies/pkg/models"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+// orderPlacer is what placing a basket with its
+// rollback needs of a broker
+type orderPlacer interface {
+	placeOrderContext(ctx context.Context, order *models.Order) error
+	// cancelOpenOrder cancels the order if it is not final and
+	// records its fills. It fails till the order is final.
+	cancelOpenOrder(order *models.Order) error
+}
+
+// PlaceBasketOrder places all the legs of a basket as one unit.
+// Buy legs (hedges) are placed first for margin benefit and then
+// the remaining legs, each group concurrently. If any leg fails or
+// the basket does not complete within the timeout, the legs which
+// were already filled are squared off.
+func (z *ZerodhaBroker) PlaceBasketOrder(orders models.RefOrders, timeout time.Duration) error {
+	return placeBasket(z, orders, timeout)
+}
+
+func placeBasket(placer orderPlacer, orders models.RefOrders, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	hedges, others := splitHedges(orders)
+	placed := models.RefOrders{}
+	for _, legs := range []models.RefOrders{hedges, others} {
+		if len(legs) <= 0 {
+			continue
+		}
+		placed = append(placed, legs...)
+		err := placeOrdersConcurrently(ctx, placer, legs)
+		if err != nil {
+			log.Printf("Basket order failed because %s, rolling back placed legs...", err)
+			rollbackErr := rollbackBasket(placer, placed)
+			if rollbackErr != nil {
+				return fmt.Errorf("basket order failed because %s and rollback failed because %s", err, rollbackErr)
+			}
+			log.Printf("Rolled back placed legs.")
+			return fmt.Errorf("basket order failed because %s", err)
+		}
+	}
+
+	return nil
+}
+
+func placeOrdersConcurrently(ctx context.Context, placer orderPlacer, orders models.RefOrders) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(orders))
+
+	for i, order := range orders {
+		wg.Add(1)
+		go func(i int, order *models.Order) {
+			defer wg.Done()
+			errs[i] = placer.placeOrderContext(ctx, order)
+		}(i, order)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return fmt.Errorf("leg %s failed because %s", orders[i].TradingSymbol, err)
+		}
+	}
+	if ctx.Err() != nil {
+		for _, order := range orders {
+			if order.Status != models.StatusComplete {
+				return fmt.Errorf("leg %s did not complete because %s", order.TradingSymbol, ctx.Err())
+			}
+		}
+	}
+
+	return nil
+}
+
+// rollbackBasket cancels the legs which are still open and squares
+// off the quantity of the legs which got filled in the reverse of the
+// entry order, shorts first and hedges last. It stops at the first
+// leg which can not be squared off so the hedges stay on.
+func rollbackBasket(placer orderPlacer, orders models.RefOrders) error {
+	orders = FlattenSlices(orders)
+	for _, order := range orders {
+		if len(order.OrderID) <= 0 || order.Status == models.StatusComplete {
+			continue
+		}
+		err := retry.Do(
+			func() error {
+				return placer.cancelOpenOrder(order)
+			},
+			retry.OnRetry(func(_ uint, err error) {
+				log.Println(fmt.Sprintf("%s %v because %s", "Retrying cancelling basket leg", order, err))
+			}),
+			retry.Delay(5*time.Second),
+			retry.Attempts(5),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	hedges, others := splitHedges(orders)
+	for _, order := range append(others, hedges...) {
+		if order.FilledQuantity <= 0 {
+			continue
+		}
+		exitOrder := order.Unplaced()
+		exitOrder.Quantity = order.FilledQuantity
+		exitOrder.TransactionType = order.TransactionType.Opposite()
+		exitOrder.OrderType = models.OrderTypeLimit
+		if len(order.Tag) > 0 {
+			// a leg placed again under its tag is squared off
+			// again, while a leg whose order got adopted finds
+			// the square off of that order instead
+			exitOrder.Tag = NewOrderTag(order.Tag, "rollback", order.OrderID)
+		}
+		err := placer.placeOrderContext(context.Background(), &exitOrder)
+		if err != nil {
+			return fmt.Errorf("could not square off basket leg %s because %s", order.TradingSymbol, err)
+		}
+		log.Printf("Squared off basket leg %s with Avg Price %f", exitOrder.TradingSymbol, exitOrder.AveragePrice)
+	}
+
+	return nil
+}
+
+// cancelOpenOrder cancels the order if it is not yet
+// filled and records the latest status of the order
+func (z *ZerodhaBroker) cancelOpenOrder(order *models.Order) error {
+	latest, found, err := z.getOrder(order.OrderID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return nil
+	}
+
+	updateFill(order, latest)
+	if latest.Status.IsFinal() {
+		order.Status = latest.Status
+		return nil
+	}
+	_, err = z.Client.CancelOrder(kiteconnect.VarietyRegular, order.OrderID, nil)
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("cancel requested for order %s with status %s", order.OrderID, latest.Status)
+}
+
+// splitHedges separates the buy legs of the basket from the rest
+func splitHedges(orders models.RefOrders) (models.RefOrders, models.RefOrders) {
+	hedges := models.RefOrders{}
+	others := models.RefOrders{}
+	for _, order := range orders {
+		if order.TransactionType == models.TransactionTypeBuy {
+			hedges = append(hedges, order)
+		} else {
+			others = append(others, order)
+		}
+	}
+
+	return hedges, others
+}
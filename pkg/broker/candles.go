@@ -0,0 +1,149 @@
+package broker
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/database"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const candleDayFormat = "2006-01-02"
+
+// marketTimeZone splits the candles into the trading days
+var marketTimeZone = time.FixedZone("IST", 5*60*60+30*60)
+
+// candleFetcher downloads the candles between the times from the broker
+type candleFetcher func(from, to time.Time) (models.Candles, error)
+
+// CandleCache keeps the candles of the days which are over in the
+// database so they are downloaded only once. The candles of today
+// are always downloaded as they are still forming. Without a repo
+// nothing is cached.
+type CandleCache struct {
+	Repo *database.CandleRepo
+}
+
+func NewCandleCache(repo *database.CandleRepo) CandleCache {
+	return CandleCache{
+		Repo: repo,
+	}
+}
+
+// GetHistoricalCandles gives the candles between the times from the
+// cache and downloads the days missing in it in as few calls as can be
+func (c CandleCache) GetHistoricalCandles(symbol string, interval models.Interval, from, to time.Time, fetch candleFetcher) (models.Candles, error) {
+	if interval.Duration() <= 0 {
+		return nil, fmt.Errorf("unknown candle interval %s", interval)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("candles from %s are after %s", from, to)
+	}
+	if c.Repo == nil {
+		return fetch(from, to)
+	}
+
+	today := time.Now().In(marketTimeZone).Format(candleDayFormat)
+	candles := models.Candles{}
+	missing := []time.Time{}
+	download := func() error {
+		if len(missing) <= 0 {
+			return nil
+		}
+		downloaded, err := c.download(symbol, interval, missing, today, fetch)
+		if err != nil {
+			return err
+		}
+		candles = append(candles, downloaded...)
+		missing = []time.Time{}
+		return nil
+	}
+
+	for day := startOfDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format(candleDayFormat)
+		if key >= today {
+			missing = append(missing, day)
+			continue
+		}
+		cached, found, err := c.Repo.Get(symbol, interval, key)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			missing = append(missing, day)
+			continue
+		}
+		err = download()
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, cached...)
+	}
+	err := download()
+	if err != nil {
+		return nil, err
+	}
+
+	result := models.Candles{}
+	for _, candle := range candles {
+		if !candle.Time.Before(from) && !candle.Time.After(to) {
+			result = append(result, candle)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Time.Before(result[j].Time)
+	})
+
+	return result, nil
+}
+
+// download fetches the consecutive days whole and caches
+// every one of them which is over
+func (c CandleCache) download(symbol string, interval models.Interval, days []time.Time, today string, fetch candleFetcher) (models.Candles, error) {
+	first := days[0]
+	last := days[len(days)-1].AddDate(0, 0, 1).Add(-time.Second)
+	candles, err := fetch(first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	byDay := map[string]models.Candles{}
+	for _, candle := range candles {
+		key := candle.Time.In(marketTimeZone).Format(candleDayFormat)
+		byDay[key] = append(byDay[key], candle)
+	}
+	for _, day := range days {
+		key := day.Format(candleDayFormat)
+		if key >= today {
+			continue
+		}
+		err = c.Repo.Save(symbol, interval, key, byDay[key])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return candles, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.In(marketTimeZone)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, marketTimeZone)
+}
+
+// splitRange splits the range into ranges of at most the
+// days for the brokers limiting the range of a call
+func splitRange(from, to time.Time, days int) [][2]time.Time {
+	ranges := [][2]time.Time{}
+	for start := from; !start.After(to); {
+		end := start.AddDate(0, 0, days).Add(-time.Second)
+		if end.After(to) {
+			end = to
+		}
+		ranges = append(ranges, [2]time.Time{start, end})
+		start = end.Add(time.Second)
+	}
+
+	return ranges
+}
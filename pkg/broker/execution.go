@@ -0,0 +1,169 @@
+package broker
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const (
+	ExecutionStartMid = "mid"
+	ExecutionStartBid = "bid"
+	ExecutionStartAsk = "ask"
+	ExecutionStartLTP = "ltp"
+
+	defaultTickSize = 0.05
+)
+
+// ExecutionConfig decides how limit orders chase the market
+// until they are filled
+type ExecutionConfig struct {
+	// StartPrice is one of mid, bid, ask or ltp
+	StartPrice            string
+	StepTicks             int
+	StepInterval          time.Duration
+	MaxSteps              int
+	MaxSlippagePercentage float64
+}
+
+func DefaultExecutionConfig() ExecutionConfig {
+	return ExecutionConfig{
+		StartPrice:            ExecutionStartMid,
+		StepTicks:             2,
+		StepInterval:          5 * time.Second,
+		MaxSteps:              10,
+		MaxSlippagePercentage: 3,
+	}
+}
+
+// NewExecutionConfig returns the default execution config
+// overridden by the EXECUTION_* environment variables
+func NewExecutionConfig() (ExecutionConfig, error) {
+	var err error
+	config := DefaultExecutionConfig()
+
+	if value := os.Getenv("EXECUTION_START_PRICE"); len(value) > 0 {
+		switch value {
+		case ExecutionStartMid, ExecutionStartBid, ExecutionStartAsk, ExecutionStartLTP:
+			config.StartPrice = value
+		default:
+			return ExecutionConfig{}, fmt.Errorf("invalid EXECUTION_START_PRICE %s", value)
+		}
+	}
+	if value := os.Getenv("EXECUTION_STEP_TICKS"); len(value) > 0 {
+		config.StepTicks, err = strconv.Atoi(value)
+		if err != nil {
+			return ExecutionConfig{}, err
+		}
+	}
+	if value := os.Getenv("EXECUTION_STEP_INTERVAL"); len(value) > 0 {
+		config.StepInterval, err = time.ParseDuration(value)
+		if err != nil {
+			return ExecutionConfig{}, err
+		}
+	}
+	if value := os.Getenv("EXECUTION_MAX_STEPS"); len(value) > 0 {
+		config.MaxSteps, err = strconv.Atoi(value)
+		if err != nil {
+			return ExecutionConfig{}, err
+		}
+	}
+	if value := os.Getenv("EXECUTION_MAX_SLIPPAGE_PERCENTAGE"); len(value) > 0 {
+		config.MaxSlippagePercentage, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return ExecutionConfig{}, err
+		}
+	}
+
+	return config, nil
+}
+
+// InitialPrice gives the price at which the limit order
+// is first placed from the market depth
+func (e ExecutionConfig) InitialPrice(ltp, bid, ask, tickSize float64, transactionType models.TransactionType) float64 {
+	price := ltp
+	switch e.StartPrice {
+	case ExecutionStartMid:
+		if bid > 0 && ask > 0 {
+			price = (bid + ask) / 2
+		}
+	case ExecutionStartBid:
+		if bid > 0 {
+			price = bid
+		}
+	case ExecutionStartAsk:
+		if ask > 0 {
+			price = ask
+		}
+	}
+
+	if transactionType == models.TransactionTypeBuy {
+		price = RoundUpToTick(price, tickSize)
+	} else {
+		price = RoundDownToTick(price, tickSize)
+	}
+
+	return math.Max(price, tick(tickSize))
+}
+
+// LimitPrice gives the worst price the order is allowed
+// to chase to according to the slippage cap
+func (e ExecutionConfig) LimitPrice(ltp, tickSize float64, transactionType models.TransactionType) float64 {
+	slippage := ltp * e.MaxSlippagePercentage / 100
+	if transactionType == models.TransactionTypeBuy {
+		return RoundDownToTick(ltp+slippage, tickSize)
+	}
+
+	return math.Max(RoundUpToTick(ltp-slippage, tickSize), tick(tickSize))
+}
+
+// NextPrice steps the price towards the market by the configured
+// number of ticks without crossing the limit price
+func (e ExecutionConfig) NextPrice(price, limitPrice, tickSize float64, transactionType models.TransactionType) float64 {
+	step := float64(e.StepTicks) * tick(tickSize)
+	if transactionType == models.TransactionTypeBuy {
+		return math.Min(roundToTick(price+step, tickSize), limitPrice)
+	}
+
+	return math.Max(roundToTick(price-step, tickSize), limitPrice)
+}
+
+// Slippage gives how much worse the average price is than the
+// reference price, negative when the fill was better
+func Slippage(referencePrice, averagePrice float64, transactionType models.TransactionType) float64 {
+	if transactionType == models.TransactionTypeBuy {
+		return averagePrice - referencePrice
+	}
+
+	return referencePrice - averagePrice
+}
+
+func RoundUpToTick(price, tickSize float64) float64 {
+	return roundToPrecision(math.Ceil(roundToPrecision(price/tick(tickSize))) * tick(tickSize))
+}
+
+func RoundDownToTick(price, tickSize float64) float64 {
+	return roundToPrecision(math.Floor(roundToPrecision(price/tick(tickSize))) * tick(tickSize))
+}
+
+func roundToTick(price, tickSize float64) float64 {
+	return roundToPrecision(math.Round(price/tick(tickSize)) * tick(tickSize))
+}
+
+// roundToPrecision removes floating point noise from
+// tick multiplication and division
+func roundToPrecision(value float64) float64 {
+	return math.Round(value*1e6) / 1e6
+}
+
+func tick(tickSize float64) float64 {
+	if tickSize <= 0 {
+		return defaultTickSize
+	}
+
+	return tickSize
+}
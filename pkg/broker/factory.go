@@ -1,31 +1,85 @@
 package broker
 
 import (
+	"fmt"
+	"log"
 	"os"
 
+	"github.com/rohitsakala/strategies/pkg/account"
 	"github.com/rohitsakala/strategies/pkg/authenticator"
 	"github.com/rohitsakala/strategies/pkg/database"
+	"github.com/rohitsakala/strategies/pkg/secret"
 )
 
-func GetBroker(name string, database database.Database, authenticator authenticator.Authenticator) (Broker, error) {
-	switch name {
-	case "zerodha":
-		zerodhaBroker, err := NewZerodhaBroker(database, authenticator,
-			os.Getenv("KITE_URL"), os.Getenv("KITE_USERID"), os.Getenv("KITE_PASSWORD"), os.Getenv("KITE_APIKEY"), os.Getenv("KITE_APISECRET"),
+func GetBroker(brokerAccount account.Account, db database.Database, authenticator authenticator.Authenticator, secrets secret.Store) (Broker, error) {
+	credentials, err := getCredentialsRepo(db, brokerAccount)
+	if err != nil {
+		return nil, err
+	}
+	candles, err := database.NewCandleRepo(db)
+	if err != nil {
+		return nil, err
+	}
+
+	switch brokerAccount.Broker {
+	case account.BrokerZerodha:
+		execution, err := NewExecutionConfig()
+		if err != nil {
+			return nil, err
+		}
+		login, err := NewLoginConfig()
+		if err != nil {
+			return nil, err
+		}
+		password, err := secrets.GetSecret(brokerAccount.Key("KITE_PASSWORD"))
+		if err != nil {
+			return nil, err
+		}
+		apiSecret, err := secrets.GetSecret(brokerAccount.Key("KITE_APISECRET"))
+		if err != nil {
+			return nil, err
+		}
+		zerodhaBroker, err := NewZerodhaBroker(credentials, authenticator, execution, login,
+			os.Getenv("KITE_URL"), brokerAccount.UserID, password, brokerAccount.APIKey, apiSecret,
 		)
 		if err != nil {
 			return nil, err
 		}
+		zerodhaBroker.Candles = NewCandleCache(&candles)
 		return &zerodhaBroker, nil
-	case "fyer":
-		fyerBroker, err := NewFyerBroker(database,
-			os.Getenv("FYER_URL"), os.Getenv("FYER_USERID"), os.Getenv("FYER_PASSWORD"), os.Getenv("FYER_APIKEY"), os.Getenv("FYER_APISECRET"), os.Getenv("FYER_APPID"),
+	case account.BrokerFyer:
+		password, err := secrets.GetSecret(brokerAccount.Key("FYER_PASSWORD"))
+		if err != nil {
+			return nil, err
+		}
+		apiSecret, err := secrets.GetSecret(brokerAccount.Key("FYER_APISECRET"))
+		if err != nil {
+			return nil, err
+		}
+		fyerBroker, err := NewFyerBroker(credentials,
+			os.Getenv("FYER_URL"), brokerAccount.Getenv("FYER_USERID"), password, brokerAccount.Getenv("FYER_APIKEY"), apiSecret, brokerAccount.Getenv("FYER_APPID"),
 		)
 		if err != nil {
 			return nil, err
 		}
+		fyerBroker.Candles = NewCandleCache(&candles)
 		return &fyerBroker, nil
 	}
 
-	return nil, nil
+	return nil, fmt.Errorf("unknown broker %s", brokerAccount.Broker)
+}
+
+// getCredentialsRepo encrypts the access token of the
+// account with the master key when there is one
+func getCredentialsRepo(db database.Database, account account.Account) (database.CredentialsRepo, error) {
+	cipher, ok, err := secret.GetMasterKeyCipher()
+	if err != nil {
+		return database.CredentialsRepo{}, err
+	}
+	if !ok {
+		log.Println("SECRETS_MASTER_KEY is not set, the access token is stored in plaintext")
+		return database.NewCredentialsRepo(db, nil, account.Name)
+	}
+
+	return database.NewCredentialsRepo(db, &cipher, account.Name)
 }
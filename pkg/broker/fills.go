@@ -0,0 +1,79 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/avast/retry-go"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// updateOrderStatus records the status and fills of the latest state
+// of the order and reports whether the order is completely filled. An
+// order the exchange cancelled after a partial fill is split so the
+// rest can be placed. A partial fill cancelled by anyone else, like by
+// hand in kite, is only reported so the cancel is not overridden.
+func updateOrderStatus(order *models.Order, latest models.Order) (bool, error) {
+	updateFill(order, latest)
+	order.Status = latest.Status
+
+	switch latest.Status {
+	case models.StatusComplete:
+		return true, nil
+	case models.StatusRejected:
+		order.OrderID = ""
+		return false, fmt.Errorf("order is rejected with message %s", latest.StatusMessage)
+	case models.StatusCancelled:
+		if order.FilledQuantity > 0 && order.FilledQuantity < order.Quantity {
+			if isExchangeCancel(latest) {
+				splitPartialFill(order)
+			}
+			return false, retry.Unrecoverable(fmt.Errorf("order is cancelled after filling %d of %d quantity", order.FilledQuantity, order.Quantity))
+		}
+		return false, retry.Unrecoverable(errors.New("order is cancelled"))
+	}
+
+	return false, nil
+}
+
+// isExchangeCancel tells whether the exchange cancelled the rest of
+// the order on its own as the validity of the order ran out. The
+// orders the broker cancels itself record their fills where they
+// are cancelled, see cancelOpenOrder.
+func isExchangeCancel(latest models.Order) bool {
+	return latest.Validity == models.ValidityIOC || latest.Validity == models.ValidityTTL
+}
+
+// updateFill records the filled and pending quantity
+// and the average price of the latest state of the order
+func updateFill(order *models.Order, latest models.Order) {
+	order.FilledQuantity = latest.FilledQuantity
+	order.PendingQuantity = latest.PendingQuantity
+	if latest.FilledQuantity > 0 {
+		order.AveragePrice = latest.AveragePrice
+	}
+}
+
+// splitPartialFill turns the order into a filled child for the
+// cancelled order and a new child for the remaining quantity
+func splitPartialFill(order *models.Order) {
+	filled := *order
+	filled.Slices = nil
+	filled.Quantity = order.FilledQuantity
+	filled.PendingQuantity = 0
+	filled.Status = models.StatusComplete
+
+	remaining := *order
+	remaining.Slices = nil
+	remaining.Quantity = order.Quantity - order.FilledQuantity
+	remaining.OrderID = ""
+	remaining.Status = ""
+	remaining.AveragePrice = 0
+	remaining.FilledQuantity = 0
+	remaining.PendingQuantity = 0
+	if len(order.Tag) > 0 {
+		remaining.Tag = NewOrderTag(order.Tag, "rest")
+	}
+
+	order.Slices = models.Orders{filled, remaining}
+}
@@ -0,0 +1,81 @@
+package broker
+
+import (
+	"testing"
+
+	"github.com/avast/retry-go"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+func TestUpdateOrderStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		// latest is the state of the order on the broker
+		latest       models.Order
+		wantComplete bool
+		wantErr      bool
+		// wantRest is the quantity placed again, zero when
+		// the order is not split
+		wantRest int
+	}{
+		{"open", models.Order{Status: models.StatusOpen, Validity: models.ValidityDay}, false, false, 0},
+		{"partly filled", models.Order{Status: models.StatusOpen, Validity: models.ValidityDay, FilledQuantity: 50, PendingQuantity: 50, AveragePrice: 100}, false, false, 0},
+		{"complete", models.Order{Status: models.StatusComplete, Validity: models.ValidityDay, FilledQuantity: 100, AveragePrice: 100}, true, false, 0},
+		{"rejected", models.Order{Status: models.StatusRejected, Validity: models.ValidityDay}, false, true, 0},
+		{"cancelled", models.Order{Status: models.StatusCancelled, Validity: models.ValidityDay}, false, true, 0},
+		{"cancelled by hand after a partial fill", models.Order{Status: models.StatusCancelled, Validity: models.ValidityDay, FilledQuantity: 50, AveragePrice: 100}, false, true, 0},
+		{"IOC cancelled after a partial fill", models.Order{Status: models.StatusCancelled, Validity: models.ValidityIOC, FilledQuantity: 30, AveragePrice: 100}, false, true, 70},
+		{"TTL cancelled after a partial fill", models.Order{Status: models.StatusCancelled, Validity: models.ValidityTTL, FilledQuantity: 50, AveragePrice: 100}, false, true, 50},
+		{"IOC cancelled unfilled", models.Order{Status: models.StatusCancelled, Validity: models.ValidityIOC}, false, true, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			order := models.Order{
+				Instrument: models.Instrument{TradingSymbol: "NIFTY24JAN21500CE", LotSize: 50},
+				OrderID:    "1",
+				Quantity:   100,
+				Tag:        "TWELVE0123456789abcd",
+			}
+			complete, err := updateOrderStatus(&order, test.latest)
+			if complete != test.wantComplete || (err != nil) != test.wantErr {
+				t.Fatalf("got complete %t and error %v, want complete %t and error %t", complete, err, test.wantComplete, test.wantErr)
+			}
+			if order.Status != test.latest.Status || order.FilledQuantity != test.latest.FilledQuantity || order.AveragePrice != test.latest.AveragePrice {
+				t.Errorf("got %s filled %d at %f, want the latest state", order.Status, order.FilledQuantity, order.AveragePrice)
+			}
+			if test.latest.Status == models.StatusCancelled && retry.IsRecoverable(err) {
+				t.Errorf("got recoverable error %v, want a cancel not retried", err)
+			}
+			if test.latest.Status == models.StatusRejected && len(order.OrderID) > 0 {
+				t.Errorf("got order id %s of the rejected order, want it cleared to place it again", order.OrderID)
+			}
+
+			if test.wantRest <= 0 {
+				if len(order.Slices) > 0 {
+					t.Errorf("got the order split into %d, want it as it is", len(order.Slices))
+				}
+				return
+			}
+			if len(order.Slices) != 2 {
+				t.Fatalf("got the order split into %d, want the fill and the rest", len(order.Slices))
+			}
+			filled, rest := order.Slices[0], order.Slices[1]
+			if filled.OrderID != "1" || filled.Quantity != test.latest.FilledQuantity || filled.Status != models.StatusComplete || filled.AveragePrice != test.latest.AveragePrice {
+				t.Errorf("got fill %s of %d %s at %f, want order 1 complete with its fill", filled.OrderID, filled.Quantity, filled.Status, filled.AveragePrice)
+			}
+			if len(rest.OrderID) > 0 || rest.Quantity != test.wantRest || len(rest.Status) > 0 || rest.FilledQuantity != 0 || rest.AveragePrice != 0 {
+				t.Errorf("got rest %+v, want a new order of %d", rest, test.wantRest)
+			}
+			if rest.Tag == order.Tag || OrderTagPrefix(rest.Tag) != OrderTagPrefix(order.Tag) {
+				t.Errorf("got rest tag %s, want a tag of its own with the prefix of %s", rest.Tag, order.Tag)
+			}
+
+			// the split order aggregates like a sliced one
+			// whose rest is not placed yet
+			aggregateSlices(&order)
+			if order.OrderID != "1" || order.FilledQuantity != test.latest.FilledQuantity || len(order.Status) > 0 {
+				t.Errorf("got %s %q filled %d after aggregating, want order 1 filled %d and not placed in full", order.OrderID, order.Status, order.FilledQuantity, test.latest.FilledQuantity)
+			}
+		})
+	}
+}
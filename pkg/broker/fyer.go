@@ -1,45 +1,76 @@
 package broker
 
 import (
+	"fmt"
+	"log"
+	"time"
+
 	"github.com/rohitsakala/strategies/pkg/database"
 	"github.com/rohitsakala/strategies/pkg/httpClient"
 	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/symbol"
 )
 
 type FyerBroker struct {
-	url    string
-	userId string
-	appId  string
-	client httpClient.Client
+	url         string
+	userId      string
+	appId       string
+	client      httpClient.Client
+	symbols     symbol.Mapper
+	credentials database.CredentialsRepo
+	Candles     CandleCache
 }
 
-func NewFyerBroker(database database.Database, url, userID, password, apiKey, apiSecret, appId string) (FyerBroker, error) {
-	err := database.CreateCollection("credentials")
-	if err != nil {
-		return FyerBroker{}, err
-	}
+func NewFyerBroker(credentials database.CredentialsRepo, url, userID, password, apiKey, apiSecret, appId string) (FyerBroker, error) {
+	fyerMapper := symbol.NewFyerMapper()
 
 	return FyerBroker{
-		url:    url,
-		userId: userID,
-		appId:  appId,
-		client: nil,
+		url:         url,
+		userId:      userID,
+		appId:       appId,
+		symbols:     &fyerMapper,
+		credentials: credentials,
 	}, nil
 }
 
+func (f *FyerBroker) GetSymbolMapper() symbol.Mapper {
+	return f.symbols
+}
+
 func (f *FyerBroker) IsMarketOpen() (bool, error) {
 	return false, nil
 }
 
-func (f *FyerBroker) GetOrderID(position models.Position) (string, error) {
+func (f *FyerBroker) GetOrderID(order models.Order) (string, error) {
 	return "", nil
 }
 
+// Authenticate uses the access token saved in the credentials as
+// there is no login to fyers, without one the calls to fyers fail
 func (f *FyerBroker) Authenticate() error {
+	credentials, err := f.credentials.Get()
+	if err != nil {
+		return err
+	}
+	if len(credentials.AccessToken) < 1 {
+		log.Println("No fyers access token saved, the calls to fyers will fail")
+		return nil
+	}
+	f.client = httpClient.NewFyerHttpClient(f.url, f.appId, credentials.AccessToken)
+
 	return nil
 }
 
-func (f *FyerBroker) CancelOrder(position *models.Position) error {
+// authenticated tells why the calls to fyers can't be made
+func (f *FyerBroker) authenticated() error {
+	if f.client == nil {
+		return fmt.Errorf("no fyers access token, the login to fyers is not supported")
+	}
+
+	return nil
+}
+
+func (f *FyerBroker) CancelOrder(order *models.Order) error {
 	return nil
 }
 
@@ -52,30 +83,93 @@ func (f *FyerBroker) CheckPosition(symbol string) (bool, error) {
 	return false, nil
 }
 
-func (f *FyerBroker) GetInstruments(exchange string) (models.Positions, error) {
-	return models.Positions{}, nil
+func (f *FyerBroker) GetInstruments(exchange models.Exchange) (models.Instruments, error) {
+	return models.Instruments{}, nil
+}
+
+func (f *FyerBroker) GetInstrument(symbol string, exchange models.Exchange) (models.Instrument, error) {
+	return models.Instrument{}, nil
+}
+
+// fyerResolutions are the resolutions of the history
+// endpoint for the candle intervals
+var fyerResolutions = map[models.Interval]string{
+	models.IntervalMinute:        "1",
+	models.IntervalThreeMinute:   "3",
+	models.IntervalFiveMinute:    "5",
+	models.IntervalTenMinute:     "10",
+	models.IntervalFifteenMinute: "15",
+	models.IntervalThirtyMinute:  "30",
+	models.IntervalHour:          "60",
+	models.IntervalDay:           "D",
 }
 
-func (f *FyerBroker) GetInstrument(symbol string, exchange string) (models.Position, error) {
-	return models.Position{}, nil
+// fyerHistoricalDays is the most days fyers gives in a call,
+// intraday resolutions are limited to 100 days and days to 366
+func fyerHistoricalDays(interval models.Interval) int {
+	if interval == models.IntervalDay {
+		return 366
+	}
+	return 100
+}
+
+func (f *FyerBroker) GetHistoricalCandles(symbol string, interval models.Interval, from, to time.Time) (models.Candles, error) {
+	resolution, ok := fyerResolutions[interval]
+	if !ok {
+		return nil, fmt.Errorf("candle interval %s is not supported on fyers", interval)
+	}
+	err := f.authenticated()
+	if err != nil {
+		return nil, err
+	}
+
+	return f.Candles.GetHistoricalCandles(symbol, interval, from, to, func(from, to time.Time) (models.Candles, error) {
+		candles := models.Candles{}
+		for _, r := range splitRange(from, to, fyerHistoricalDays(interval)) {
+			history, err := f.client.GetHistory(symbol, resolution, r[0], r[1])
+			if err != nil {
+				return nil, err
+			}
+			for _, cmd := range history {
+				candles = append(candles, models.CandleFromFyer(cmd))
+			}
+		}
+		return candles, nil
+	})
 }
 
 // Orders
-func (f *FyerBroker) GetOrders() (models.Positions, error) {
-	return models.Positions{}, nil
+func (f *FyerBroker) GetOrders() (models.Orders, error) {
+	return models.Orders{}, nil
 }
-func (f *FyerBroker) PlaceOrder(position *models.Position) error {
+
+func (f *FyerBroker) GetTrades() (models.Trades, error) {
+	return models.Trades{}, nil
+}
+func (f *FyerBroker) PlaceOrder(order *models.Order) error {
+	return nil
+}
+func (f *FyerBroker) PlaceBasketOrder(orders models.RefOrders, timeout time.Duration) error {
 	return nil
 }
-func (f *FyerBroker) CancelOrders(positions models.RefPositions) error {
+func (f *FyerBroker) CancelOrders(orders models.RefOrders) error {
 	return nil
 }
 
 // Margin
-func (f *FyerBroker) GetMargin() {
+func (f *FyerBroker) GetMargin() (float64, error) {
+	return 0, fmt.Errorf("margins are not supported on fyers")
+}
+
+func (f *FyerBroker) GetBasketMargin(orders models.Orders) (float64, error) {
+	return 0, fmt.Errorf("margins are not supported on fyers")
 }
 
 func (f *FyerBroker) GetLTP(symbol string) (float64, error) {
+	err := f.authenticated()
+	if err != nil {
+		return 0, err
+	}
 	quote, err := f.client.GetQuote(symbol)
 	if err != nil {
 		return 0, err
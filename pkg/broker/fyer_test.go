@@ -0,0 +1,75 @@
+package broker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/database"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+func newTestFyerBroker(t *testing.T, url, accessToken string) FyerBroker {
+	memoryDatabase := database.NewMemoryDatabase()
+	credentials, err := database.NewCredentialsRepo(&memoryDatabase, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accessToken) > 0 {
+		err = credentials.Save(models.Credentials{AccessToken: accessToken})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fyerBroker, err := NewFyerBroker(credentials, url, "XY1234", "", "", "", "APP-100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fyerBroker.Authenticate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fyerBroker
+}
+
+func TestFyerHistoricalCandlesAreAuthorized(t *testing.T) {
+	from := time.Date(2024, 1, 2, 9, 15, 0, 0, marketTimeZone)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorization := r.Header.Get("Authorization"); authorization != "APP-100:token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"s": "ok", "candles": [[%d, 100, 110, 95, 105, 1000]]}`, from.Unix())
+	}))
+	defer server.Close()
+
+	fyerBroker := newTestFyerBroker(t, server.URL, "token")
+	candles, err := fyerBroker.GetHistoricalCandles("NSE:NIFTY50-INDEX", models.IntervalFiveMinute, from, from.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candles) != 1 || !candles[0].Time.Equal(from) || candles[0].Close != 105 || candles[0].Volume != 1000 {
+		t.Errorf("got candles %v, want the candle of %s", candles, from)
+	}
+}
+
+func TestFyerWithoutAccessToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("got a call to %s without an access token", r.URL.Path)
+	}))
+	defer server.Close()
+
+	fyerBroker := newTestFyerBroker(t, server.URL, "")
+	from := time.Date(2024, 1, 2, 9, 15, 0, 0, marketTimeZone)
+	_, err := fyerBroker.GetHistoricalCandles("NSE:NIFTY50-INDEX", models.IntervalFiveMinute, from, from.Add(time.Hour))
+	if err == nil {
+		t.Error("got candles without an access token, want an error")
+	}
+	_, err = fyerBroker.GetLTP("NSE:NIFTY50-INDEX")
+	if err == nil {
+		t.Error("got the LTP without an access token, want an error")
+	}
+}
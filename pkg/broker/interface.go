@@ -1,27 +1,44 @@
 package broker
 
-import "github.com/rohitsakala/strategies/pkg/models"
+import (
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/symbol"
+)
 
 type Broker interface {
 	Authenticate() error
 	IsMarketOpen() (bool, error)
 	GetLTP(symbol string) (float64, error)
 	GetLTPNoFreak(symbol string) (float64, error)
+	// GetSymbolMapper gives the mapper between symbols
+	// and the trading symbols of the broker
+	GetSymbolMapper() symbol.Mapper
 
 	// Positions
 	GetPositions() (models.Positions, error)
 	CheckPosition(symbol string) (bool, error)
 
-	GetInstruments(exchange string) (models.Positions, error)
-	GetInstrument(symbol string, exchange string) (models.Position, error)
+	GetInstruments(exchange models.Exchange) (models.Instruments, error)
+	GetInstrument(symbol string, exchange models.Exchange) (models.Instrument, error)
+	// GetHistoricalCandles gives the candles of the trading
+	// symbol between the times, both included
+	GetHistoricalCandles(symbol string, interval models.Interval, from, to time.Time) (models.Candles, error)
 
 	// Orders
-	GetOrders() (models.Positions, error)
-	GetOrderID(position models.Position) (string, error)
-	PlaceOrder(position *models.Position) error
-	CancelOrder(position *models.Position) error
-	CancelOrders(positions models.RefPositions) error
+	GetOrders() (models.Orders, error)
+	GetTrades() (models.Trades, error)
+	GetOrderID(order models.Order) (string, error)
+	PlaceOrder(order *models.Order) error
+	PlaceBasketOrder(orders models.RefOrders, timeout time.Duration) error
+	CancelOrder(order *models.Order) error
+	CancelOrders(orders models.RefOrders) error
 
 	// Margin
-	GetMargin()
+	// GetMargin gives the margin available for trading
+	GetMargin() (float64, error)
+	// GetBasketMargin gives the margin the orders need
+	// together, with the benefit of the hedges
+	GetBasketMargin(orders models.Orders) (float64, error)
 }
@@ -0,0 +1,279 @@
+package broker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/tebeka/selenium"
+	"github.com/tebeka/selenium/chrome"
+)
+
+const (
+	LoginHTTP     = "http"
+	LoginSelenium = "selenium"
+
+	DefaultSeleniumURL = "http://localhost:8080/wd/hub"
+	loginTimeout       = 30 * time.Second
+)
+
+// LoginConfig decides how the kite web login is done to get
+// the request token of the kite connect app
+type LoginConfig struct {
+	// Methods are tried in order till one of them works
+	Methods     []string
+	SeleniumURL string
+	// APIURL overrides the kite connect api url, e.g.
+	// with a local stand in server
+	APIURL string
+}
+
+func DefaultLoginConfig() LoginConfig {
+	return LoginConfig{
+		Methods:     []string{LoginHTTP},
+		SeleniumURL: DefaultSeleniumURL,
+	}
+}
+
+// NewLoginConfig returns the default login config
+// overridden by the KITE_LOGIN* environment variables
+func NewLoginConfig() (LoginConfig, error) {
+	config := DefaultLoginConfig()
+
+	if value := os.Getenv("KITE_LOGIN"); len(value) > 0 {
+		config.Methods = []string{}
+		for _, method := range strings.Split(value, ",") {
+			method = strings.TrimSpace(method)
+			switch method {
+			case LoginHTTP, LoginSelenium:
+				config.Methods = append(config.Methods, method)
+			default:
+				return LoginConfig{}, fmt.Errorf("invalid KITE_LOGIN method %s", method)
+			}
+		}
+	}
+	if value := os.Getenv("KITE_SELENIUM_URL"); len(value) > 0 {
+		config.SeleniumURL = value
+	}
+	config.APIURL = os.Getenv("KITE_API_URL")
+
+	return config, nil
+}
+
+type kiteLoginResponse struct {
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+	ErrorType string `json:"error_type"`
+	Data      struct {
+		UserID    string `json:"user_id"`
+		RequestID string `json:"request_id"`
+		TwoFAType string `json:"twofa_type"`
+	} `json:"data"`
+}
+
+// getRequestToken logs in with the configured methods
+// and gives the request token of the first that works
+func (z *ZerodhaBroker) getRequestToken() (string, error) {
+	messages := []string{}
+	for _, method := range z.Login.Methods {
+		var requestToken string
+		var err error
+		switch method {
+		case LoginHTTP:
+			requestToken, err = z.getRequestTokenHTTP()
+		case LoginSelenium:
+			requestToken, err = z.getRequestTokenSelenium()
+		default:
+			err = fmt.Errorf("unknown login method %s", method)
+		}
+		if err == nil {
+			return requestToken, nil
+		}
+		messages = append(messages, fmt.Sprintf("%s login failed because %s", method, err))
+	}
+	if len(messages) <= 0 {
+		return "", errors.New("no login method is configured")
+	}
+
+	return "", errors.New(strings.Join(messages, "; "))
+}
+
+// getRequestTokenHTTP does the kite web login with the password
+// and the TOTP and then opens the connect login of the app with
+// the session cookies. The redirect to the app carries the
+// request token and is not followed.
+func (z *ZerodhaBroker) getRequestTokenHTTP() (string, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{
+		Jar:     jar,
+		Timeout: loginTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(req.URL.Query().Get("request_token")) > 0 {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return nil
+		},
+	}
+
+	login, err := z.postLoginForm(client, "/api/login", url.Values{
+		"user_id":  {z.UserID},
+		"password": {z.Password},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	totp, err := z.Authenticator.GetTOTP()
+	if err != nil {
+		return "", err
+	}
+	twoFAType := login.Data.TwoFAType
+	if len(twoFAType) < 1 {
+		twoFAType = "totp"
+	}
+	_, err = z.postLoginForm(client, "/api/twofa", url.Values{
+		"user_id":     {z.UserID},
+		"request_id":  {login.Data.RequestID},
+		"twofa_value": {totp},
+		"twofa_type":  {twoFAType},
+		"skip_totp":   {"true"},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Get(z.connectLoginURL())
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	redirectURL, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("connect login didn't redirect with the request token, got status %d", resp.StatusCode)
+	}
+
+	return requestTokenFromURL(redirectURL.String())
+}
+
+func (z *ZerodhaBroker) postLoginForm(client *http.Client, path string, form url.Values) (kiteLoginResponse, error) {
+	resp, err := client.PostForm(z.webURL(path), form)
+	if err != nil {
+		return kiteLoginResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return kiteLoginResponse{}, err
+	}
+	var response kiteLoginResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return kiteLoginResponse{}, fmt.Errorf("unexpected response from %s with status %d", path, resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || response.Status != "success" {
+		return kiteLoginResponse{}, fmt.Errorf("%s failed with status %d and message %s", path, resp.StatusCode, response.Message)
+	}
+
+	return response, nil
+}
+
+// getRequestTokenSelenium drives chrome through the same login
+func (z *ZerodhaBroker) getRequestTokenSelenium() (string, error) {
+	caps := selenium.Capabilities{"browserName": "chrome"}
+	chromeCaps := chrome.Capabilities{
+		Path: "",
+		Args: []string{
+			"--headless",
+			"--no-sandbox",
+		},
+	}
+	caps.AddChrome(chromeCaps)
+	webDriver, err := selenium.NewRemote(caps, z.Login.SeleniumURL)
+	if err != nil {
+		return "", err
+	}
+	defer webDriver.Quit()
+
+	webDriver.Get(z.URL)
+
+	userIDField, err := webDriver.FindElement(selenium.ByID, "userid")
+	if err != nil {
+		return "", err
+	}
+
+	userIDField.SendKeys(z.UserID)
+	passwordElement, err := webDriver.FindElement(selenium.ByID, "password")
+	if err != nil {
+		return "", err
+	}
+
+	passwordElement.SendKeys(z.Password)
+	loginButton, err := webDriver.FindElement(selenium.ByCSSSelector, "button[type=submit]")
+	if err != nil {
+		return "", err
+	}
+	loginButton.Click()
+	time.Sleep(1 * time.Second)
+
+	totp, err := z.Authenticator.GetTOTP()
+	if err != nil {
+		return "", err
+	}
+	totpField, err := webDriver.FindElement(selenium.ByID, "totp")
+	if err != nil {
+		return "", err
+	}
+	totpField.SendKeys(totp)
+	submitButton, err := webDriver.FindElement(selenium.ByCSSSelector, "button[type=submit]")
+	if err != nil {
+		return "", err
+	}
+	submitButton.Click()
+	time.Sleep(1 * time.Second)
+
+	webDriver.Get(z.connectLoginURL())
+	time.Sleep(1 * time.Second)
+
+	authorizedURLString, err := webDriver.CurrentURL()
+	if err != nil {
+		return "", err
+	}
+
+	return requestTokenFromURL(authorizedURLString)
+}
+
+func (z *ZerodhaBroker) webURL(path string) string {
+	return strings.TrimSuffix(z.URL, "/") + path
+}
+
+func (z *ZerodhaBroker) connectLoginURL() string {
+	return z.webURL(fmt.Sprintf("/connect/login?api_key=%s&v=3", url.QueryEscape(z.APIKey)))
+}
+
+func requestTokenFromURL(authorizedURLString string) (string, error) {
+	authorizedURL, err := url.Parse(authorizedURLString)
+	if err != nil {
+		return "", err
+	}
+	requestToken := authorizedURL.Query().Get("request_token")
+	if len(requestToken) < 1 {
+		return "", errors.New("request token is missing")
+	}
+
+	return requestToken, nil
+}
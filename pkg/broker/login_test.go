@@ -0,0 +1,203 @@
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rohitsakala/strategies/pkg/authenticator"
+)
+
+const (
+	testUserID       = "AB1234"
+	testPassword     = "password"
+	testAPIKey       = "apikey"
+	testTOTP         = "123456"
+	testRequestID    = "requestid"
+	testRequestToken = "requesttoken"
+)
+
+// newKiteLoginServer stands in for the kite web login and redirects
+// the connect login of the app to its redirect url
+func newKiteLoginServer(t *testing.T) *httptest.Server {
+	reply := func(w http.ResponseWriter, status int, response kiteLoginResponse) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		err := json.NewEncoder(w).Encode(response)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	fail := func(w http.ResponseWriter, status int, message string) {
+		reply(w, status, kiteLoginResponse{Status: "error", Message: message})
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.FormValue("user_id") != testUserID || r.FormValue("password") != testPassword {
+			fail(w, http.StatusForbidden, "Invalid username or password")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "kf_session", Value: "session", Path: "/"})
+		response := kiteLoginResponse{Status: "success"}
+		response.Data.UserID = testUserID
+		response.Data.RequestID = testRequestID
+		response.Data.TwoFAType = "app_code"
+		reply(w, http.StatusOK, response)
+	})
+	mux.HandleFunc("/api/twofa", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("kf_session"); err != nil {
+			fail(w, http.StatusForbidden, "Session expired")
+			return
+		}
+		if r.FormValue("request_id") != testRequestID || r.FormValue("twofa_type") != "app_code" {
+			fail(w, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		if r.FormValue("twofa_value") != testTOTP {
+			fail(w, http.StatusForbidden, "Invalid TOTP")
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "enctoken", Value: "enctoken", Path: "/"})
+		reply(w, http.StatusOK, kiteLoginResponse{Status: "success"})
+	})
+	mux.HandleFunc("/connect/login", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("enctoken"); err != nil || r.URL.Query().Get("api_key") != testAPIKey {
+			http.Error(w, "not logged in", http.StatusForbidden)
+			return
+		}
+		http.Redirect(w, r, "/connect/finish?sess_id=session", http.StatusFound)
+	})
+	mux.HandleFunc("/connect/finish", func(w http.ResponseWriter, r *http.Request) {
+		// the redirect url of the app isn't served, the login has
+		// to stop at the redirect instead of following it
+		http.Redirect(w, r, "http://127.0.0.1:1/redirect?action=login&status=success&request_token="+testRequestToken, http.StatusFound)
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newSeleniumServer counts the sessions asked for and fails them
+func newSeleniumServer(t *testing.T, sessions *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(sessions, 1)
+		http.Error(w, "no browser", http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func newLoginBroker(url, code string, login LoginConfig) *ZerodhaBroker {
+	staticAuthenticator := authenticator.NewStaticAuthenticator(code)
+
+	return &ZerodhaBroker{
+		URL:           url,
+		UserID:        testUserID,
+		Password:      testPassword,
+		APIKey:        testAPIKey,
+		Authenticator: &staticAuthenticator,
+		Login:         login,
+	}
+}
+
+func TestLoginHTTPCapturesRequestToken(t *testing.T) {
+	server := newKiteLoginServer(t)
+	zerodha := newLoginBroker(server.URL, testTOTP, DefaultLoginConfig())
+
+	requestToken, err := zerodha.getRequestTokenHTTP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requestToken != testRequestToken {
+		t.Errorf("got request token %s, want %s", requestToken, testRequestToken)
+	}
+}
+
+func TestLoginHTTPFails(t *testing.T) {
+	server := newKiteLoginServer(t)
+	tests := []struct {
+		name     string
+		password string
+		code     string
+		want     string
+	}{
+		{"wrong password", "wrong", testTOTP, "Invalid username or password"},
+		{"wrong TOTP", testPassword, "654321", "Invalid TOTP"},
+		{"no TOTP", testPassword, "", "static TOTP code is empty"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			zerodha := newLoginBroker(server.URL, test.code, DefaultLoginConfig())
+			zerodha.Password = test.password
+
+			_, err := zerodha.getRequestTokenHTTP()
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("got error %v, want one with %s", err, test.want)
+			}
+		})
+	}
+}
+
+func TestLoginFallsBackToSelenium(t *testing.T) {
+	server := newKiteLoginServer(t)
+	tests := []struct {
+		name         string
+		methods      []string
+		code         string
+		wantSelenium bool
+		wantToken    bool
+	}{
+		{"http works", []string{LoginHTTP, LoginSelenium}, testTOTP, false, true},
+		{"http fails", []string{LoginHTTP, LoginSelenium}, "654321", true, false},
+		{"selenium first", []string{LoginSelenium, LoginHTTP}, testTOTP, true, true},
+		{"http only", []string{LoginHTTP}, "654321", false, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sessions int32
+			selenium := newSeleniumServer(t, &sessions)
+			zerodha := newLoginBroker(server.URL, test.code, LoginConfig{
+				Methods:     test.methods,
+				SeleniumURL: selenium.URL,
+			})
+
+			requestToken, err := zerodha.getRequestToken()
+			if test.wantToken {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if requestToken != testRequestToken {
+					t.Errorf("got request token %s, want %s", requestToken, testRequestToken)
+				}
+			} else {
+				if err == nil {
+					t.Fatal("got no error, want the failed logins")
+				}
+				for _, method := range test.methods {
+					if !strings.Contains(err.Error(), method+" login failed") {
+						t.Errorf("got error %s, want the %s login failure in it", err, method)
+					}
+				}
+			}
+			if tried := atomic.LoadInt32(&sessions) > 0; tried != test.wantSelenium {
+				t.Errorf("got selenium tried %t, want %t", tried, test.wantSelenium)
+			}
+		})
+	}
+}
+
+func TestLoginWithoutMethods(t *testing.T) {
+	zerodha := newLoginBroker("http://127.0.0.1:1", testTOTP, LoginConfig{})
+
+	_, err := zerodha.getRequestToken()
+	if err == nil || err.Error() != "no login method is configured" {
+		t.Errorf("got error %v, want no login method is configured", err)
+	}
+}
@@ -0,0 +1,542 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/symbol"
+)
+
+// ErrMockOrderTimedOut is the error kite gives when the order got
+// placed but the response was lost
+var ErrMockOrderTimedOut = errors.New("Order request timed out")
+
+// MockStep is a state an order of the mock broker moves to.
+// A COMPLETE step without FilledQuantity fills the whole order
+// and one without AveragePrice fills at the order price.
+type MockStep struct {
+	Status         models.Status
+	FilledQuantity int
+	AveragePrice   float64
+	StatusMessage  string
+}
+
+// MockPlaceError is returned on placing the matching order. The
+// order is still placed when Placed is set, like a timeout.
+type MockPlaceError struct {
+	Err    error
+	Placed bool
+	Times  int
+}
+
+type mockOrder struct {
+	order models.Order
+	steps []MockStep
+}
+
+// MockBroker is a scriptable in-memory broker for tests of the
+// strategies and the watcher. Orders move one scripted step
+// further on every GetOrders call. Scripts and errors match the
+// order by its tag first and by its trading symbol otherwise.
+type MockBroker struct {
+	MarketOpen  bool
+	Instruments models.Instruments
+	// LTPs are the prices GetLTP gives one after another
+	// and the last price repeats
+	LTPs      map[string][]float64
+	Positions models.Positions
+	Symbols   symbol.Mapper
+	// Candles are the candles of a trading symbol
+	// and interval set with SetCandles
+	Candles map[string]models.Candles
+	// Margin is the margin available and Margins the margin
+	// a unit of quantity of the trading symbol needs
+	Margin  float64
+	Margins map[string]float64
+
+	scripts     map[string][]MockStep
+	placeErrors map[string]*MockPlaceError
+	orders      []*mockOrder
+	cancelled   models.Orders
+	modified    models.Orders
+	mutex       *sync.Mutex
+}
+
+func NewMockBroker() (MockBroker, error) {
+	kiteMapper := symbol.NewKiteMapper()
+
+	return MockBroker{
+		MarketOpen:  true,
+		LTPs:        map[string][]float64{},
+		Candles:     map[string]models.Candles{},
+		Margins:     map[string]float64{},
+		Symbols:     &kiteMapper,
+		scripts:     map[string][]MockStep{},
+		placeErrors: map[string]*MockPlaceError{},
+		mutex:       &sync.Mutex{},
+	}, nil
+}
+
+// Script sets the steps the orders matching the tag or trading
+// symbol go through after getting placed. The first step is
+// the status right after placing.
+func (m *MockBroker) Script(match string, steps ...MockStep) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.scripts[match] = steps
+}
+
+// FailPlaceOrder makes placing the orders matching the tag or
+// trading symbol fail the given number of times
+func (m *MockBroker) FailPlaceOrder(match string, placeError MockPlaceError) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.placeErrors[match] = &placeError
+}
+
+// SetLTP sets the prices GetLTP gives for the symbol
+func (m *MockBroker) SetLTP(symbol string, prices ...float64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.LTPs[symbol] = prices
+}
+
+// PlacedOrders gives the orders placed so far in their latest state
+func (m *MockBroker) PlacedOrders() models.Orders {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	orders := models.Orders{}
+	for _, placed := range m.orders {
+		orders = append(orders, placed.order)
+	}
+
+	return orders
+}
+
+// ModifiedOrders gives the orders modified so far in
+// their state right after each modification
+func (m *MockBroker) ModifiedOrders() models.Orders {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append(models.Orders{}, m.modified...)
+}
+
+// CancelledOrders gives the orders cancelled so far
+func (m *MockBroker) CancelledOrders() models.Orders {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return append(models.Orders{}, m.cancelled...)
+}
+
+func (m *MockBroker) Authenticate() error {
+	return nil
+}
+
+func (m *MockBroker) IsMarketOpen() (bool, error) {
+	return m.MarketOpen, nil
+}
+
+func (m *MockBroker) GetSymbolMapper() symbol.Mapper {
+	return m.Symbols
+}
+
+func (m *MockBroker) GetLTP(symbol string) (float64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	prices := m.LTPs[symbol]
+	if len(prices) <= 0 {
+		return -1, fmt.Errorf("no LTP of %s in mock broker", symbol)
+	}
+	if len(prices) > 1 {
+		m.LTPs[symbol] = prices[1:]
+	}
+
+	return prices[0], nil
+}
+
+func (m *MockBroker) GetLTPNoFreak(symbol string) (float64, error) {
+	return m.GetLTP(symbol)
+}
+
+// GetPositions gives the scripted positions along with
+// the net positions of the filled orders
+func (m *MockBroker) GetPositions() (models.Positions, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	positions := append(models.Positions{}, m.Positions...)
+	// the positions of the products are kept apart like on kite
+	indices := map[string]int{}
+	for i, position := range positions {
+		indices[position.TradingSymbol+"/"+string(position.Product)] = i
+	}
+	for _, placed := range m.orders {
+		order := placed.order
+		if order.FilledQuantity <= 0 {
+			continue
+		}
+		quantity := order.FilledQuantity
+		if order.TransactionType == models.TransactionTypeSell {
+			quantity = -quantity
+		}
+		key := order.TradingSymbol + "/" + string(order.Product)
+		i, ok := indices[key]
+		if !ok {
+			positions = append(positions, models.Position{Instrument: order.Instrument, Product: order.Product})
+			i = len(positions) - 1
+			indices[key] = i
+		}
+		positions[i].Quantity += quantity
+	}
+
+	return positions, nil
+}
+
+func (m *MockBroker) CheckPosition(symbol string) (bool, error) {
+	positions, err := m.GetPositions()
+	if err != nil {
+		return false, err
+	}
+	for _, position := range positions {
+		if position.TradingSymbol == symbol {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *MockBroker) GetInstruments(exchange models.Exchange) (models.Instruments, error) {
+	instruments := models.Instruments{}
+	for _, instrument := range m.Instruments {
+		if len(exchange) < 1 || instrument.Exchange == exchange {
+			instruments = append(instruments, instrument)
+		}
+	}
+
+	return instruments, nil
+}
+
+// SetCandles sets the candles GetHistoricalCandles
+// gives of the symbol and interval
+func (m *MockBroker) SetCandles(symbol string, interval models.Interval, candles ...models.Candle) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.Candles[symbol+":"+string(interval)] = candles
+}
+
+func (m *MockBroker) GetHistoricalCandles(symbol string, interval models.Interval, from, to time.Time) (models.Candles, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	candles := models.Candles{}
+	for _, candle := range m.Candles[symbol+":"+string(interval)] {
+		if !candle.Time.Before(from) && !candle.Time.After(to) {
+			candles = append(candles, candle)
+		}
+	}
+
+	return candles, nil
+}
+
+func (m *MockBroker) GetInstrument(symbol string, exchange models.Exchange) (models.Instrument, error) {
+	instruments, err := m.GetInstruments(exchange)
+	if err != nil {
+		return models.Instrument{}, err
+	}
+	for _, instrument := range instruments {
+		if instrument.TradingSymbol == symbol {
+			return instrument, nil
+		}
+	}
+
+	return models.Instrument{}, nil
+}
+
+// GetOrders moves every open order one scripted step
+// further and gives all the orders
+func (m *MockBroker) GetOrders() (models.Orders, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	orders := models.Orders{}
+	for _, placed := range m.orders {
+		if !placed.order.Status.IsFinal() && len(placed.steps) > 0 {
+			m.applyStep(placed)
+		}
+		orders = append(orders, placed.order)
+	}
+
+	return orders, nil
+}
+
+func (m *MockBroker) GetTrades() (models.Trades, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	trades := models.Trades{}
+	for _, placed := range m.orders {
+		order := placed.order
+		if order.FilledQuantity <= 0 {
+			continue
+		}
+		trades = append(trades, models.Trade{
+			TradeID:         "T" + order.OrderID,
+			OrderID:         order.OrderID,
+			TradingSymbol:   order.TradingSymbol,
+			Exchange:        order.Exchange,
+			Product:         order.Product,
+			TransactionType: order.TransactionType,
+			Quantity:        order.FilledQuantity,
+			AveragePrice:    order.AveragePrice,
+			FillTime:        time.Now(),
+		})
+	}
+
+	return trades, nil
+}
+
+func (m *MockBroker) GetOrderID(order models.Order) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, placed := range m.orders {
+		existing := placed.order
+		if len(order.Tag) > 0 {
+			live, err := isLiveOrderWithTag(existing, order)
+			if err != nil {
+				return "", err
+			}
+			if live {
+				return existing.OrderID, nil
+			}
+			continue
+		}
+		if existing.Exchange == order.Exchange && existing.TradingSymbol == order.TradingSymbol && existing.Product == order.Product && existing.OrderType == order.OrderType && existing.TransactionType == order.TransactionType && existing.Quantity == order.Quantity {
+			return existing.OrderID, nil
+		}
+	}
+
+	return "", fmt.Errorf("couldn't find order of %s in mock broker", order.TradingSymbol)
+}
+
+// PlaceOrder places the order and moves it to the first scripted
+// step. Without a script market and limit orders get filled and
+// stop loss orders wait for the trigger. An order which is already
+// placed gets modified and a live order with the tag of the order
+// gets adopted like on kite.
+func (m *MockBroker) PlaceOrder(order *models.Order) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(order.OrderID) > 0 {
+		return m.modifyOrder(order)
+	}
+	if len(order.Tag) > 0 {
+		for _, placed := range m.orders {
+			live, err := isLiveOrderWithTag(placed.order, *order)
+			if err != nil {
+				return err
+			}
+			if live {
+				order.OrderID = placed.order.OrderID
+				updatePlaced(order, placed.order)
+				return nil
+			}
+		}
+	}
+
+	placeError := m.placeErrorOf(*order)
+	if placeError != nil && placeError.Times > 0 {
+		placeError.Times--
+		if !placeError.Placed {
+			return placeError.Err
+		}
+	}
+
+	placed := &mockOrder{order: *order}
+	placed.order.OrderID = fmt.Sprintf("%d", len(m.orders)+1)
+	placed.order.FilledQuantity = 0
+	placed.order.PendingQuantity = order.Quantity
+	placed.order.Slices = nil
+	placed.steps = m.scriptOf(*order)
+	if len(placed.steps) <= 0 {
+		placed.steps = []MockStep{{Status: models.StatusComplete}}
+		if order.OrderType == models.OrderTypeSL || order.OrderType == models.OrderTypeSLM {
+			placed.steps = []MockStep{{Status: models.StatusTriggerPending}}
+		}
+	}
+	m.applyStep(placed)
+	m.orders = append(m.orders, placed)
+
+	if placeError != nil && placeError.Placed && placeError.Err != nil {
+		return placeError.Err
+	}
+	order.OrderID = placed.order.OrderID
+	updatePlaced(order, placed.order)
+	if placed.order.Status == models.StatusRejected {
+		return fmt.Errorf("order failed with status %s and message %s", placed.order.Status, placed.order.StatusMessage)
+	}
+
+	return nil
+}
+
+// modifyOrder changes the type and prices of the placed order, like
+// the watchers do with a stop loss stuck open. A market or limit
+// order without scripted steps left gets filled.
+func (m *MockBroker) modifyOrder(order *models.Order) error {
+	placed := m.placedOrder(order.OrderID)
+	if placed == nil {
+		return fmt.Errorf("order %s of %s is not placed in mock broker", order.OrderID, order.TradingSymbol)
+	}
+	if !placed.order.Status.IsFinal() {
+		placed.order.OrderType = order.OrderType
+		placed.order.Price = order.Price
+		placed.order.TriggerPrice = order.TriggerPrice
+		if len(placed.steps) <= 0 && (order.OrderType == models.OrderTypeMarket || order.OrderType == models.OrderTypeLimit) {
+			placed.steps = []MockStep{{Status: models.StatusComplete}}
+			m.applyStep(placed)
+		}
+		m.modified = append(m.modified, placed.order)
+	}
+	updatePlaced(order, placed.order)
+	if placed.order.Status == models.StatusRejected {
+		return fmt.Errorf("order failed with status %s and message %s", placed.order.Status, placed.order.StatusMessage)
+	}
+
+	return nil
+}
+
+// PlaceBasketOrder places and rolls back the basket like kite
+func (m *MockBroker) PlaceBasketOrder(orders models.RefOrders, timeout time.Duration) error {
+	return placeBasket(m, orders, timeout)
+}
+
+func (m *MockBroker) placeOrderContext(ctx context.Context, order *models.Order) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return m.PlaceOrder(order)
+}
+
+func (m *MockBroker) cancelOpenOrder(order *models.Order) error {
+	return m.CancelOrder(order)
+}
+
+func (m *MockBroker) CancelOrder(order *models.Order) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	placed := m.placedOrder(order.OrderID)
+	if placed == nil {
+		return nil
+	}
+	if !placed.order.Status.IsFinal() {
+		placed.order.Status = models.StatusCancelled
+		placed.order.PendingQuantity = 0
+		placed.steps = nil
+		m.cancelled = append(m.cancelled, placed.order)
+	}
+	updatePlaced(order, placed.order)
+
+	return nil
+}
+
+func (m *MockBroker) CancelOrders(orders models.RefOrders) error {
+	for _, order := range orders {
+		err := m.CancelOrder(order)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MockBroker) GetMargin() (float64, error) {
+	return m.Margin, nil
+}
+
+func (m *MockBroker) GetBasketMargin(orders models.Orders) (float64, error) {
+	margin := 0.0
+	for _, order := range orders {
+		margin += m.Margins[order.TradingSymbol] * float64(order.Quantity)
+	}
+
+	return margin, nil
+}
+
+// placedOrder gives the placed order of the order id
+func (m *MockBroker) placedOrder(orderID string) *mockOrder {
+	for _, placed := range m.orders {
+		if placed.order.OrderID == orderID {
+			return placed
+		}
+	}
+
+	return nil
+}
+
+// updatePlaced records the state of the placed order on the order
+func updatePlaced(order *models.Order, placed models.Order) {
+	order.Status = placed.Status
+	order.StatusMessage = placed.StatusMessage
+	order.FilledQuantity = placed.FilledQuantity
+	order.PendingQuantity = placed.PendingQuantity
+	order.AveragePrice = placed.AveragePrice
+}
+
+// applyStep moves the order to its next scripted step
+func (m *MockBroker) applyStep(placed *mockOrder) {
+	step := placed.steps[0]
+	placed.steps = placed.steps[1:]
+
+	order := &placed.order
+	order.Status = step.Status
+	order.StatusMessage = step.StatusMessage
+	filled := step.FilledQuantity
+	if filled <= 0 && step.Status == models.StatusComplete {
+		filled = order.Quantity
+	}
+	if filled > 0 {
+		order.FilledQuantity = filled
+		order.AveragePrice = step.AveragePrice
+		if order.AveragePrice <= 0 {
+			order.AveragePrice = order.Price
+		}
+	}
+	order.PendingQuantity = order.Quantity - order.FilledQuantity
+	if order.Status.IsFinal() {
+		order.PendingQuantity = 0
+	}
+}
+
+// scriptOf gives the steps scripted for the order
+func (m *MockBroker) scriptOf(order models.Order) []MockStep {
+	if steps, ok := m.scripts[order.Tag]; ok && len(order.Tag) > 0 {
+		return steps
+	}
+
+	return m.scripts[order.TradingSymbol]
+}
+
+// placeErrorOf gives the error set for placing the order
+func (m *MockBroker) placeErrorOf(order models.Order) *MockPlaceError {
+	if placeError, ok := m.placeErrors[order.Tag]; ok && len(order.Tag) > 0 {
+		return placeError
+	}
+
+	return m.placeErrors[order.TradingSymbol]
+}
@@ -0,0 +1,198 @@
+package broker
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+func newTestOrder(tradingSymbol string, transactionType models.TransactionType, orderType models.OrderType) *models.Order {
+	return &models.Order{
+		Instrument: models.Instrument{
+			TradingSymbol: tradingSymbol,
+			Exchange:      models.ExchangeNFO,
+			LotSize:       50,
+		},
+		Tag:             NewOrderTag("test", tradingSymbol, string(transactionType)),
+		Product:         models.ProductMIS,
+		OrderType:       orderType,
+		TransactionType: transactionType,
+		Quantity:        50,
+		Price:           100,
+	}
+}
+
+func newTestBroker(t *testing.T) *MockBroker {
+	mockBroker, err := NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &mockBroker
+}
+
+func orderNumber(t *testing.T, order models.Order) int {
+	number, err := strconv.Atoi(order.OrderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return number
+}
+
+func TestMockPlaceOrderModifiesPlacedOrder(t *testing.T) {
+	mockBroker := newTestBroker(t)
+	stopLoss := newTestOrder("NIFTY2410421500CE", models.TransactionTypeBuy, models.OrderTypeSL)
+	mockBroker.Script(stopLoss.TradingSymbol, MockStep{Status: models.StatusTriggerPending}, MockStep{Status: models.StatusOpen})
+
+	err := mockBroker.PlaceOrder(stopLoss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stopLoss.Status != models.StatusTriggerPending {
+		t.Fatalf("got status %s, want %s", stopLoss.Status, models.StatusTriggerPending)
+	}
+	_, err = mockBroker.GetOrders()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopLoss.OrderType = models.OrderTypeLimit
+	stopLoss.Price = 120
+	err = mockBroker.PlaceOrder(stopLoss)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	placed := mockBroker.PlacedOrders()
+	if len(placed) != 1 {
+		t.Fatalf("got %d placed orders, want the stop loss modified", len(placed))
+	}
+	if placed[0].OrderType != models.OrderTypeLimit || placed[0].Price != 120 {
+		t.Errorf("got %s order at %f, want LIMIT order at 120", placed[0].OrderType, placed[0].Price)
+	}
+	if stopLoss.Status != models.StatusComplete || stopLoss.FilledQuantity != 50 || stopLoss.AveragePrice != 120 {
+		t.Errorf("got status %s with %d filled at %f, want COMPLETE with 50 filled at 120", stopLoss.Status, stopLoss.FilledQuantity, stopLoss.AveragePrice)
+	}
+	if len(mockBroker.ModifiedOrders()) != 1 {
+		t.Errorf("got %d modifications, want 1", len(mockBroker.ModifiedOrders()))
+	}
+}
+
+func TestMockBasketRollsBackShortsBeforeHedges(t *testing.T) {
+	mockBroker := newTestBroker(t)
+	buyCE := newTestOrder("NIFTY2410422000CE", models.TransactionTypeBuy, models.OrderTypeLimit)
+	buyPE := newTestOrder("NIFTY2410421000PE", models.TransactionTypeBuy, models.OrderTypeLimit)
+	sellCE := newTestOrder("NIFTY2410421500CE", models.TransactionTypeSell, models.OrderTypeLimit)
+	sellPE := newTestOrder("NIFTY2410421500PE", models.TransactionTypeSell, models.OrderTypeLimit)
+	mockBroker.FailPlaceOrder(sellPE.Tag, MockPlaceError{Err: errors.New("insufficient margin"), Times: 1})
+
+	err := mockBroker.PlaceBasketOrder(models.RefOrders{sellCE, sellPE, buyCE, buyPE}, time.Minute)
+	if err == nil {
+		t.Fatal("got no error, want the basket to fail")
+	}
+
+	placed := mockBroker.PlacedOrders()
+	if len(placed) != 6 {
+		t.Fatalf("got %d placed orders, want 3 legs and 3 square offs: %v", len(placed), placed)
+	}
+	entries, exits := placed[:3], placed[3:]
+	for _, entry := range entries {
+		if entry.TransactionType == models.TransactionTypeSell && orderNumber(t, entry) < 3 {
+			t.Errorf("short %s was placed before the hedges", entry.TradingSymbol)
+		}
+	}
+	if exits[0].TradingSymbol != sellCE.TradingSymbol || exits[0].TransactionType != models.TransactionTypeBuy {
+		t.Errorf("got first square off %s %s, want the short %s bought back", exits[0].TransactionType, exits[0].TradingSymbol, sellCE.TradingSymbol)
+	}
+	for _, exit := range exits[1:] {
+		if exit.TransactionType != models.TransactionTypeSell {
+			t.Errorf("got square off %s %s after the short, want the hedges sold", exit.TransactionType, exit.TradingSymbol)
+		}
+	}
+	positions, err := mockBroker.GetPositions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, position := range positions {
+		if position.Quantity != 0 {
+			t.Errorf("got %d of %s after the rollback, want 0", position.Quantity, position.TradingSymbol)
+		}
+	}
+}
+
+func TestMockBasketRollbackKeepsHedgesOfShortNotBoughtBack(t *testing.T) {
+	mockBroker := newTestBroker(t)
+	buyCE := newTestOrder("NIFTY2410422000CE", models.TransactionTypeBuy, models.OrderTypeLimit)
+	sellCE := newTestOrder("NIFTY2410421500CE", models.TransactionTypeSell, models.OrderTypeLimit)
+	sellPE := newTestOrder("NIFTY2410421500PE", models.TransactionTypeSell, models.OrderTypeLimit)
+	mockBroker.FailPlaceOrder(sellPE.Tag, MockPlaceError{Err: errors.New("insufficient margin"), Times: 1})
+	// the hedge is order 1 and the short order 2
+	mockBroker.FailPlaceOrder(NewOrderTag(sellCE.Tag, "rollback", "2"), MockPlaceError{Err: errors.New("insufficient margin"), Times: 1})
+
+	err := mockBroker.PlaceBasketOrder(models.RefOrders{buyCE, sellCE, sellPE}, time.Minute)
+	if err == nil {
+		t.Fatal("got no error, want the basket to fail")
+	}
+
+	for _, order := range mockBroker.PlacedOrders() {
+		if order.TradingSymbol == buyCE.TradingSymbol && order.TransactionType == models.TransactionTypeSell {
+			t.Errorf("hedge %s was sold while the short %s is still open", buyCE.TradingSymbol, sellCE.TradingSymbol)
+		}
+	}
+}
+
+func TestMockBasketRollsBackLegTwice(t *testing.T) {
+	mockBroker := newTestBroker(t)
+	sellCE := newTestOrder("NIFTY2410421500CE", models.TransactionTypeSell, models.OrderTypeLimit)
+	sellPE := newTestOrder("NIFTY2410421500PE", models.TransactionTypeSell, models.OrderTypeLimit)
+	checkFlat := func(when string) {
+		t.Helper()
+		positions, err := mockBroker.GetPositions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, position := range positions {
+			if position.Quantity != 0 {
+				t.Errorf("got %d of %s after %s, want 0", position.Quantity, position.TradingSymbol, when)
+			}
+		}
+	}
+
+	// the second attempt adopts the short filled and squared
+	// off by the first, so it has nothing more to square off
+	for attempt := 1; attempt <= 2; attempt++ {
+		mockBroker.FailPlaceOrder(sellPE.Tag, MockPlaceError{Err: errors.New("insufficient margin"), Times: 1})
+		ce, pe := *sellCE, *sellPE
+		err := mockBroker.PlaceBasketOrder(models.RefOrders{&ce, &pe}, time.Minute)
+		if err == nil {
+			t.Fatalf("got no error at attempt %d, want the basket to fail", attempt)
+		}
+		checkFlat(fmt.Sprintf("rollback %d", attempt))
+	}
+	if placed := mockBroker.PlacedOrders(); len(placed) != 2 {
+		t.Fatalf("got %d placed orders, want the short and its square off: %v", len(placed), placed)
+	}
+
+	// a new order of the leg under the same tag gets
+	// a square off of its own
+	again := *sellCE
+	again.Tag = ""
+	err := mockBroker.PlaceOrder(&again)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Tag = sellCE.Tag
+	err = rollbackBasket(mockBroker, models.RefOrders{&again})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFlat("rolling back the new order")
+	if placed := mockBroker.PlacedOrders(); len(placed) != 4 {
+		t.Errorf("got %d placed orders, want the new order squared off as well: %v", len(placed), placed)
+	}
+}
@@ -0,0 +1,170 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// MaxOrderQuantities is the largest quantity the exchange accepts in
+// a single order of the underlying. The exchange freezes orders of the
+// freeze quantity and above, which is one more than these (1801 for
+// NIFTY). It can be overridden with MAX_ORDER_QUANTITY_<UNDERLYING>.
+var MaxOrderQuantities = map[string]int{
+	"NIFTY":      1800,
+	"BANKNIFTY":  900,
+	"FINNIFTY":   1800,
+	"MIDCPNIFTY": 4200,
+}
+
+// MaxOrderQuantity gives the largest quantity allowed in a single order
+// of the order, zero when the underlying has no freeze limit
+func MaxOrderQuantity(order models.Order) (int, error) {
+	name := order.Name
+	if len(name) <= 0 {
+		for underlying := range MaxOrderQuantities {
+			if strings.HasPrefix(order.TradingSymbol, underlying) && len(underlying) > len(name) {
+				name = underlying
+			}
+		}
+	}
+	if len(name) <= 0 {
+		return 0, nil
+	}
+
+	if value := os.Getenv(fmt.Sprintf("MAX_ORDER_QUANTITY_%s", name)); len(value) > 0 {
+		maxQuantity, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, err
+		}
+		return maxQuantity, nil
+	}
+
+	return MaxOrderQuantities[name], nil
+}
+
+// SliceOrder splits the order into child orders each within the
+// max quantity and in multiples of the lot size
+func SliceOrder(order models.Order, maxQuantity int) models.Orders {
+	lotSize := order.LotSize
+	if lotSize <= 0 {
+		lotSize = 1
+	}
+	sliceQuantity := (maxQuantity / lotSize) * lotSize
+	if sliceQuantity <= 0 {
+		sliceQuantity = lotSize
+	}
+
+	slices := models.Orders{}
+	for remaining := order.Quantity; remaining > 0; remaining = remaining - sliceQuantity {
+		slice := order
+		slice.Slices = nil
+		slice.OrderID = ""
+		slice.Status = ""
+		slice.AveragePrice = 0
+		slice.FilledQuantity = 0
+		slice.PendingQuantity = 0
+		slice.Quantity = sliceQuantity
+		if remaining < sliceQuantity {
+			slice.Quantity = remaining
+		}
+		if len(order.Tag) > 0 {
+			slice.Tag = NewOrderTag(order.Tag, strconv.Itoa(len(slices)))
+		}
+		slices = append(slices, slice)
+	}
+
+	return slices
+}
+
+// needsSlicing tells whether the order
+// has to be placed as multiple child orders
+func needsSlicing(order *models.Order) (bool, error) {
+	if len(order.Slices) > 0 && len(order.OrderID) > 0 {
+		return true, nil
+	}
+	maxQuantity, err := MaxOrderQuantity(*order)
+	if err != nil {
+		return false, err
+	}
+
+	return maxQuantity > 0 && order.Quantity > maxQuantity && order.Quantity > order.LotSize, nil
+}
+
+// placeSlicedOrder places the order as child orders within the
+// freeze limit and aggregates their fills into the order. Children
+// which are already placed are kept so a retry only places the rest.
+// The children are placed concurrently within the order rate limit.
+func (z *ZerodhaBroker) placeSlicedOrder(ctx context.Context, order *models.Order) error {
+	if len(order.OrderID) <= 0 {
+		maxQuantity, err := MaxOrderQuantity(*order)
+		if err != nil {
+			return err
+		}
+		order.Slices = SliceOrder(*order, maxQuantity)
+	}
+
+	children := models.RefOrders{}
+	for i := range order.Slices {
+		slice := &order.Slices[i]
+		slice.OrderType = order.OrderType
+		slice.Price = order.Price
+		slice.TriggerPrice = order.TriggerPrice
+		if slice.Status != models.StatusComplete {
+			children = append(children, slice)
+		}
+	}
+
+	err := placeOrdersConcurrently(ctx, z, children)
+	aggregateSlices(order)
+	if err != nil {
+		return fmt.Errorf("filled %d of %d quantity of %s because %s", order.FilledQuantity, order.Quantity, order.TradingSymbol, err)
+	}
+
+	return nil
+}
+
+// aggregateSlices sets the order id, status, fills and
+// average price of the order from its child orders
+func aggregateSlices(order *models.Order) {
+	var filledValue float64
+
+	order.OrderID = ""
+	order.Status = ""
+	order.FilledQuantity = 0
+	order.PendingQuantity = 0
+	for _, slice := range order.Slices {
+		if len(order.OrderID) <= 0 {
+			order.OrderID = slice.OrderID
+		}
+		if len(order.Status) <= 0 || order.Status == models.StatusComplete {
+			order.Status = slice.Status
+		}
+		order.FilledQuantity = order.FilledQuantity + slice.FilledQuantity
+		order.PendingQuantity = order.PendingQuantity + slice.PendingQuantity
+		filledValue = filledValue + slice.AveragePrice*float64(slice.FilledQuantity)
+	}
+	if order.FilledQuantity > 0 {
+		order.AveragePrice = filledValue / float64(order.FilledQuantity)
+	}
+}
+
+// FlattenSlices replaces sliced orders with their child orders
+func FlattenSlices(orders models.RefOrders) models.RefOrders {
+	flattened := models.RefOrders{}
+	for _, order := range orders {
+		if len(order.Slices) <= 0 {
+			flattened = append(flattened, order)
+			continue
+		}
+		for i := range order.Slices {
+			flattened = append(flattened, &order.Slices[i])
+		}
+	}
+
+	return flattened
+}
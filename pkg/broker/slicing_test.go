@@ -0,0 +1,150 @@
+package broker
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+func TestSliceOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		quantity    int
+		lotSize     int
+		maxQuantity int
+		want        []int
+	}{
+		{"below the limit", 1750, 50, 1800, []int{1750}},
+		{"at the limit", 1800, 50, 1800, []int{1800}},
+		{"a lot above the limit", 1850, 50, 1800, []int{1800, 50}},
+		{"twice the limit", 3600, 50, 1800, []int{1800, 1800}},
+		{"three times the limit", 5400, 50, 1800, []int{1800, 1800, 1800}},
+		{"limit not a multiple of the lot size", 3570, 35, 1800, []int{1785, 1785}},
+		{"limit below the lot size", 100, 50, 30, []int{50, 50}},
+		{"no lot size", 2000, 0, 1800, []int{1800, 200}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			order := models.Order{
+				Instrument:     models.Instrument{TradingSymbol: "NIFTY24JAN21500CE", LotSize: test.lotSize},
+				Quantity:       test.quantity,
+				Tag:            "TWELVE0123456789abcd",
+				OrderID:        "1",
+				Status:         models.StatusOpen,
+				FilledQuantity: 50,
+			}
+			slices := SliceOrder(order, test.maxQuantity)
+
+			quantities := []int{}
+			tags := map[string]bool{}
+			for _, slice := range slices {
+				quantities = append(quantities, slice.Quantity)
+				tags[slice.Tag] = true
+				if len(slice.OrderID) > 0 || len(slice.Status) > 0 || slice.FilledQuantity != 0 {
+					t.Errorf("got slice %+v with the state of the order, want a new order", slice)
+				}
+				if slice.Tag == order.Tag || OrderTagPrefix(slice.Tag) != OrderTagPrefix(order.Tag) {
+					t.Errorf("got slice tag %s, want a tag of its own with the prefix of %s", slice.Tag, order.Tag)
+				}
+			}
+			if !reflect.DeepEqual(quantities, test.want) {
+				t.Errorf("got slices of %v, want %v", quantities, test.want)
+			}
+			if len(tags) != len(slices) {
+				t.Errorf("got %d tags for %d slices, want one each", len(tags), len(slices))
+			}
+		})
+	}
+}
+
+func TestNeedsSlicing(t *testing.T) {
+	tests := []struct {
+		name  string
+		order models.Order
+		want  bool
+	}{
+		{"at the limit", models.Order{Instrument: models.Instrument{TradingSymbol: "NIFTY24JAN21500CE", LotSize: 50}, Quantity: 1800}, false},
+		{"above the limit", models.Order{Instrument: models.Instrument{TradingSymbol: "NIFTY24JAN21500CE", LotSize: 50}, Quantity: 1850}, true},
+		{"above the limit of the longest underlying", models.Order{Instrument: models.Instrument{TradingSymbol: "BANKNIFTY24JAN47000CE", LotSize: 15}, Quantity: 915}, true},
+		{"named underlying", models.Order{Instrument: models.Instrument{TradingSymbol: "NIFTY24JAN21500CE", Name: "MIDCPNIFTY", LotSize: 50}, Quantity: 1850}, false},
+		{"no freeze limit", models.Order{Instrument: models.Instrument{TradingSymbol: "RELIANCE", LotSize: 1}, Quantity: 10000}, false},
+		{"placed in slices", models.Order{Instrument: models.Instrument{TradingSymbol: "NIFTY24JAN21500CE", LotSize: 50}, Quantity: 100, OrderID: "1", Slices: models.Orders{{OrderID: "1"}}}, true},
+	}
+	for _, test := range tests {
+		got, err := needsSlicing(&test.order)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("got %t for %s, want %t", got, test.name, test.want)
+		}
+	}
+}
+
+func TestMaxOrderQuantityOverride(t *testing.T) {
+	t.Setenv("MAX_ORDER_QUANTITY_NIFTY", "900")
+	order := models.Order{Instrument: models.Instrument{TradingSymbol: "NIFTY24JAN21500CE"}}
+
+	maxQuantity, err := MaxOrderQuantity(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxQuantity != 900 {
+		t.Errorf("got %d, want the override of 900", maxQuantity)
+	}
+
+	t.Setenv("MAX_ORDER_QUANTITY_NIFTY", "many")
+	_, err = MaxOrderQuantity(order)
+	if err == nil {
+		t.Error("got a max quantity of many, want an error")
+	}
+}
+
+func TestAggregateSlices(t *testing.T) {
+	tests := []struct {
+		name   string
+		slices models.Orders
+		want   models.Order
+	}{
+		{
+			"all complete",
+			models.Orders{
+				{OrderID: "1", Quantity: 1800, Status: models.StatusComplete, FilledQuantity: 1800, AveragePrice: 100},
+				{OrderID: "2", Quantity: 200, Status: models.StatusComplete, FilledQuantity: 200, AveragePrice: 110},
+			},
+			models.Order{OrderID: "1", Status: models.StatusComplete, FilledQuantity: 2000, AveragePrice: 101},
+		},
+		{
+			"one open",
+			models.Orders{
+				{OrderID: "1", Quantity: 1800, Status: models.StatusComplete, FilledQuantity: 1800, AveragePrice: 100},
+				{OrderID: "2", Quantity: 1800, Status: models.StatusOpen, FilledQuantity: 600, PendingQuantity: 1200, AveragePrice: 104},
+				{OrderID: "3", Quantity: 400, Status: models.StatusComplete, FilledQuantity: 400, AveragePrice: 100},
+			},
+			models.Order{OrderID: "1", Status: models.StatusOpen, FilledQuantity: 2800, PendingQuantity: 1200, AveragePrice: (1800*100 + 600*104 + 400*100) / 2800.0},
+		},
+		{
+			"one rejected",
+			models.Orders{
+				{OrderID: "1", Quantity: 1800, Status: models.StatusComplete, FilledQuantity: 1800, AveragePrice: 100},
+				{Quantity: 1800, Status: models.StatusRejected},
+			},
+			models.Order{OrderID: "1", Status: models.StatusRejected, FilledQuantity: 1800, AveragePrice: 100},
+		},
+		{
+			"none placed",
+			models.Orders{{Quantity: 1800}, {Quantity: 200}},
+			models.Order{},
+		},
+	}
+	for _, test := range tests {
+		order := models.Order{Quantity: 2000, Slices: test.slices}
+		aggregateSlices(&order)
+		if order.OrderID != test.want.OrderID || order.Status != test.want.Status || order.FilledQuantity != test.want.FilledQuantity ||
+			order.PendingQuantity != test.want.PendingQuantity || order.AveragePrice != test.want.AveragePrice {
+			t.Errorf("got %s %s filled %d pending %d at %f when %s, want %s %s filled %d pending %d at %f", order.OrderID, order.Status, order.FilledQuantity, order.PendingQuantity, order.AveragePrice,
+				test.name, test.want.OrderID, test.want.Status, test.want.FilledQuantity, test.want.PendingQuantity, test.want.AveragePrice)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package broker
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/avast/retry-go"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const (
+	// MaxOrderTagLength is the longest tag kite accepts on an order
+	MaxOrderTagLength    = 20
+	orderTagPrefixLength = 6
+)
+
+// NewOrderTag generates the tag of an order from the strategy, run id
+// and leg. The same parts always give the same tag so that an order can
+// be found again after a timeout or a restart instead of being placed twice.
+func NewOrderTag(prefix string, parts ...string) string {
+	hash := sha1.Sum([]byte(strings.Join(append([]string{prefix}, parts...), "/")))
+
+	tag := OrderTagPrefix(prefix) + strings.ToUpper(hex.EncodeToString(hash[:]))
+	return tag[:MaxOrderTagLength]
+}
+
+// OrderTagPrefix gives the start of the tags of the orders of the
+// strategy, so its orders can be told apart from the others
+func OrderTagPrefix(prefix string) string {
+	tagPrefix := ""
+	for _, r := range strings.ToUpper(prefix) {
+		if len(tagPrefix) >= orderTagPrefixLength {
+			break
+		}
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			tagPrefix = tagPrefix + string(r)
+		}
+	}
+
+	return tagPrefix
+}
+
+// getOrderByTag finds the live order carrying the tag of the order
+func (z *ZerodhaBroker) getOrderByTag(order models.Order) (models.Order, bool, error) {
+	var result models.Order
+	found := false
+
+	orders, err := z.GetOrders()
+	if err != nil {
+		return models.Order{}, false, err
+	}
+	for _, existing := range orders {
+		live, err := isLiveOrderWithTag(existing, order)
+		if err != nil {
+			return models.Order{}, false, err
+		}
+		if live {
+			result = existing
+			found = true
+		}
+	}
+
+	return result, found, nil
+}
+
+// isLiveOrderWithTag tells whether the existing order carries the tag of
+// the order and was not rejected or cancelled unfilled, since such an
+// order is placed again with the same tag. It fails when the existing
+// order is for another symbol, side or quantity, like when a restart
+// picked other strikes for the leg, as adopting it would track an
+// order the leg does not hold.
+func isLiveOrderWithTag(existing models.Order, order models.Order) (bool, error) {
+	if existing.Tag != order.Tag {
+		return false, nil
+	}
+	if existing.Status == models.StatusRejected {
+		return false, nil
+	}
+	if existing.Status == models.StatusCancelled && existing.FilledQuantity <= 0 {
+		return false, nil
+	}
+	if existing.TradingSymbol != order.TradingSymbol || existing.TransactionType != order.TransactionType || existing.Quantity != order.Quantity {
+		return true, retry.Unrecoverable(fmt.Errorf("order %s with tag %s is to %s %d of %s and not to %s %d of %s",
+			existing.OrderID, order.Tag, existing.TransactionType, existing.Quantity, existing.TradingSymbol,
+			order.TransactionType, order.Quantity, order.TradingSymbol))
+	}
+
+	return true, nil
+}
@@ -0,0 +1,55 @@
+package broker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// OrdersPerSecond is the most orders kite accepts in a second.
+// Placing and modifying an order both count towards it.
+var OrdersPerSecond = 10
+
+// OrderThrottle spaces out the order requests so that the slices
+// and legs placed concurrently stay within the rate limit
+type OrderThrottle struct {
+	interval time.Duration
+	next     time.Time
+	mutex    *sync.Mutex
+}
+
+func NewOrderThrottle(perSecond int) *OrderThrottle {
+	interval := time.Duration(0)
+	if perSecond > 0 {
+		interval = time.Second / time.Duration(perSecond)
+	}
+
+	return &OrderThrottle{
+		interval: interval,
+		mutex:    &sync.Mutex{},
+	}
+}
+
+// Wait blocks until the next order request is allowed
+// or the context is done. A nil throttle never waits.
+func (t *OrderThrottle) Wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	now := time.Now()
+	slot := t.next
+	if slot.Before(now) {
+		slot = now
+	}
+	t.next = slot.Add(t.interval)
+	t.mutex.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(slot)):
+		return nil
+	}
+}
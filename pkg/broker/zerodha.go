@@ -1,11 +1,11 @@
 package broker
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log"
 	"math"
-	"net/url"
 	"strings"
 	"time"
 
@@ -13,10 +13,8 @@ import (
 	"github.com/rohitsakala/strategies/pkg/authenticator"
 	"github.com/rohitsakala/strategies/pkg/database"
 	"github.com/rohitsakala/strategies/pkg/models"
-	"github.com/tebeka/selenium"
-	"github.com/tebeka/selenium/chrome"
+	"github.com/rohitsakala/strategies/pkg/symbol"
 	kiteconnect "github.com/zerodha/gokiteconnect/v4"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 type ZerodhaBroker struct {
@@ -27,16 +25,17 @@ type ZerodhaBroker struct {
 	APISecret     string
 	Client        *kiteconnect.Client
 	TimeZone      time.Location
-	Database      database.Database
-	Filter        bson.M
+	Credentials   database.CredentialsRepo
 	Authenticator authenticator.Authenticator
+	Execution     ExecutionConfig
+	Login         LoginConfig
+	Symbols       symbol.Mapper
+	Candles       CandleCache
+	Throttle      *OrderThrottle
 }
 
-func NewZerodhaBroker(database database.Database, authenticator authenticator.Authenticator, url, userID, password, apiKey, apiSecret string) (ZerodhaBroker, error) {
-	err := database.CreateCollection("credentials")
-	if err != nil {
-		return ZerodhaBroker{}, err
-	}
+func NewZerodhaBroker(credentials database.CredentialsRepo, authenticator authenticator.Authenticator, execution ExecutionConfig, login LoginConfig, url, userID, password, apiKey, apiSecret string) (ZerodhaBroker, error) {
+	kiteMapper := symbol.NewKiteMapper()
 
 	return ZerodhaBroker{
 		URL:           url,
@@ -44,47 +43,17 @@ func NewZerodhaBroker(database database.Database, authenticator authenticator.Au
 		APIKey:        apiKey,
 		APISecret:     apiSecret,
 		Password:      password,
-		Database:      database,
+		Credentials:   credentials,
 		Authenticator: authenticator,
+		Execution:     execution,
+		Login:         login,
+		Symbols:       &kiteMapper,
+		Throttle:      NewOrderThrottle(OrdersPerSecond),
 	}, nil
 }
 
-func (z *ZerodhaBroker) fetchAccessToken() (models.Credentials, error) {
-	var data models.Credentials
-
-	collectionRaw, err := z.Database.GetCollection(bson.D{}, "credentials")
-	if err != nil {
-		return models.Credentials{}, err
-	}
-	if len(collectionRaw) <= 0 {
-		insertID, err := z.Database.InsertCollection(data, "credentials")
-		if err != nil {
-			return data, err
-		}
-		z.Filter = bson.M{
-			"_id": insertID,
-		}
-		return data, nil
-	}
-
-	dataBytes, err := bson.Marshal(collectionRaw)
-	if err != nil {
-		return models.Credentials{}, err
-	}
-	err = bson.Unmarshal(dataBytes, &data)
-	if err != nil {
-		return models.Credentials{}, err
-	}
-
-	z.Filter = bson.M{
-		"_id": collectionRaw["_id"],
-	}
-
-	return data, nil
-}
-
 func (z *ZerodhaBroker) checkConnection(credentials models.Credentials) error {
-	kc := kiteconnect.New(z.APIKey)
+	kc := z.newKiteClient()
 	kc.SetAccessToken(credentials.AccessToken)
 
 	_, err := kc.GetUserMargins()
@@ -96,100 +65,57 @@ func (z *ZerodhaBroker) checkConnection(credentials models.Credentials) error {
 }
 
 func (z *ZerodhaBroker) getAccessToken(kc *kiteconnect.Client) (string, error) {
-	caps := selenium.Capabilities{"browserName": "chrome"}
-	chromeCaps := chrome.Capabilities{
-		Path: "",
-		Args: []string{
-			"--headless",
-			"--no-sandbox",
-		},
-	}
-	caps.AddChrome(chromeCaps)
-	webDriver, err := selenium.NewRemote(caps, fmt.Sprintf("http://localhost:%d/wd/hub", 8080))
-	if err != nil {
-		return "", err
-	}
-	defer webDriver.Quit()
-
-	webDriver.Get(z.URL)
-
-	userIDField, err := webDriver.FindElement(selenium.ByID, "userid")
+	requestToken, err := z.getRequestToken()
 	if err != nil {
 		return "", err
 	}
 
-	userIDField.SendKeys(z.UserID)
-	passwordElement, err := webDriver.FindElement(selenium.ByID, "password")
+	data, err := kc.GenerateSession(requestToken, z.APISecret)
 	if err != nil {
 		return "", err
 	}
 
-	passwordElement.SendKeys(z.Password)
-	loginButton, err := webDriver.FindElement(selenium.ByCSSSelector, "button[type=submit]")
-	if err != nil {
-		return "", err
-	}
-	loginButton.Click()
-	time.Sleep(1 * time.Second)
+	return data.AccessToken, nil
+}
 
-	totp, err := z.Authenticator.GetTOTP()
-	if err != nil {
-		return "", err
-	}
-	totpField, err := webDriver.FindElement(selenium.ByID, "totp")
-	if err != nil {
-		return "", err
-	}
-	totpField.SendKeys(totp)
-	submitButton, err := webDriver.FindElement(selenium.ByCSSSelector, "button[type=submit]")
-	if err != nil {
-		return "", err
+// newKiteClient gives a kite connect client of the app
+func (z *ZerodhaBroker) newKiteClient() *kiteconnect.Client {
+	kc := kiteconnect.New(z.APIKey)
+	if len(z.Login.APIURL) > 0 {
+		kc.SetBaseURI(z.Login.APIURL)
 	}
-	submitButton.Click()
-	time.Sleep(1 * time.Second)
 
-	webDriver.Get(kc.GetLoginURL())
-	time.Sleep(1 * time.Second)
+	return kc
+}
 
-	authorizedURLString, err := webDriver.CurrentURL()
-	if err != nil {
-		return "", err
-	}
-	authorizedURL, err := url.Parse(authorizedURLString)
-	if err != nil {
-		return "", err
-	}
-	requestTokenArray, ok := authorizedURL.Query()["request_token"]
-	if !ok || len(requestTokenArray[0]) < 1 {
-		return "", errors.New("access token is missing")
-	}
-	requestToken := requestTokenArray[0]
+func (z *ZerodhaBroker) GetSymbolMapper() symbol.Mapper {
+	return z.Symbols
+}
 
-	data, err := kc.GenerateSession(requestToken, z.APISecret)
+func (z *ZerodhaBroker) IsMarketOpen() (bool, error) {
+	nifty := symbol.NewIndex("NIFTY")
+	tradingSymbol, err := z.Symbols.Format(nifty)
 	if err != nil {
-		return "", err
+		return false, err
 	}
+	quoteKey := fmt.Sprintf("%s:%s", nifty.Exchange(), tradingSymbol)
 
-	return data.AccessToken, nil
-}
-
-func (z *ZerodhaBroker) IsMarketOpen() (bool, error) {
 	open := false
-	err := retry.Do(
+	err = retry.Do(
 		func() error {
-			oldQuote, err := z.Client.GetQuote("NSE:NIFTY 50")
+			oldQuote, err := z.Client.GetQuote(quoteKey)
 			if err != nil {
 				return err
 			}
-			oldPrice := oldQuote["NSE:NIFTY 50"].LastPrice
+			oldPrice := oldQuote[quoteKey].LastPrice
 
 			for i := 0; i < 5; i++ {
 				time.Sleep(1 * time.Second)
-				newQuote, err := z.Client.GetQuote("NSE:NIFTY 50")
+				newQuote, err := z.Client.GetQuote(quoteKey)
 				if err != nil {
 					return err
 				}
-				newPrice := newQuote["NSE:NIFTY 50"].LastPrice
+				newPrice := newQuote[quoteKey].LastPrice
 				diff := math.Abs(float64(newPrice - oldPrice))
 				delta := (diff / float64(oldPrice)) * 100
 				if delta > 0 {
@@ -213,12 +139,12 @@ func (z *ZerodhaBroker) IsMarketOpen() (bool, error) {
 }
 
 func (z *ZerodhaBroker) Authenticate() error {
-	credentials, err := z.fetchAccessToken()
+	credentials, err := z.Credentials.Get()
 	if err != nil {
 		return err
 	}
 
-	kc := kiteconnect.New(z.APIKey)
+	kc := z.newKiteClient()
 	if err := z.checkConnection(credentials); err != nil {
 		err = retry.Do(
 			func() error {
@@ -242,7 +168,7 @@ func (z *ZerodhaBroker) Authenticate() error {
 	}
 
 	kc.SetAccessToken(credentials.AccessToken)
-	err = z.Database.UpdateCollection(z.Filter, credentials, "credentials")
+	err = z.Credentials.Save(credentials)
 	if err != nil {
 		return err
 	}
@@ -272,69 +198,96 @@ func (z *ZerodhaBroker) GetLTP(symbol string) (float64, error) {
 	return -1, nil
 }
 
-func (z *ZerodhaBroker) GetInstruments(exchange string) (models.Positions, error) {
-	var resultInstruments models.Positions
+func (z *ZerodhaBroker) GetInstruments(exchange models.Exchange) (models.Instruments, error) {
+	var resultInstruments models.Instruments
 	var instruments kiteconnect.Instruments
 	var err error
 
 	if len(exchange) < 1 {
 		instruments, err = z.Client.GetInstruments()
 		if err != nil {
-			return models.Positions{}, err
+			return models.Instruments{}, err
 		}
 	} else {
-		instruments, err = z.Client.GetInstrumentsByExchange(exchange)
+		instruments, err = z.Client.GetInstrumentsByExchange(string(exchange))
 		if err != nil {
-			return models.Positions{}, err
+			return models.Instruments{}, err
 		}
 	}
 	for _, instrument := range instruments {
-		resultInstrument := models.Position{
-			TradingSymbol:  instrument.Tradingsymbol,
-			Expiry:         instrument.Expiry.Time,
-			Segment:        instrument.Segment,
-			Exchange:       instrument.Exchange,
-			InstrumentType: instrument.InstrumentType,
-			StrikePrice:    instrument.StrikePrice,
-			LotSize:        int(instrument.LotSize),
-		}
-		resultInstruments = append(resultInstruments, resultInstrument)
+		resultInstruments = append(resultInstruments, models.InstrumentFromKite(instrument))
 	}
 
 	return resultInstruments, nil
 }
 
-func (z *ZerodhaBroker) GetInstrument(symbol string, exchange string) (models.Position, error) {
+func (z *ZerodhaBroker) GetInstrument(symbol string, exchange models.Exchange) (models.Instrument, error) {
 	var instruments kiteconnect.Instruments
 	var err error
 
 	if len(exchange) < 1 {
 		instruments, err = z.Client.GetInstruments()
 		if err != nil {
-			return models.Position{}, err
+			return models.Instrument{}, err
 		}
 	} else {
-		instruments, err = z.Client.GetInstrumentsByExchange(exchange)
+		instruments, err = z.Client.GetInstrumentsByExchange(string(exchange))
 		if err != nil {
-			return models.Position{}, err
+			return models.Instrument{}, err
 		}
 	}
 	for _, instrument := range instruments {
 		if symbol == instrument.Tradingsymbol {
-			resultInstrument := models.Position{
-				TradingSymbol:  instrument.Tradingsymbol,
-				Expiry:         instrument.Expiry.Time,
-				Segment:        instrument.Segment,
-				Exchange:       instrument.Exchange,
-				InstrumentType: instrument.InstrumentType,
-				StrikePrice:    instrument.StrikePrice,
-				LotSize:        int(instrument.LotSize),
-			}
-			return resultInstrument, nil
+			return models.InstrumentFromKite(instrument), nil
 		}
 	}
 
-	return models.Position{}, nil
+	return models.Instrument{}, nil
+}
+
+// kiteHistoricalDays is the most days of an interval kite
+// gives in a call for historical data
+var kiteHistoricalDays = map[models.Interval]int{
+	models.IntervalMinute:        60,
+	models.IntervalThreeMinute:   100,
+	models.IntervalFiveMinute:    100,
+	models.IntervalTenMinute:     100,
+	models.IntervalFifteenMinute: 200,
+	models.IntervalThirtyMinute:  200,
+	models.IntervalHour:          400,
+	models.IntervalDay:           2000,
+}
+
+func (z *ZerodhaBroker) GetHistoricalCandles(symbol string, interval models.Interval, from, to time.Time) (models.Candles, error) {
+	days, ok := kiteHistoricalDays[interval]
+	if !ok {
+		return nil, fmt.Errorf("candle interval %s is not supported on kite", interval)
+	}
+	parsed, err := z.Symbols.Parse(symbol)
+	if err != nil {
+		return nil, err
+	}
+	instrument, err := z.GetInstrument(symbol, parsed.Exchange())
+	if err != nil {
+		return nil, err
+	}
+	if instrument.InstrumentToken == 0 {
+		return nil, fmt.Errorf("instrument %s is not found on kite", symbol)
+	}
+
+	return z.Candles.GetHistoricalCandles(symbol, interval, from, to, func(from, to time.Time) (models.Candles, error) {
+		candles := models.Candles{}
+		for _, r := range splitRange(from, to, days) {
+			data, err := z.Client.GetHistoricalData(instrument.InstrumentToken, string(interval), r[0], r[1], false, true)
+			if err != nil {
+				return nil, err
+			}
+			for _, d := range data {
+				candles = append(candles, models.CandleFromKite(d))
+			}
+		}
+		return candles, nil
+	})
 }
 
 func (z *ZerodhaBroker) GetPositions() (models.Positions, error) {
@@ -345,16 +298,7 @@ func (z *ZerodhaBroker) GetPositions() (models.Positions, error) {
 		return models.Positions{}, err
 	}
 	for _, position := range positions.Net {
-		resultPositon := models.Position{
-			TradingSymbol: position.Tradingsymbol,
-			Exchange:      position.Exchange,
-			Product:       position.Product,
-			AveragePrice:  position.AveragePrice,
-			Value:         position.Value,
-			BuyPrice:      position.BuyPrice,
-			SellPrice:     position.SellPrice,
-		}
-		resultPositions = append(resultPositions, resultPositon)
+		resultPositions = append(resultPositions, models.PositionFromKite(position))
 	}
 
 	return resultPositions, nil
@@ -413,149 +357,212 @@ func (z *ZerodhaBroker) GetLTPNoFreak(symbol string) (float64, error) {
 	return oldPrice, nil
 }
 
-func (z *ZerodhaBroker) PlaceOrder(position *models.Position) error {
-	var err error
+func (z *ZerodhaBroker) PlaceOrder(order *models.Order) error {
+	return z.placeOrderContext(context.Background(), order)
+}
+
+// placeOrderContext places the order like PlaceOrder but
+// stops retrying once the context is done
+func (z *ZerodhaBroker) placeOrderContext(ctx context.Context, order *models.Order) error {
+	sliced, err := needsSlicing(order)
+	if err != nil {
+		return err
+	}
+	if sliced {
+		return z.placeSlicedOrder(ctx, order)
+	}
 
 	err = retry.Do(
 		func() error {
-			if position.OrderType == kiteconnect.OrderTypeLimit {
-				position.Price, err = z.GetLTPNoFreak(position.TradingSymbol)
-				if err != nil {
-					return err
-				}
-				if position.TransactionType == kiteconnect.TransactionTypeBuy {
-					position.Price = position.Price + 1
-				}
-				if position.TransactionType == kiteconnect.TransactionTypeSell {
-					position.Price = position.Price - 1
-					if position.Price < 0 {
-						position.Price = position.Price + 1
-					}
-				}
-			}
-			err = z.placeOrder(position)
-			if err != nil {
-				return err
+			if order.OrderType == models.OrderTypeLimit {
+				return z.chaseLimitOrder(ctx, order)
 			}
-
-			return nil
+			return z.placeOrder(ctx, order)
 		},
 		retry.OnRetry(func(_ uint, err error) {
-			log.Println(fmt.Sprintf("%s %v because %s", "Retrying placing position", position, err))
+			log.Println(fmt.Sprintf("%s %v because %s", "Retrying placing order", order, err))
 		}),
 		retry.Delay(5*time.Second),
 		retry.Attempts(5),
+		retry.Context(ctx),
 	)
 	if err != nil {
+		if len(order.Slices) > 0 && order.Status == models.StatusCancelled {
+			log.Printf("Placing remaining %d quantity of %s after partial fill", order.Quantity-order.FilledQuantity, order.TradingSymbol)
+			return z.placeSlicedOrder(ctx, order)
+		}
 		return err
 	}
 
 	return nil
 }
 
-func (z *ZerodhaBroker) placeOrder(position *models.Position) error {
-	var err error
+// chaseLimitOrder places a limit order at the configured starting
+// price and steps it towards the market every interval until it is
+// filled or the slippage cap from the initial LTP is reached. The
+// initial LTP is kept on the order so retries chase to the same cap
+// and an order which reaches the cap is cancelled and not retried.
+func (z *ZerodhaBroker) chaseLimitOrder(ctx context.Context, order *models.Order) error {
+	if len(order.OrderID) > 0 {
+		latest, found, err := z.getOrder(order.OrderID)
+		if err != nil {
+			return err
+		}
+		if found {
+			done, err := updateOrderStatus(order, latest)
+			if done || err != nil {
+				return err
+			}
+		}
+	}
 
-	orderParams := kiteconnect.OrderParams{
-		Exchange:        position.Exchange,
-		Tradingsymbol:   position.TradingSymbol,
-		Product:         position.Product,
-		OrderType:       position.OrderType,
-		TransactionType: position.TransactionType,
-		Quantity:        position.Quantity,
+	if order.ReferencePrice <= 0 {
+		ltp, err := z.GetLTPNoFreak(order.TradingSymbol)
+		if err != nil {
+			return err
+		}
+		order.ReferencePrice = ltp
+	}
+	ltp := order.ReferencePrice
+
+	quoteKey := fmt.Sprintf("%s:%s", order.Exchange, order.TradingSymbol)
+	quote, err := z.Client.GetQuote(quoteKey)
+	if err != nil {
+		return err
 	}
+	bid := quote[quoteKey].Depth.Buy[0].Price
+	ask := quote[quoteKey].Depth.Sell[0].Price
 
-	if position.OrderType == kiteconnect.OrderTypeLimit {
-		orderParams.Price = position.Price
+	if order.TickSize <= 0 {
+		instrument, err := z.GetInstrument(order.TradingSymbol, order.Exchange)
+		if err != nil {
+			return err
+		}
+		order.TickSize = instrument.TickSize
 	}
 
-	if position.OrderType == kiteconnect.OrderTypeSL {
-		orderParams.TriggerPrice = position.TriggerPrice
-		orderParams.Price = position.Price
+	limitPrice := z.Execution.LimitPrice(ltp, order.TickSize, order.TransactionType)
+	order.Price = z.Execution.InitialPrice(ltp, bid, ask, order.TickSize, order.TransactionType)
+	if order.TransactionType == models.TransactionTypeBuy {
+		order.Price = math.Min(order.Price, limitPrice)
+	} else {
+		order.Price = math.Max(order.Price, limitPrice)
 	}
 
-	if len(position.OrderID) <= 0 {
-		orderResponse, err := z.Client.PlaceOrder(kiteconnect.VarietyRegular, orderParams)
-		if err == nil {
-			position.OrderID = orderResponse.OrderID
-		} else {
-			if strings.Contains(err.Error(), "Order request timed out") {
-				log.Printf("Order timed out for %s", position.TradingSymbol)
-				orderID, err := z.GetOrderID(*position)
-				if err != nil {
-					return fmt.Errorf("could not rectify order request timed out for %s because %s", position.TradingSymbol, err)
-				}
-				position.OrderID = orderID
-			} else {
-				return err
-			}
+	for step := 0; step <= z.Execution.MaxSteps; step++ {
+		if step > 0 {
+			order.Price = z.Execution.NextPrice(order.Price, limitPrice, order.TickSize, order.TransactionType)
 		}
 
-		if position.OrderType == kiteconnect.OrderTypeLimit {
-			time.Sleep(10 * time.Second)
+		if len(order.OrderID) <= 0 {
+			err = z.submitOrder(ctx, order)
 		} else {
-			time.Sleep(1 * time.Second)
+			err = z.modifyOrder(ctx, order)
 		}
-	} else {
-		orders, err := z.Client.GetOrders()
 		if err != nil {
 			return err
 		}
 
-		for _, order := range orders {
-			if order.OrderID == position.OrderID {
-				if order.Status == kiteconnect.OrderStatusComplete {
-					position.Status = kiteconnect.OrderStatusComplete
-					position.AveragePrice = order.AveragePrice
-					return nil
-				}
-				if order.Status == kiteconnect.OrderStatusRejected {
-					position.Status = kiteconnect.OrderStatusRejected
-					position.OrderID = ""
-					return errors.New("order is rejected")
-				}
-			}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(z.Execution.StepInterval):
 		}
 
-		if position.OrderType == kiteconnect.OrderTypeLimit {
-			_, err = z.Client.ModifyOrder(kiteconnect.VarietyRegular, position.OrderID, orderParams)
-			if err != nil {
+		latest, found, err := z.getOrder(order.OrderID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("could not find order %s of %s", order.OrderID, order.TradingSymbol)
+		}
+		done, err := updateOrderStatus(order, latest)
+		if err != nil {
+			return err
+		}
+		if done {
+			order.Slippage = Slippage(ltp, order.AveragePrice, order.TransactionType)
+			log.Printf("Filled %s at %f with slippage %f from LTP %f", order.TradingSymbol, order.AveragePrice, order.Slippage, ltp)
+			return nil
+		}
+	}
+
+	filled, err := z.cancelChasedOrder(order)
+	if err != nil {
+		return retry.Unrecoverable(fmt.Errorf("limit order of %s reached slippage cap of %f and could not be cancelled because %s", order.TradingSymbol, limitPrice, err))
+	}
+	if filled {
+		order.Slippage = Slippage(ltp, order.AveragePrice, order.TransactionType)
+		log.Printf("Filled %s at %f with slippage %f from LTP %f", order.TradingSymbol, order.AveragePrice, order.Slippage, ltp)
+		return nil
+	}
+
+	return retry.Unrecoverable(fmt.Errorf("limit order of %s not filled within slippage cap of %f, filled %d of %d quantity", order.TradingSymbol, limitPrice, order.FilledQuantity, order.Quantity))
+}
+
+// cancelChasedOrder cancels the limit order which reached the slippage
+// cap and records its fills. It tells whether the order got completely
+// filled before it could be cancelled.
+func (z *ZerodhaBroker) cancelChasedOrder(order *models.Order) (bool, error) {
+	err := retry.Do(
+		func() error {
+			return z.cancelOpenOrder(order)
+		},
+		retry.OnRetry(func(_ uint, err error) {
+			log.Println(fmt.Sprintf("%s %v because %s", "Retrying cancelling chased order", order, err))
+		}),
+		retry.Delay(1*time.Second),
+		retry.Attempts(5),
+	)
+	if err != nil {
+		return false, err
+	}
+
+	return order.Status == models.StatusComplete, nil
+}
+
+func (z *ZerodhaBroker) placeOrder(ctx context.Context, order *models.Order) error {
+	var err error
+
+	if len(order.OrderID) <= 0 {
+		err = z.submitOrder(ctx, order)
+		if err != nil {
+			return err
+		}
+		time.Sleep(1 * time.Second)
+	} else {
+		latest, found, err := z.getOrder(order.OrderID)
+		if err != nil {
+			return err
+		}
+		if found {
+			done, err := updateOrderStatus(order, latest)
+			if done || err != nil {
 				return err
 			}
-			time.Sleep(10 * time.Second)
 		}
 	}
 
-	orders, err := z.Client.GetOrders()
+	latest, found, err := z.getOrder(order.OrderID)
 	if err != nil {
 		return err
 	}
-	for _, order := range orders {
-		if order.OrderID == position.OrderID {
-			if position.OrderType == kiteconnect.OrderTypeSL {
-				if order.Status == "TRIGGER PENDING" {
-					position.Status = order.Status
-				} else {
-					return fmt.Errorf("order failed with status %s and message %s", order.Status, order.StatusMessage)
-				}
+	if found {
+		if order.OrderType == models.OrderTypeSL {
+			if latest.Status == models.StatusTriggerPending {
+				order.Status = latest.Status
+			} else {
+				return fmt.Errorf("order failed with status %s and message %s", latest.Status, latest.StatusMessage)
 			}
+		}
 
-			if position.OrderType == kiteconnect.OrderTypeMarket {
-				if order.Status == kiteconnect.OrderStatusComplete {
-					position.AveragePrice = order.AveragePrice
-					position.Status = order.Status
-				} else {
-					return fmt.Errorf("order failed with status %s and message %s", order.Status, order.StatusMessage)
-				}
+		if order.OrderType == models.OrderTypeMarket {
+			done, err := updateOrderStatus(order, latest)
+			if err != nil {
+				return err
 			}
-
-			if position.OrderType == kiteconnect.OrderTypeLimit {
-				if order.Status == kiteconnect.OrderStatusComplete {
-					position.AveragePrice = order.AveragePrice
-					position.Status = order.Status
-				} else {
-					return fmt.Errorf("order failed with status %s and message %s", order.Status, order.StatusMessage)
-				}
+			if !done {
+				return fmt.Errorf("order failed with status %s, filled %d of %d and message %s", latest.Status, order.FilledQuantity, order.Quantity, latest.StatusMessage)
 			}
 		}
 	}
@@ -563,46 +570,156 @@ func (z *ZerodhaBroker) placeOrder(position *models.Position) error {
 	return nil
 }
 
-func (z *ZerodhaBroker) GetOrders() (models.Positions, error) {
-	var positions models.Positions
-	orders, err := z.Client.GetOrders()
+// submitOrder sends a new order and rectifies
+// the order id if the request timed out
+func (z *ZerodhaBroker) submitOrder(ctx context.Context, order *models.Order) error {
+	if len(order.Tag) > 0 {
+		existing, found, err := z.getOrderByTag(*order)
+		if err != nil {
+			return err
+		}
+		if found {
+			log.Printf("Found existing order %s with tag %s for %s", existing.OrderID, order.Tag, order.TradingSymbol)
+			order.OrderID = existing.OrderID
+			return nil
+		}
+	}
+
+	err := z.Throttle.Wait(ctx)
 	if err != nil {
-		return models.Positions{}, err
+		return err
+	}
+	orderResponse, err := z.Client.PlaceOrder(kiteconnect.VarietyRegular, z.orderParams(order))
+	if err == nil {
+		order.OrderID = orderResponse.OrderID
+		return nil
+	}
+	if !strings.Contains(err.Error(), "Order request timed out") {
+		return err
 	}
 
+	log.Printf("Order timed out for %s", order.TradingSymbol)
+	orderID, err := z.GetOrderID(*order)
+	if err != nil {
+		return fmt.Errorf("could not rectify order request timed out for %s because %s", order.TradingSymbol, err)
+	}
+	order.OrderID = orderID
+
+	return nil
+}
+
+// modifyOrder sends the latest price of the order
+func (z *ZerodhaBroker) modifyOrder(ctx context.Context, order *models.Order) error {
+	err := z.Throttle.Wait(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = z.Client.ModifyOrder(kiteconnect.VarietyRegular, order.OrderID, z.orderParams(order))
+
+	return err
+}
+
+// orderParams builds the kite order params of the order. Quantity
+// is always the total quantity of the order as kite keeps the filled
+// part of a partially filled order on modify.
+func (z *ZerodhaBroker) orderParams(order *models.Order) kiteconnect.OrderParams {
+	orderParams := kiteconnect.OrderParams{
+		Exchange:        string(order.Exchange),
+		Tradingsymbol:   order.TradingSymbol,
+		Product:         string(order.Product),
+		OrderType:       string(order.OrderType),
+		TransactionType: string(order.TransactionType),
+		Validity:        string(order.Validity),
+		Quantity:        order.Quantity,
+		Tag:             order.Tag,
+	}
+
+	if order.OrderType == models.OrderTypeLimit {
+		orderParams.Price = order.Price
+	}
+
+	if order.OrderType == models.OrderTypeSL {
+		orderParams.TriggerPrice = order.TriggerPrice
+		orderParams.Price = order.Price
+	}
+
+	return orderParams
+}
+
+func (z *ZerodhaBroker) getOrder(orderID string) (models.Order, bool, error) {
+	orders, err := z.Client.GetOrders()
+	if err != nil {
+		return models.Order{}, false, err
+	}
 	for _, order := range orders {
-		position := models.Position{
-			OrderID: order.OrderID,
-			Status:  order.Status,
+		if order.OrderID == orderID {
+			return models.OrderFromKite(order), true, nil
 		}
-		positions = append(positions, position)
 	}
 
-	return positions, nil
+	return models.Order{}, false, nil
+}
+
+func (z *ZerodhaBroker) GetOrders() (models.Orders, error) {
+	var resultOrders models.Orders
+	orders, err := z.Client.GetOrders()
+	if err != nil {
+		return models.Orders{}, err
+	}
+
+	for _, order := range orders {
+		resultOrders = append(resultOrders, models.OrderFromKite(order))
+	}
+
+	return resultOrders, nil
+}
+
+func (z *ZerodhaBroker) GetTrades() (models.Trades, error) {
+	var resultTrades models.Trades
+	trades, err := z.Client.GetTrades()
+	if err != nil {
+		return models.Trades{}, err
+	}
+
+	for _, trade := range trades {
+		resultTrades = append(resultTrades, models.TradeFromKite(trade))
+	}
+
+	return resultTrades, nil
 }
 
-func (z *ZerodhaBroker) GetOrderID(position models.Position) (string, error) {
+func (z *ZerodhaBroker) GetOrderID(order models.Order) (string, error) {
 	orderID := ""
 	err := retry.Do(
 		func() error {
-			orders, err := z.Client.GetOrders()
+			orders, err := z.GetOrders()
 			if err != nil {
 				return err
 			}
-			for _, order := range orders {
-				if order.Exchange == position.Exchange && order.TradingSymbol == position.TradingSymbol && order.Product == position.Product && order.OrderType == position.OrderType && order.TransactionType == position.TransactionType && order.Quantity == float64(position.Quantity) {
-					orderID = order.OrderID
+			for _, existing := range orders {
+				if len(order.Tag) > 0 {
+					live, err := isLiveOrderWithTag(existing, order)
+					if err != nil {
+						return err
+					}
+					if live {
+						orderID = existing.OrderID
+					}
+					continue
+				}
+				if existing.Exchange == order.Exchange && existing.TradingSymbol == order.TradingSymbol && existing.Product == order.Product && existing.OrderType == order.OrderType && existing.TransactionType == order.TransactionType && existing.Quantity == order.Quantity {
+					orderID = existing.OrderID
 					break
 				}
 			}
 			if orderID == "" {
-				return fmt.Errorf("couldn't find order of %s which failed due to order timed out", position.TradingSymbol)
+				return fmt.Errorf("couldn't find order of %s which failed due to order timed out", order.TradingSymbol)
 			}
 
 			return nil
 		},
 		retry.OnRetry(func(_ uint, err error) {
-			log.Println(fmt.Sprintf("%s %v because %s", "Retrying getting order id of", position, err))
+			log.Println(fmt.Sprintf("%s %v because %s", "Retrying getting order id of", order, err))
 		}),
 		retry.Delay(5*time.Second),
 		retry.Attempts(5),
@@ -614,43 +731,52 @@ func (z *ZerodhaBroker) GetOrderID(position models.Position) (string, error) {
 	return orderID, nil
 }
 
-func (z *ZerodhaBroker) CancelOrder(position *models.Position) error {
-	orders, err := z.Client.GetOrders()
+func (z *ZerodhaBroker) CancelOrder(order *models.Order) error {
+	if len(order.Slices) > 0 {
+		for i := range order.Slices {
+			err := z.CancelOrder(&order.Slices[i])
+			if err != nil {
+				return err
+			}
+		}
+		aggregateSlices(order)
+		return nil
+	}
+
+	latest, found, err := z.getOrder(order.OrderID)
 	if err != nil {
 		return err
 	}
-	for _, order := range orders {
-		if order.OrderID == position.OrderID {
-			if order.Status == kiteconnect.OrderStatusComplete {
-				position.Status = kiteconnect.OrderStatusComplete
-			} else if order.Status == kiteconnect.OrderStatusCancelled {
-				position.Status = kiteconnect.OrderStatusCancelled
-			} else if order.Status == "TRIGGER PENDING" {
-				_, err := z.Client.CancelOrder(kiteconnect.VarietyRegular, position.OrderID, nil)
-				if err != nil {
-					return err
-				}
-			} else {
-				return fmt.Errorf("order failed with status %s and message %s", order.Status, order.StatusMessage)
+	if found {
+		if latest.Status == models.StatusComplete {
+			order.Status = models.StatusComplete
+		} else if latest.Status == models.StatusCancelled {
+			order.Status = models.StatusCancelled
+		} else if latest.Status == models.StatusTriggerPending {
+			_, err := z.Client.CancelOrder(kiteconnect.VarietyRegular, order.OrderID, nil)
+			if err != nil {
+				return err
 			}
+		} else {
+			return fmt.Errorf("order failed with status %s and message %s", latest.Status, latest.StatusMessage)
 		}
 	}
 
 	return nil
 }
 
-func (z *ZerodhaBroker) CancelOrders(positions models.RefPositions) error {
-	for _, position := range positions {
+func (z *ZerodhaBroker) CancelOrders(orders models.RefOrders) error {
+	for _, order := range orders {
 		err := retry.Do(
 			func() error {
-				err := z.CancelOrder(position)
+				err := z.CancelOrder(order)
 				if err != nil {
 					return err
 				}
 				return nil
 			},
 			retry.OnRetry(func(_ uint, err error) {
-				log.Println(fmt.Sprintf("%s %v because %s", "Retrying cancelling order ", position, err))
+				log.Println(fmt.Sprintf("%s %v because %s", "Retrying cancelling order ", order, err))
 			}),
 			retry.Delay(5*time.Second),
 			retry.Attempts(5),
@@ -663,10 +789,37 @@ func (z *ZerodhaBroker) CancelOrders(positions models.RefPositions) error {
 	return nil
 }
 
-func (z *ZerodhaBroker) GetMargin() {
+func (z *ZerodhaBroker) GetMargin() (float64, error) {
 	allMargins, err := z.Client.GetUserMargins()
 	if err != nil {
-		return
+		return 0, err
+	}
+
+	return allMargins.Equity.Net, nil
+}
+
+// GetBasketMargin prices the orders at market as the
+// limit prices are only known when they are placed
+func (z *ZerodhaBroker) GetBasketMargin(orders models.Orders) (float64, error) {
+	params := []kiteconnect.OrderMarginParam{}
+	for _, order := range orders {
+		params = append(params, kiteconnect.OrderMarginParam{
+			Exchange:        string(order.Exchange),
+			Tradingsymbol:   order.TradingSymbol,
+			TransactionType: string(order.TransactionType),
+			Variety:         kiteconnect.VarietyRegular,
+			Product:         string(order.Product),
+			OrderType:       string(models.OrderTypeMarket),
+			Quantity:        float64(order.Quantity),
+		})
+	}
+	margins, err := z.Client.GetBasketMargins(kiteconnect.GetBasketParams{
+		OrderParams: params,
+		Compact:     true,
+	})
+	if err != nil {
+		return 0, err
 	}
-	fmt.Println(allMargins)
+
+	return margins.Final.Total, nil
 }
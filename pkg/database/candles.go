@@ -0,0 +1,73 @@
+package database
+
+import (
+	"github.com/rohitsakala/strategies/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	CandleCollection = "candles"
+)
+
+// CandleDay are the candles of a symbol on a day. A day
+// without candles, like a holiday, is kept as well so it
+// isn't downloaded again.
+type CandleDay struct {
+	Symbol   string          `bson:"symbol"`
+	Interval models.Interval `bson:"interval"`
+	Day      string          `bson:"day"`
+	Candles  models.Candles  `bson:"candles"`
+}
+
+// CandleRepo caches the candles downloaded from the
+// brokers by the day
+type CandleRepo struct {
+	Database Database
+}
+
+func NewCandleRepo(database Database) (CandleRepo, error) {
+	err := database.CreateCollection(CandleCollection)
+	if err != nil {
+		return CandleRepo{}, err
+	}
+	err = database.CreateIndex(CandleCollection, []string{"symbol", "interval", "day"}, true)
+	if err != nil {
+		return CandleRepo{}, err
+	}
+
+	return CandleRepo{
+		Database: database,
+	}, nil
+}
+
+// Get gives the candles of the day, e.g. 2024-01-25,
+// and tells whether the day is cached
+func (r *CandleRepo) Get(symbol string, interval models.Interval, day string) (models.Candles, bool, error) {
+	document, err := r.Database.GetCollection(bson.D{{Key: "symbol", Value: symbol}, {Key: "interval", Value: string(interval)}, {Key: "day", Value: day}}, CandleCollection)
+	if err != nil {
+		return nil, false, err
+	}
+	if document == nil {
+		return nil, false, nil
+	}
+	var candleDay CandleDay
+	err = fromDocument(document, &candleDay)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return candleDay.Candles, true, nil
+}
+
+func (r *CandleRepo) Save(symbol string, interval models.Interval, day string, candles models.Candles) error {
+	if candles == nil {
+		candles = models.Candles{}
+	}
+
+	return r.Database.UpsertCollection(bson.M{"symbol": symbol, "interval": string(interval), "day": day}, CandleDay{
+		Symbol:   symbol,
+		Interval: interval,
+		Day:      day,
+		Candles:  candles,
+	}, CandleCollection)
+}
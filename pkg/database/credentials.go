@@ -0,0 +1,120 @@
+package database
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/secret"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	CredentialsCollection = "credentials"
+)
+
+// CredentialsRepo keeps the credentials of the broker account. The
+// access token is encrypted at rest when there is a Cipher.
+type CredentialsRepo struct {
+	Database Database
+	Cipher   *secret.Cipher
+	// Account is the name of the account and the
+	// default account is the one without a name
+	Account string
+}
+
+func NewCredentialsRepo(database Database, cipher *secret.Cipher, account string) (CredentialsRepo, error) {
+	err := database.CreateCollection(CredentialsCollection)
+	if err != nil {
+		return CredentialsRepo{}, err
+	}
+
+	repo := CredentialsRepo{
+		Database: database,
+		Cipher:   cipher,
+		Account:  account,
+	}
+	err = repo.migrate()
+	if err != nil {
+		return CredentialsRepo{}, fmt.Errorf("could not migrate the credentials of the default account because %s", err)
+	}
+
+	return repo, nil
+}
+
+// Get gives empty credentials when none are saved yet
+func (r *CredentialsRepo) Get() (models.Credentials, error) {
+	var credentials models.Credentials
+
+	filter := bson.D{}
+	for key, value := range r.filter() {
+		filter = append(filter, bson.E{Key: key, Value: value})
+	}
+	document, err := r.Database.GetCollection(filter, CredentialsCollection)
+	if err != nil {
+		return models.Credentials{}, err
+	}
+	err = fromDocument(document, &credentials)
+	if err != nil {
+		return models.Credentials{}, err
+	}
+	if r.Cipher != nil {
+		credentials.AccessToken, err = r.Cipher.DecryptString(credentials.AccessToken)
+		if err != nil {
+			return models.Credentials{}, err
+		}
+	}
+
+	return credentials, nil
+}
+
+func (r *CredentialsRepo) Save(credentials models.Credentials) error {
+	if r.Cipher != nil && len(credentials.AccessToken) > 0 {
+		accessToken, err := r.Cipher.EncryptString(credentials.AccessToken)
+		if err != nil {
+			return err
+		}
+		credentials.AccessToken = accessToken
+	}
+
+	return r.Database.UpsertCollection(r.filter(), credentials, CredentialsCollection)
+}
+
+// filter matches the credentials of the account only, the
+// default account has its empty name saved as well
+func (r *CredentialsRepo) filter() bson.M {
+	return bson.M{"account": r.Account}
+}
+
+// migrate gives the credentials saved before there were
+// accounts, which have no account at all, to the default
+// account unless it has credentials of its own already
+func (r *CredentialsRepo) migrate() error {
+	if len(r.Account) > 0 {
+		return nil
+	}
+
+	documents, err := r.Database.GetCollections(bson.D{}, CredentialsCollection)
+	if err != nil {
+		return err
+	}
+	var unscoped bson.M
+	for _, document := range documents {
+		account, ok := document["account"]
+		if !ok {
+			if unscoped == nil {
+				unscoped = document
+			}
+			continue
+		}
+		if account == r.Account {
+			return nil
+		}
+	}
+	if unscoped == nil {
+		return nil
+	}
+	log.Println("Moving the credentials saved before there were accounts to the default account")
+
+	return r.Database.UpdateCollection(bson.M{"_id": unscoped["_id"]}, bson.M{"account": r.Account}, CredentialsCollection)
+}
@@ -0,0 +1,29 @@
+package database
+
+import (
+	"os"
+)
+
+const (
+	DefaultFilePath = "strategies.json"
+)
+
+func GetDatabase(name string) Database {
+	switch name {
+	case "mongo":
+		mongoDatabase := NewMongoDatabase(os.Getenv("DATABASE_NAME"))
+		return &mongoDatabase
+	case "memory":
+		memoryDatabase := NewMemoryDatabase()
+		return &memoryDatabase
+	case "file":
+		path := os.Getenv("DATABASE_FILE")
+		if path == "" {
+			path = DefaultFilePath
+		}
+		fileDatabase := NewFileDatabase(path)
+		return &fileDatabase
+	}
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package database
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var _ Database = &FileDatabase{}
+
+// FileDatabase is a MemoryDatabase which loads the collections
+// from a JSON file on connecting and saves them on every change.
+// The file is in mongo extended JSON so types like ids and
+// dates survive a restart.
+type FileDatabase struct {
+	MemoryDatabase
+	Path string
+}
+
+func NewFileDatabase(path string) FileDatabase {
+	return FileDatabase{
+		MemoryDatabase: NewMemoryDatabase(),
+		Path:           path,
+	}
+}
+
+func (d *FileDatabase) Connect() error {
+	err := d.MemoryDatabase.Connect()
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(d.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(data) <= 0 {
+		return nil
+	}
+
+	var stored bson.M
+	err = bson.UnmarshalExtJSON(data, true, &stored)
+	if err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for name, value := range stored {
+		documents := []bson.M{}
+		if array, ok := value.(primitive.A); ok {
+			for _, document := range array {
+				if document, ok := document.(bson.M); ok {
+					documents = append(documents, document)
+				}
+			}
+		}
+		d.collections[name] = documents
+	}
+
+	return nil
+}
+
+func (d *FileDatabase) Disconnect() error {
+	return d.save()
+}
+
+func (d *FileDatabase) CreateCollection(name string) error {
+	err := d.MemoryDatabase.CreateCollection(name)
+	if err != nil {
+		return err
+	}
+
+	return d.save()
+}
+
+func (d *FileDatabase) InsertCollection(data interface{}, name string) (string, error) {
+	id, err := d.MemoryDatabase.InsertCollection(data, name)
+	if err != nil {
+		return "", err
+	}
+
+	return id, d.save()
+}
+
+func (d *FileDatabase) UpdateCollection(filter bson.M, data interface{}, name string) error {
+	err := d.MemoryDatabase.UpdateCollection(filter, data, name)
+	if err != nil {
+		return err
+	}
+
+	return d.save()
+}
+
+func (d *FileDatabase) UpsertCollection(filter bson.M, data interface{}, name string) error {
+	err := d.MemoryDatabase.UpsertCollection(filter, data, name)
+	if err != nil {
+		return err
+	}
+
+	return d.save()
+}
+
+func (d *FileDatabase) DeleteCollection(filter bson.M, name string) error {
+	err := d.MemoryDatabase.DeleteCollection(filter, name)
+	if err != nil {
+		return err
+	}
+
+	return d.save()
+}
+
+func (d *FileDatabase) WithTransaction(transaction func(database Database) error) error {
+	err := d.runTransaction(d, transaction)
+	if err != nil {
+		saveErr := d.save()
+		if saveErr != nil {
+			return saveErr
+		}
+		return err
+	}
+
+	return nil
+}
+
+// save writes the collections to a temporary file first so a
+// crash while writing doesn't leave a broken file behind
+func (d *FileDatabase) save() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	stored := bson.M{}
+	for name, documents := range d.collections {
+		array := primitive.A{}
+		for _, document := range documents {
+			array = append(array, document)
+		}
+		stored[name] = array
+	}
+	data, err := bson.MarshalExtJSON(stored, true, false)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(d.Path)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+	temp, err := ioutil.TempFile(dir, filepath.Base(d.Path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	err = temp.Close()
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	return os.Rename(temp.Name(), d.Path)
+}
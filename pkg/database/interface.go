@@ -5,14 +5,25 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+const (
+	DefaultDatabaseName = "strategies"
+)
+
 type Database interface {
 	Connect() error
 	Disconnect() error
 
 	// Collections
 	CreateCollection(name string) error
+	CreateIndex(name string, keys []string, unique bool) error
 	GetCollection(filter primitive.D, name string) (bson.M, error)
+	GetCollections(filter primitive.D, name string) ([]bson.M, error)
 	InsertCollection(data interface{}, name string) (string, error)
 	UpdateCollection(filter bson.M, data interface{}, name string) error
+	UpsertCollection(filter bson.M, data interface{}, name string) error
 	DeleteCollection(filter bson.M, name string) error
+
+	// WithTransaction runs the transaction against the database
+	// given to it and undoes all its changes if it fails
+	WithTransaction(transaction func(database Database) error) error
 }
@@ -0,0 +1,410 @@
+package database
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var _ Database = &MemoryDatabase{}
+
+type memoryIndex struct {
+	keys   []string
+	unique bool
+}
+
+// MemoryDatabase keeps the collections in memory for tests and
+// paper trading. Filters match documents on equality of their
+// fields like mongo does, with dots reaching into sub documents.
+type MemoryDatabase struct {
+	collections map[string][]bson.M
+	indexes     map[string][]memoryIndex
+	mutex       *sync.Mutex
+	// transactionMutex runs one transaction at a time
+	transactionMutex *sync.Mutex
+}
+
+func NewMemoryDatabase() MemoryDatabase {
+	return MemoryDatabase{
+		collections:      map[string][]bson.M{},
+		indexes:          map[string][]memoryIndex{},
+		mutex:            &sync.Mutex{},
+		transactionMutex: &sync.Mutex{},
+	}
+}
+
+func (d *MemoryDatabase) Connect() error {
+	if d.mutex == nil {
+		*d = NewMemoryDatabase()
+	}
+
+	return nil
+}
+
+func (d *MemoryDatabase) Disconnect() error {
+	return nil
+}
+
+func (d *MemoryDatabase) CreateCollection(name string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.collections[name]; !ok {
+		d.collections[name] = []bson.M{}
+	}
+
+	return nil
+}
+
+// CreateIndex only enforces unique indexes as there
+// is nothing to speed up in memory
+func (d *MemoryDatabase) CreateIndex(name string, keys []string, unique bool) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	index := memoryIndex{keys: keys, unique: unique}
+	documents := d.collections[name]
+	for i := range documents {
+		if err := d.checkIndex(index, name, documents[i], i); err != nil {
+			return err
+		}
+	}
+	d.indexes[name] = append(d.indexes[name], index)
+
+	return nil
+}
+
+func (d *MemoryDatabase) GetCollection(filter primitive.D, name string) (bson.M, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	indices, err := d.find(filter.Map(), name, 1)
+	if err != nil || len(indices) <= 0 {
+		return nil, err
+	}
+
+	return copyDocument(d.collections[name][indices[0]])
+}
+
+func (d *MemoryDatabase) GetCollections(filter primitive.D, name string) ([]bson.M, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	indices, err := d.find(filter.Map(), name, -1)
+	if err != nil {
+		return nil, err
+	}
+	documents := []bson.M{}
+	for _, i := range indices {
+		document, err := copyDocument(d.collections[name][i])
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	}
+
+	return documents, nil
+}
+
+func (d *MemoryDatabase) InsertCollection(data interface{}, name string) (string, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	document, err := toDocument(data)
+	if err != nil {
+		return "", err
+	}
+
+	return d.insert(document, name)
+}
+
+// UpdateCollection sets the fields of the data on the first
+// document matching the filter
+func (d *MemoryDatabase) UpdateCollection(filter bson.M, data interface{}, name string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	indices, err := d.find(filter, name, 1)
+	if err != nil || len(indices) <= 0 {
+		return err
+	}
+
+	return d.update(indices[0], data, name)
+}
+
+// UpsertCollection updates the first document matching the filter
+// or inserts the fields of the filter along with the data
+func (d *MemoryDatabase) UpsertCollection(filter bson.M, data interface{}, name string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	indices, err := d.find(filter, name, 1)
+	if err != nil {
+		return err
+	}
+	if len(indices) > 0 {
+		return d.update(indices[0], data, name)
+	}
+
+	document, err := toDocument(filter)
+	if err != nil {
+		return err
+	}
+	fields, err := toDocument(data)
+	if err != nil {
+		return err
+	}
+	for key, value := range fields {
+		document[key] = value
+	}
+	_, err = d.insert(document, name)
+
+	return err
+}
+
+func (d *MemoryDatabase) DeleteCollection(filter bson.M, name string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	indices, err := d.find(filter, name, 1)
+	if err != nil || len(indices) <= 0 {
+		return err
+	}
+	i := indices[0]
+	documents := d.collections[name]
+	d.collections[name] = append(documents[:i], documents[i+1:]...)
+
+	return nil
+}
+
+// WithTransaction runs one transaction at a time and puts back
+// the collections as they were if the transaction fails. Changes
+// made outside of the transaction meanwhile are undone as well.
+func (d *MemoryDatabase) WithTransaction(transaction func(database Database) error) error {
+	return d.runTransaction(d, transaction)
+}
+
+func (d *MemoryDatabase) runTransaction(database Database, transaction func(database Database) error) error {
+	d.transactionMutex.Lock()
+	defer d.transactionMutex.Unlock()
+
+	snapshot, err := d.snapshot()
+	if err != nil {
+		return err
+	}
+	err = transaction(database)
+	if err != nil {
+		d.mutex.Lock()
+		d.collections = snapshot
+		d.mutex.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (d *MemoryDatabase) snapshot() (map[string][]bson.M, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	collections := map[string][]bson.M{}
+	for name, documents := range d.collections {
+		collections[name] = []bson.M{}
+		for _, document := range documents {
+			copied, err := copyDocument(document)
+			if err != nil {
+				return nil, err
+			}
+			collections[name] = append(collections[name], copied)
+		}
+	}
+
+	return collections, nil
+}
+
+func (d *MemoryDatabase) insert(document bson.M, name string) (string, error) {
+	if _, ok := document["_id"]; !ok {
+		document["_id"] = primitive.NewObjectID()
+	}
+	for _, index := range d.indexes[name] {
+		if err := d.checkIndex(index, name, document, -1); err != nil {
+			return "", err
+		}
+	}
+	d.collections[name] = append(d.collections[name], document)
+
+	if id, ok := document["_id"].(primitive.ObjectID); ok {
+		return id.String(), nil
+	}
+
+	return fmt.Sprintf("%v", document["_id"]), nil
+}
+
+func (d *MemoryDatabase) update(i int, data interface{}, name string) error {
+	fields, err := toDocument(data)
+	if err != nil {
+		return err
+	}
+	document, err := copyDocument(d.collections[name][i])
+	if err != nil {
+		return err
+	}
+	for key, value := range fields {
+		document[key] = value
+	}
+	for _, index := range d.indexes[name] {
+		if err := d.checkIndex(index, name, document, i); err != nil {
+			return err
+		}
+	}
+	d.collections[name][i] = document
+
+	return nil
+}
+
+// checkIndex fails if the document breaks the unique index
+// against the documents of the collection other than the one
+// at position self
+func (d *MemoryDatabase) checkIndex(index memoryIndex, name string, document bson.M, self int) error {
+	if !index.unique {
+		return nil
+	}
+	for i, existing := range d.collections[name] {
+		if i == self {
+			continue
+		}
+		duplicate := true
+		for _, key := range index.keys {
+			expected, _ := lookupField(document, key)
+			actual, _ := lookupField(existing, key)
+			if !equalValues(actual, expected) {
+				duplicate = false
+				break
+			}
+		}
+		if duplicate {
+			return fmt.Errorf("duplicate key error collection: %s index: %s", name, strings.Join(index.keys, "_"))
+		}
+	}
+
+	return nil
+}
+
+// find gives the indices of the documents matching the filter
+// in the collection up to the limit unless it is negative
+func (d *MemoryDatabase) find(filter bson.M, name string, limit int) ([]int, error) {
+	normalized, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+	indices := []int{}
+	for i, document := range d.collections[name] {
+		if limit >= 0 && len(indices) >= limit {
+			break
+		}
+		if matchesFilter(document, normalized) {
+			indices = append(indices, i)
+		}
+	}
+
+	return indices, nil
+}
+
+func matchesFilter(document bson.M, filter bson.M) bool {
+	for key, expected := range filter {
+		actual, ok := lookupField(document, key)
+		if !ok || !equalValues(actual, expected) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func lookupField(document bson.M, key string) (interface{}, bool) {
+	var value interface{} = document
+	for _, part := range strings.Split(key, ".") {
+		current, ok := value.(bson.M)
+		if !ok {
+			return nil, false
+		}
+		value, ok = current[part]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// equalValues compares the values like mongo, so numbers of
+// any type are equal by value and ids are equal to the ids
+// given by InsertCollection
+func equalValues(actual, expected interface{}) bool {
+	if id, ok := actual.(primitive.ObjectID); ok {
+		if text, ok := expected.(string); ok {
+			return text == id.Hex() || text == id.String()
+		}
+	}
+	actualNumber, actualIsNumber := toNumber(actual)
+	expectedNumber, expectedIsNumber := toNumber(expected)
+	if actualIsNumber && expectedIsNumber {
+		return actualNumber == expectedNumber
+	}
+
+	return reflect.DeepEqual(actual, expected)
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch number := value.(type) {
+	case int32:
+		return float64(number), true
+	case int64:
+		return float64(number), true
+	case float64:
+		return number, true
+	}
+
+	return 0, false
+}
+
+// toDocument converts the data to a document the way
+// mongo stores it, honouring the bson tags
+func toDocument(data interface{}) (bson.M, error) {
+	document := bson.M{}
+	if data == nil {
+		return document, nil
+	}
+	dataBytes, err := bson.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	err = bson.Unmarshal(dataBytes, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	return document, nil
+}
+
+func copyDocument(document bson.M) (bson.M, error) {
+	return toDocument(document)
+}
+
+// fromDocument reads the document into data the
+// way mongo decodes it
+func fromDocument(document bson.M, data interface{}) error {
+	if document == nil {
+		return nil
+	}
+	dataBytes, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(dataBytes, data)
+}
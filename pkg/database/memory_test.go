@@ -0,0 +1,198 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const testCollection = "orders"
+
+type testLeg struct {
+	Tag        string `bson:"tag"`
+	Quantity   int    `bson:"quantity"`
+	Instrument struct {
+		TradingSymbol string `bson:"tradingsymbol"`
+	} `bson:"instrument"`
+}
+
+func newTestLeg(tag, tradingSymbol string, quantity int) testLeg {
+	leg := testLeg{Tag: tag, Quantity: quantity}
+	leg.Instrument.TradingSymbol = tradingSymbol
+	return leg
+}
+
+func newTestMemoryDatabase(t *testing.T) *MemoryDatabase {
+	memoryDatabase := NewMemoryDatabase()
+	err := memoryDatabase.CreateCollection(testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &memoryDatabase
+}
+
+func TestMemoryUpsert(t *testing.T) {
+	d := newTestMemoryDatabase(t)
+
+	err := d.UpsertCollection(bson.M{"account": "alice"}, bson.M{"quantity": 50}, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.UpsertCollection(bson.M{"account": "alice"}, bson.M{"quantity": 100}, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.UpsertCollection(bson.M{"account": "bob"}, bson.M{"quantity": 25}, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	documents, err := d.GetCollections(bson.D{}, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 2 {
+		t.Fatalf("got %d documents, want one of each account", len(documents))
+	}
+	document, err := d.GetCollection(bson.D{{Key: "account", Value: "alice"}}, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// numbers compare by value whatever their type
+	if !equalValues(document["quantity"], int64(100)) {
+		t.Errorf("got quantity %v of alice, want the upsert to update it to 100", document["quantity"])
+	}
+}
+
+func TestMemoryUniqueIndex(t *testing.T) {
+	d := newTestMemoryDatabase(t)
+	_, err := d.InsertCollection(newTestLeg("a", "NIFTY24JAN21500CE", 50), testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = d.InsertCollection(newTestLeg("a", "NIFTY24JAN21500PE", 50), testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the documents there already break the index
+	err = d.CreateIndex(testCollection, []string{"tag"}, true)
+	if err == nil {
+		t.Error("created a unique index over duplicates, want a duplicate key error")
+	}
+	err = d.CreateIndex(testCollection, []string{"tag", "instrument.tradingsymbol"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = d.InsertCollection(newTestLeg("a", "NIFTY24JAN21500CE", 100), testCollection)
+	if err == nil {
+		t.Error("inserted a duplicate key, want a duplicate key error")
+	}
+	_, err = d.InsertCollection(newTestLeg("b", "NIFTY24JAN21500CE", 100), testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.UpdateCollection(bson.M{"tag": "b"}, bson.M{"tag": "a"}, testCollection)
+	if err == nil {
+		t.Error("updated to a duplicate key, want a duplicate key error")
+	}
+	err = d.UpsertCollection(bson.M{"tag": "b"}, bson.M{"quantity": 150}, testCollection)
+	if err != nil {
+		t.Errorf("got error %v updating the document itself, want none", err)
+	}
+	documents, err := d.GetCollections(bson.D{}, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 3 {
+		t.Errorf("got %d documents, want the duplicates left out", len(documents))
+	}
+}
+
+func TestMemoryDottedFilters(t *testing.T) {
+	d := newTestMemoryDatabase(t)
+	for _, leg := range []testLeg{
+		newTestLeg("a", "NIFTY24JAN21500CE", 50),
+		newTestLeg("a", "NIFTY24JAN21500PE", 50),
+		newTestLeg("b", "NIFTY24JAN21500CE", 100),
+	} {
+		_, err := d.InsertCollection(leg, testCollection)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter bson.D
+		want   int
+	}{
+		{"no filter", bson.D{}, 3},
+		{"field", bson.D{{Key: "tag", Value: "a"}}, 2},
+		{"dotted field", bson.D{{Key: "instrument.tradingsymbol", Value: "NIFTY24JAN21500CE"}}, 2},
+		{"dotted field and number", bson.D{{Key: "instrument.tradingsymbol", Value: "NIFTY24JAN21500CE"}, {Key: "quantity", Value: 100}}, 1},
+		{"missing field", bson.D{{Key: "instrument.exchange", Value: "NFO"}}, 0},
+		{"dots into a value", bson.D{{Key: "tag.name", Value: "a"}}, 0},
+	}
+	for _, test := range tests {
+		documents, err := d.GetCollections(test.filter, testCollection)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(documents) != test.want {
+			t.Errorf("got %d documents with %s, want %d", len(documents), test.name, test.want)
+		}
+	}
+}
+
+func TestMemoryTransaction(t *testing.T) {
+	d := newTestMemoryDatabase(t)
+	_, err := d.InsertCollection(newTestLeg("a", "NIFTY24JAN21500CE", 50), testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("failed")
+	err = d.WithTransaction(func(database Database) error {
+		_, err := database.InsertCollection(newTestLeg("b", "NIFTY24JAN21500PE", 50), testCollection)
+		if err != nil {
+			return err
+		}
+		err = database.UpdateCollection(bson.M{"tag": "a"}, bson.M{"quantity": 0}, testCollection)
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("got error %v, want the error of the transaction", err)
+	}
+	documents, err := d.GetCollections(bson.D{}, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 1 || !equalValues(documents[0]["quantity"], int32(50)) {
+		t.Fatalf("got %v after the abort, want the collection as it was", documents)
+	}
+
+	err = d.WithTransaction(func(database Database) error {
+		_, err := database.InsertCollection(newTestLeg("b", "NIFTY24JAN21500PE", 50), testCollection)
+		if err != nil {
+			return err
+		}
+		return database.DeleteCollection(bson.M{"tag": "a"}, testCollection)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	documents, err = d.GetCollections(bson.D{}, testCollection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 1 || documents[0]["tag"] != "b" {
+		t.Errorf("got %v after the commit, want the changes of the transaction", documents)
+	}
+}
@@ -15,6 +15,17 @@ var _ Database = &MongoDatabase{}
 
 type MongoDatabase struct {
 	Client *mongo.Client
+	// Name is the name of the mongo database and
+	// defaults to DefaultDatabaseName
+	Name string
+	// ctx is the session of the transaction if any
+	ctx context.Context
+}
+
+func NewMongoDatabase(name string) MongoDatabase {
+	return MongoDatabase{
+		Name: name,
+	}
 }
 
 func (d *MongoDatabase) Connect() error {
@@ -41,9 +52,25 @@ func (d *MongoDatabase) Disconnect() error {
 	return nil
 }
 
+func (d *MongoDatabase) database() *mongo.Database {
+	if d.Name == "" {
+		return d.Client.Database(DefaultDatabaseName)
+	}
+
+	return d.Client.Database(d.Name)
+}
+
+func (d *MongoDatabase) context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+
+	return d.ctx
+}
+
 func (d *MongoDatabase) CreateCollection(name string) error {
-	database := d.Client.Database("strategies")
-	err := database.CreateCollection(context.Background(), name, &options.CreateCollectionOptions{})
+	database := d.database()
+	err := database.CreateCollection(d.context(), name, &options.CreateCollectionOptions{})
 	if err != nil {
 		if !strings.Contains(err.Error(), "Collection already exists") && !strings.Contains(err.Error(), "already exists") {
 			return err
@@ -53,10 +80,28 @@ func (d *MongoDatabase) CreateCollection(name string) error {
 	return nil
 }
 
+func (d *MongoDatabase) CreateIndex(name string, keys []string, unique bool) error {
+	indexKeys := bson.D{}
+	for _, key := range keys {
+		indexKeys = append(indexKeys, bson.E{Key: key, Value: 1})
+	}
+
+	collection := d.database().Collection(name)
+	_, err := collection.Indexes().CreateOne(d.context(), mongo.IndexModel{
+		Keys:    indexKeys,
+		Options: options.Index().SetUnique(unique),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (d *MongoDatabase) GetCollection(filter primitive.D, name string) (bson.M, error) {
-	collection := d.Client.Database("strategies").Collection(name)
+	collection := d.database().Collection(name)
 
-	singleResult := collection.FindOne(context.Background(), filter, &options.FindOneOptions{})
+	singleResult := collection.FindOne(d.context(), filter, &options.FindOneOptions{})
 	var resultDoc bson.M
 	err := singleResult.Decode(&resultDoc)
 	if err != nil {
@@ -68,10 +113,26 @@ func (d *MongoDatabase) GetCollection(filter primitive.D, name string) (bson.M,
 	return resultDoc, nil
 }
 
+func (d *MongoDatabase) GetCollections(filter primitive.D, name string) ([]bson.M, error) {
+	collection := d.database().Collection(name)
+
+	cursor, err := collection.Find(d.context(), filter, &options.FindOptions{})
+	if err != nil {
+		return nil, err
+	}
+	resultDocs := []bson.M{}
+	err = cursor.All(d.context(), &resultDocs)
+	if err != nil {
+		return nil, err
+	}
+
+	return resultDocs, nil
+}
+
 func (d *MongoDatabase) InsertCollection(data interface{}, name string) (string, error) {
-	collection := d.Client.Database("strategies").Collection(name)
+	collection := d.database().Collection(name)
 
-	response, err := collection.InsertOne(context.Background(), data, &options.InsertOneOptions{})
+	response, err := collection.InsertOne(d.context(), data, &options.InsertOneOptions{})
 	if err != nil {
 		return "", nil
 	}
@@ -80,6 +141,14 @@ func (d *MongoDatabase) InsertCollection(data interface{}, name string) (string,
 }
 
 func (d *MongoDatabase) UpdateCollection(filter bson.M, data interface{}, name string) error {
+	return d.updateCollection(filter, data, name, false)
+}
+
+func (d *MongoDatabase) UpsertCollection(filter bson.M, data interface{}, name string) error {
+	return d.updateCollection(filter, data, name, true)
+}
+
+func (d *MongoDatabase) updateCollection(filter bson.M, data interface{}, name string, upsert bool) error {
 	var dataMap bson.M
 	dataBytes, err := bson.Marshal(data)
 	if err != nil {
@@ -93,8 +162,8 @@ func (d *MongoDatabase) UpdateCollection(filter bson.M, data interface{}, name s
 		"$set": dataMap,
 	}
 
-	collection := d.Client.Database("strategies").Collection(name)
-	_, err = collection.UpdateOne(context.Background(), filter, dataMapFull, &options.UpdateOptions{})
+	collection := d.database().Collection(name)
+	_, err = collection.UpdateOne(d.context(), filter, dataMapFull, options.Update().SetUpsert(upsert))
 	if err != nil {
 		return err
 	}
@@ -103,11 +172,28 @@ func (d *MongoDatabase) UpdateCollection(filter bson.M, data interface{}, name s
 }
 
 func (d *MongoDatabase) DeleteCollection(filter bson.M, name string) error {
-	collection := d.Client.Database("strategies").Collection(name)
-	_, err := collection.DeleteOne(context.Background(), filter, &options.DeleteOptions{})
+	collection := d.database().Collection(name)
+	_, err := collection.DeleteOne(d.context(), filter, &options.DeleteOptions{})
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// WithTransaction needs mongo to run as a replica set
+func (d *MongoDatabase) WithTransaction(transaction func(database Database) error) error {
+	session, err := d.Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(context.Background())
+
+	_, err = session.WithTransaction(context.Background(), func(sessionContext mongo.SessionContext) (interface{}, error) {
+		transactionDatabase := *d
+		transactionDatabase.ctx = sessionContext
+		return nil, transaction(&transactionDatabase)
+	})
+
+	return err
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	StrategyStateCollection = "strategystates"
+)
+
+// StrategyState is the state of a run of a strategy
+type StrategyState struct {
+	Strategy  string      `bson:"strategy"`
+	RunID     string      `bson:"runid"`
+	State     interface{} `bson:"state"`
+	UpdatedAt time.Time   `bson:"updatedat"`
+}
+
+// StrategyStateRepo keeps the state of the strategies so
+// that a run can carry on after a restart
+type StrategyStateRepo struct {
+	Database Database
+}
+
+func NewStrategyStateRepo(database Database) (StrategyStateRepo, error) {
+	err := database.CreateCollection(StrategyStateCollection)
+	if err != nil {
+		return StrategyStateRepo{}, err
+	}
+	err = database.CreateIndex(StrategyStateCollection, []string{"strategy", "runid"}, true)
+	if err != nil {
+		return StrategyStateRepo{}, err
+	}
+
+	return StrategyStateRepo{
+		Database: database,
+	}, nil
+}
+
+// Load reads the state of the run into state and tells
+// whether there was any
+func (r *StrategyStateRepo) Load(strategy, runID string, state interface{}) (bool, error) {
+	document, err := r.Database.GetCollection(bson.D{{Key: "strategy", Value: strategy}, {Key: "runid", Value: runID}}, StrategyStateCollection)
+	if err != nil {
+		return false, err
+	}
+	if document == nil {
+		return false, nil
+	}
+	stateDocument, ok := document["state"].(bson.M)
+	if !ok {
+		return false, nil
+	}
+	err = fromDocument(stateDocument, state)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *StrategyStateRepo) Save(strategy, runID string, state interface{}) error {
+	return r.Database.UpsertCollection(r.filter(strategy, runID), StrategyState{
+		Strategy:  strategy,
+		RunID:     runID,
+		State:     state,
+		UpdatedAt: time.Now(),
+	}, StrategyStateCollection)
+}
+
+func (r *StrategyStateRepo) Delete(strategy, runID string) error {
+	return r.Database.DeleteCollection(r.filter(strategy, runID), StrategyStateCollection)
+}
+
+func (r *StrategyStateRepo) filter(strategy, runID string) bson.M {
+	return bson.M{
+		"strategy": strategy,
+		"runid":    runID,
+	}
+}
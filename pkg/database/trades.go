@@ -0,0 +1,77 @@
+package database
+
+import (
+	"github.com/rohitsakala/strategies/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	TradeCollection = "trades"
+)
+
+// TradeRecord is a trade along with the run of
+// the strategy which made it
+type TradeRecord struct {
+	Strategy     string `bson:"strategy"`
+	RunID        string `bson:"runid"`
+	models.Trade `bson:",inline"`
+}
+
+// TradeRepo keeps the trades made by the strategies
+type TradeRepo struct {
+	Database Database
+}
+
+func NewTradeRepo(database Database) (TradeRepo, error) {
+	err := database.CreateCollection(TradeCollection)
+	if err != nil {
+		return TradeRepo{}, err
+	}
+	err = database.CreateIndex(TradeCollection, []string{"tradeid"}, true)
+	if err != nil {
+		return TradeRepo{}, err
+	}
+	err = database.CreateIndex(TradeCollection, []string{"strategy", "runid"}, false)
+	if err != nil {
+		return TradeRepo{}, err
+	}
+
+	return TradeRepo{
+		Database: database,
+	}, nil
+}
+
+// Save keeps the trades once however many times they are saved
+func (r *TradeRepo) Save(strategy, runID string, trades ...models.Trade) error {
+	for _, trade := range trades {
+		err := r.Database.UpsertCollection(bson.M{"tradeid": trade.TradeID}, TradeRecord{
+			Strategy: strategy,
+			RunID:    runID,
+			Trade:    trade,
+		}, TradeCollection)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Find gives the trades of the run of the strategy
+func (r *TradeRepo) Find(strategy, runID string) (models.Trades, error) {
+	documents, err := r.Database.GetCollections(bson.D{{Key: "strategy", Value: strategy}, {Key: "runid", Value: runID}}, TradeCollection)
+	if err != nil {
+		return nil, err
+	}
+	trades := models.Trades{}
+	for _, document := range documents {
+		var record TradeRecord
+		err = fromDocument(document, &record)
+		if err != nil {
+			return nil, err
+		}
+		trades = append(trades, record.Trade)
+	}
+
+	return trades, nil
+}
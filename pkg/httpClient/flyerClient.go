@@ -6,11 +6,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 )
 
 type Client interface {
 	GetQuote(symbol string) (SymbolQuote, error)
+	GetHistory(symbol, resolution string, from, to time.Time) ([]CMD, error)
 }
 
 type flyerClient struct {
@@ -20,8 +23,10 @@ type flyerClient struct {
 	accessToken string
 }
 
-func NewFyerHttpClient(url string) Client {
-	return &flyerClient{client: new(http.Client), url: url}
+// NewFyerHttpClient gives a client which authorizes its
+// requests with the app id and the access token
+func NewFyerHttpClient(url, appId, accessToken string) Client {
+	return &flyerClient{client: new(http.Client), url: url, appId: appId, accessToken: accessToken}
 }
 
 func (c *flyerClient) GetQuote(symbol string) (SymbolQuote, error) {
@@ -60,3 +65,57 @@ func (c *flyerClient) GetQuote(symbol string) (SymbolQuote, error) {
 	symbolQuotes := make([]SymbolQuoteResponse, 0)
 	return symbolQuotes[0].Quote, nil
 }
+
+// GetHistory gives the candles of the symbol between the times,
+// the resolution is in minutes or D for days
+func (c *flyerClient) GetHistory(symbol, resolution string, from, to time.Time) ([]CMD, error) {
+	query := url.Values{}
+	query.Set("symbol", symbol)
+	query.Set("resolution", resolution)
+	query.Set("date_format", "0")
+	query.Set("range_from", fmt.Sprintf("%d", from.Unix()))
+	query.Set("range_to", fmt.Sprintf("%d", to.Unix()))
+	query.Set("cont_flag", "1")
+	req, err := http.NewRequest("GET", c.url+"/history?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Authorization", strings.Join([]string{c.appId, c.accessToken}, ":"))
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unsuccessful response code %d from fyer history", resp.StatusCode)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var history HistoryResponse
+	err = json.Unmarshal(body, &history)
+	if err != nil {
+		return nil, err
+	}
+	if history.S != "ok" {
+		return nil, fmt.Errorf("unsuccessful status %s from fyer history: %s", history.S, history.Message)
+	}
+
+	candles := make([]CMD, 0, len(history.Candles))
+	for _, candle := range history.Candles {
+		if len(candle) < 6 {
+			return nil, fmt.Errorf("fyer history candle %v has too few values", candle)
+		}
+		candles = append(candles, CMD{
+			TimeOfDay: time.Unix(int64(candle[0]), 0),
+			Open:      candle[1],
+			High:      candle[2],
+			Low:       candle[3],
+			Close:     candle[4],
+			Volume:    int(candle[5]),
+		})
+	}
+
+	return candles, nil
+}
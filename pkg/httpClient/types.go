@@ -40,3 +40,11 @@ type SymbolQuoteResponse struct {
 	Name  string      `json:"n"`
 	Quote SymbolQuote `json:"v"`
 }
+
+// HistoryResponse has the candles as arrays of
+// epoch, open, high, low, close and volume
+type HistoryResponse struct {
+	S       string      `json:"s"`
+	Message string      `json:"message"`
+	Candles [][]float64 `json:"candles"`
+}
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"github.com/rohitsakala/strategies/pkg/httpClient"
+	kiteconnect "github.com/zerodha/gokiteconnect/v4"
+)
+
+// InstrumentFromKite gives the instrument of a kite instrument
+func InstrumentFromKite(instrument kiteconnect.Instrument) Instrument {
+	return Instrument{
+		TradingSymbol:   instrument.Tradingsymbol,
+		Exchange:        Exchange(instrument.Exchange),
+		Name:            instrument.Name,
+		Segment:         instrument.Segment,
+		InstrumentType:  instrument.InstrumentType,
+		InstrumentToken: instrument.InstrumentToken,
+		ExchangeToken:   instrument.ExchangeToken,
+		StrikePrice:     instrument.StrikePrice,
+		Expiry:          instrument.Expiry.Time,
+		LotSize:         int(instrument.LotSize),
+		TickSize:        instrument.TickSize,
+		LastPrice:       instrument.LastPrice,
+	}
+}
+
+// OrderFromKite gives the order of a kite order
+func OrderFromKite(order kiteconnect.Order) Order {
+	return Order{
+		Instrument: Instrument{
+			TradingSymbol:   order.TradingSymbol,
+			Exchange:        Exchange(order.Exchange),
+			InstrumentToken: int(order.InstrumentToken),
+		},
+		OrderID:         order.OrderID,
+		Tag:             order.Tag,
+		Product:         Product(order.Product),
+		OrderType:       OrderType(order.OrderType),
+		TransactionType: TransactionType(order.TransactionType),
+		Validity:        Validity(order.Validity),
+		Quantity:        int(order.Quantity),
+		Price:           order.Price,
+		TriggerPrice:    order.TriggerPrice,
+		AveragePrice:    order.AveragePrice,
+		FilledQuantity:  int(order.FilledQuantity),
+		PendingQuantity: int(order.PendingQuantity),
+		Status:          Status(order.Status),
+		StatusMessage:   order.StatusMessage,
+	}
+}
+
+// TradeFromKite gives the trade of a kite trade
+func TradeFromKite(trade kiteconnect.Trade) Trade {
+	return Trade{
+		TradeID:         trade.TradeID,
+		OrderID:         trade.OrderID,
+		TradingSymbol:   trade.TradingSymbol,
+		Exchange:        Exchange(trade.Exchange),
+		Product:         Product(trade.Product),
+		TransactionType: TransactionType(trade.TransactionType),
+		Quantity:        int(trade.Quantity),
+		AveragePrice:    trade.AveragePrice,
+		FillTime:        trade.FillTimestamp.Time,
+	}
+}
+
+// PositionFromKite gives the position of a kite position
+func PositionFromKite(position kiteconnect.Position) Position {
+	return Position{
+		Instrument: Instrument{
+			TradingSymbol:   position.Tradingsymbol,
+			Exchange:        Exchange(position.Exchange),
+			InstrumentToken: int(position.InstrumentToken),
+			LastPrice:       position.LastPrice,
+		},
+		Product:      Product(position.Product),
+		Quantity:     position.Quantity,
+		AveragePrice: position.AveragePrice,
+		Value:        position.Value,
+		BuyPrice:     position.BuyPrice,
+		SellPrice:    position.SellPrice,
+		PnL:          position.PnL,
+	}
+}
+
+// InstrumentFromFyerQuote gives the instrument of a fyers quote
+// along with its last price
+func InstrumentFromFyerQuote(quote httpClient.SymbolQuote) Instrument {
+	return Instrument{
+		TradingSymbol: quote.Symbol,
+		Exchange:      Exchange(quote.Exchange),
+		Name:          quote.ShortName,
+		LastPrice:     quote.LTP,
+	}
+}
+
+// CandleFromKite gives the candle of a kite historical data point
+func CandleFromKite(data kiteconnect.HistoricalData) Candle {
+	return Candle{
+		Time:   data.Date.Time,
+		Open:   data.Open,
+		High:   data.High,
+		Low:    data.Low,
+		Close:  data.Close,
+		Volume: data.Volume,
+		OI:     data.OI,
+	}
+}
+
+// CandleFromFyer gives the candle of a fyers candle
+func CandleFromFyer(candle httpClient.CMD) Candle {
+	return Candle{
+		Time:   candle.TimeOfDay,
+		Open:   candle.Open,
+		High:   candle.High,
+		Low:    candle.Low,
+		Close:  candle.Close,
+		Volume: candle.Volume,
+	}
+}
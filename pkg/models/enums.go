@@ -0,0 +1,109 @@
+package models
+
+import "time"
+
+type Exchange string
+
+const (
+	ExchangeNSE Exchange = "NSE"
+	ExchangeBSE Exchange = "BSE"
+	ExchangeNFO Exchange = "NFO"
+	ExchangeBFO Exchange = "BFO"
+	ExchangeMCX Exchange = "MCX"
+	ExchangeCDS Exchange = "CDS"
+)
+
+type Product string
+
+const (
+	ProductMIS  Product = "MIS"
+	ProductNRML Product = "NRML"
+	ProductCNC  Product = "CNC"
+)
+
+type OrderType string
+
+const (
+	OrderTypeMarket OrderType = "MARKET"
+	OrderTypeLimit  OrderType = "LIMIT"
+	OrderTypeSL     OrderType = "SL"
+	OrderTypeSLM    OrderType = "SL-M"
+)
+
+type TransactionType string
+
+const (
+	TransactionTypeBuy  TransactionType = "BUY"
+	TransactionTypeSell TransactionType = "SELL"
+)
+
+// Opposite gives the transaction type which squares off this one
+func (t TransactionType) Opposite() TransactionType {
+	if t == TransactionTypeBuy {
+		return TransactionTypeSell
+	}
+
+	return TransactionTypeBuy
+}
+
+type Validity string
+
+const (
+	ValidityDay Validity = "DAY"
+	ValidityIOC Validity = "IOC"
+	ValidityTTL Validity = "TTL"
+)
+
+type Status string
+
+const (
+	StatusOpen           Status = "OPEN"
+	StatusComplete       Status = "COMPLETE"
+	StatusRejected       Status = "REJECTED"
+	StatusCancelled      Status = "CANCELLED"
+	StatusTriggerPending Status = "TRIGGER PENDING"
+)
+
+// IsFinal tells whether the order can not change anymore
+func (s Status) IsFinal() bool {
+	return s == StatusComplete || s == StatusRejected || s == StatusCancelled
+}
+
+// Interval is the size of a candle, named like kite does
+type Interval string
+
+const (
+	IntervalMinute        Interval = "minute"
+	IntervalThreeMinute   Interval = "3minute"
+	IntervalFiveMinute    Interval = "5minute"
+	IntervalTenMinute     Interval = "10minute"
+	IntervalFifteenMinute Interval = "15minute"
+	IntervalThirtyMinute  Interval = "30minute"
+	IntervalHour          Interval = "60minute"
+	IntervalDay           Interval = "day"
+)
+
+// Duration gives the time a candle of the interval spans
+// or zero for an unknown interval
+func (i Interval) Duration() time.Duration {
+	switch i {
+	case IntervalMinute:
+		return time.Minute
+	case IntervalThreeMinute:
+		return 3 * time.Minute
+	case IntervalFiveMinute:
+		return 5 * time.Minute
+	case IntervalTenMinute:
+		return 10 * time.Minute
+	case IntervalFifteenMinute:
+		return 15 * time.Minute
+	case IntervalThirtyMinute:
+		return 30 * time.Minute
+	case IntervalHour:
+		return time.Hour
+	case IntervalDay:
+		return 24 * time.Hour
+	}
+
+	return 0
+}
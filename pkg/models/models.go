@@ -2,39 +2,110 @@ package models
 
 import "time"
 
-type Position struct {
+// Instrument is a tradable symbol on an exchange
+type Instrument struct {
 	TradingSymbol   string    `json:"tradingsymbol"`
-	Exchange        string    `json:"exchange"`
-	Product         string    `json:"product"`
-	AveragePrice    float64   `json:"average_price"`
-	Value           float64   `json:"value"`
-	Quantity        int       `json:"quantity"`
-	BuyPrice        float64   `json:"buy_price"`
-	SellPrice       float64   `json:"sell_price"`
-	StoplossPrice   float64   `json:"stoploss_price"`
-	Price           float64   `json:"price"`
-	TriggerPrice    float64   `json:"trigger_price"`
-	TargetPrice     string    `json:"target_price"`
-	StrikePrice     float64   `json:"strike_price"`
-	Type            string    `json:"type"`
-	LotSize         int       `json:"lot_size"`
+	Exchange        Exchange  `json:"exchange"`
+	Name            string    `json:"name"`
+	Segment         string    `json:"segment"`
+	InstrumentType  string    `json:"instrument_type"`
 	InstrumentToken int       `json:"instrument_token"`
 	ExchangeToken   int       `json:"exchange_token"`
-	Name            string    `json:"name"`
-	LastPrice       float64   `json:"last_price"`
+	StrikePrice     float64   `json:"strike_price"`
 	Expiry          time.Time `json:"expiry"`
+	LotSize         int       `json:"lot_size"`
 	TickSize        float64   `json:"tick_size"`
-	InstrumentType  string    `json:"instrument_type"`
-	Segment         string    `json:"segment"`
-	OrderType       string    `json:"order_type"`
-	TransactionType string    `json:"transaction_type"`
-	OrderID         string    `json:"order_id"`
-	Status          string    `json:"status"`
+	LastPrice       float64   `json:"last_price"`
+}
+
+type Instruments []Instrument
+
+// Order is an order placed or to be placed on an instrument.
+// An order above the freeze quantity is placed as Slices.
+type Order struct {
+	Instrument      `bson:",inline"`
+	OrderID         string          `json:"order_id"`
+	Tag             string          `json:"tag"`
+	Product         Product         `json:"product"`
+	OrderType       OrderType       `json:"order_type"`
+	TransactionType TransactionType `json:"transaction_type"`
+	Validity        Validity        `json:"validity"`
+	Quantity        int             `json:"quantity"`
+	Price           float64         `json:"price"`
+	TriggerPrice    float64         `json:"trigger_price"`
+	AveragePrice    float64         `json:"average_price"`
+	FilledQuantity  int             `json:"filled_quantity"`
+	PendingQuantity int             `json:"pending_quantity"`
+	Status          Status          `json:"status"`
+	StatusMessage   string          `json:"status_message"`
+	// ReferencePrice is the LTP a limit order chases from
+	// and its slippage is measured against
+	ReferencePrice float64 `json:"reference_price"`
+	Slippage       float64 `json:"slippage"`
+	Slices         Orders  `json:"slices"`
+}
+
+// Unplaced gives a copy of the order without the state of its
+// placement so that it can be placed again as a new order
+func (o Order) Unplaced() Order {
+	o.OrderID = ""
+	o.Status = ""
+	o.StatusMessage = ""
+	o.AveragePrice = 0
+	o.FilledQuantity = 0
+	o.PendingQuantity = 0
+	o.ReferencePrice = 0
+	o.Slippage = 0
+	o.Slices = nil
+
+	return o
+}
+
+type Orders []Order
+
+type RefOrders []*Order
+
+// Trade is a fill of an order on the exchange
+type Trade struct {
+	TradeID         string          `json:"trade_id"`
+	OrderID         string          `json:"order_id"`
+	TradingSymbol   string          `json:"tradingsymbol"`
+	Exchange        Exchange        `json:"exchange"`
+	Product         Product         `json:"product"`
+	TransactionType TransactionType `json:"transaction_type"`
+	Quantity        int             `json:"quantity"`
+	AveragePrice    float64         `json:"average_price"`
+	FillTime        time.Time       `json:"fill_time"`
+}
+
+type Trades []Trade
+
+// Position is the net holding of an instrument in the account
+type Position struct {
+	Instrument   `bson:",inline"`
+	Product      Product `json:"product"`
+	Quantity     int     `json:"quantity"`
+	AveragePrice float64 `json:"average_price"`
+	Value        float64 `json:"value"`
+	BuyPrice     float64 `json:"buy_price"`
+	SellPrice    float64 `json:"sell_price"`
+	PnL          float64 `json:"pnl"`
 }
 
 type Positions []Position
 
-type RefPositions []*Position
+// Candle is an OHLC bar starting at Time
+type Candle struct {
+	Time   time.Time `json:"time"`
+	Open   float64   `json:"open"`
+	High   float64   `json:"high"`
+	Low    float64   `json:"low"`
+	Close  float64   `json:"close"`
+	Volume int       `json:"volume"`
+	OI     int       `json:"oi"`
+}
+
+type Candles []Candle
 
 type Credentials struct {
 	AccessToken string
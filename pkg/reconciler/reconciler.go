@@ -0,0 +1,352 @@
+package reconciler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const (
+	DefaultInterval = 5 * time.Minute
+)
+
+type MismatchType string
+
+const (
+	// MismatchMissing is a leg the strategy holds which
+	// the broker has no position of
+	MismatchMissing MismatchType = "missing"
+	// MismatchExtra is a position of an instrument the
+	// strategy traded today but doesn't hold
+	MismatchExtra MismatchType = "extra"
+	// MismatchDrift is a position whose quantity is not
+	// the one the strategy holds
+	MismatchDrift MismatchType = "drift"
+)
+
+// Mismatch is a difference between the strategy and the broker.
+// The quantities are net, negative when short.
+type Mismatch struct {
+	Type          MismatchType
+	TradingSymbol string
+	Product       models.Product
+	Expected      int
+	Actual        int
+	// SquaredOff tells whether the extra position was squared off
+	SquaredOff bool
+}
+
+func (m Mismatch) String() string {
+	message := fmt.Sprintf("%s %s %s: strategy holds %d, broker holds %d", m.Type, m.TradingSymbol, m.Product, m.Expected, m.Actual)
+	if m.SquaredOff {
+		message = message + ", squared off"
+	}
+
+	return message
+}
+
+type Mismatches []Mismatch
+
+func (m Mismatches) String() string {
+	messages := []string{}
+	for _, mismatch := range m {
+		messages = append(messages, mismatch.String())
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+// Held gives the quantity of the leg the broker still holds. It
+// is the quantity of the leg unless the position of the leg is
+// missing or has drifted, and never more than the leg.
+func (m Mismatches) Held(leg models.Order) int {
+	for _, mismatch := range m {
+		if mismatch.TradingSymbol != leg.TradingSymbol || mismatch.Product != leg.Product {
+			continue
+		}
+		actual := mismatch.Actual
+		if leg.TransactionType == models.TransactionTypeSell {
+			actual = -actual
+		}
+		if actual <= 0 {
+			return 0
+		}
+		if actual < leg.Quantity {
+			return actual
+		}
+	}
+
+	return leg.Quantity
+}
+
+// Holding is an instrument held in a product, as the
+// broker keeps the positions of the products apart
+type Holding struct {
+	TradingSymbol string
+	Product       models.Product
+}
+
+func HoldingOf(order models.Order) Holding {
+	return Holding{TradingSymbol: order.TradingSymbol, Product: order.Product}
+}
+
+// Holdings are the net quantities by holding
+type Holdings map[Holding]int
+
+// HoldingsOf gives what the filled orders hold. Orders not
+// placed and stop losses not triggered hold nothing.
+func HoldingsOf(orders ...models.Order) Holdings {
+	holdings := Holdings{}
+	for _, order := range orders {
+		if len(order.TradingSymbol) < 1 || len(order.OrderID) < 1 && len(order.Slices) < 1 {
+			continue
+		}
+		if isStopLoss(order) && !showsFill(order) {
+			continue
+		}
+		holdings[HoldingOf(order)] += filledQuantity(order)
+	}
+
+	return holdings
+}
+
+// filledQuantity gives the net quantity the order has filled
+func filledQuantity(order models.Order) int {
+	quantity := order.FilledQuantity
+	if quantity <= 0 && order.Status == models.StatusComplete {
+		quantity = order.Quantity
+	}
+	if order.TransactionType == models.TransactionTypeSell {
+		return -quantity
+	}
+
+	return quantity
+}
+
+// attributed gives the part of the net quantity the account holds
+// which the net fills of the strategy account for, the rest is
+// held by the other strategies or by hand
+func attributed(fills, account int) int {
+	switch {
+	case fills > 0 && account > 0:
+		if fills < account {
+			return fills
+		}
+		return account
+	case fills < 0 && account < 0:
+		if fills > account {
+			return fills
+		}
+		return account
+	}
+
+	return 0
+}
+
+func isStopLoss(order models.Order) bool {
+	return order.OrderType == models.OrderTypeSL || order.OrderType == models.OrderTypeSLM
+}
+
+// showsFill tells whether the status of the order says it has
+// filled, a stop loss still waiting for its trigger has not
+// whatever quantities it carries
+func showsFill(order models.Order) bool {
+	switch order.Status {
+	case models.StatusComplete:
+		return true
+	case models.StatusOpen, models.StatusCancelled:
+		return order.FilledQuantity > 0
+	}
+
+	return false
+}
+
+// ReconcilerConfig decides how often the strategies reconcile
+// and whether the extra positions get squared off
+type ReconcilerConfig struct {
+	Interval        time.Duration
+	SquareOffExtras bool
+}
+
+// NewReconcilerConfig reads the RECONCILE_* environment
+// variables of the account
+func NewReconcilerConfig(account account.Account) (ReconcilerConfig, error) {
+	var err error
+	config := ReconcilerConfig{
+		Interval: DefaultInterval,
+	}
+
+	if value := account.Getenv("RECONCILE_INTERVAL"); len(value) > 0 {
+		config.Interval, err = time.ParseDuration(value)
+		if err != nil || config.Interval <= 0 {
+			return ReconcilerConfig{}, fmt.Errorf("invalid %s %s", account.Key("RECONCILE_INTERVAL"), value)
+		}
+	}
+	if value := account.Getenv("RECONCILE_SQUARE_OFF_EXTRAS"); len(value) > 0 {
+		config.SquareOffExtras, err = strconv.ParseBool(value)
+		if err != nil {
+			return ReconcilerConfig{}, fmt.Errorf("invalid %s %s", account.Key("RECONCILE_SQUARE_OFF_EXTRAS"), value)
+		}
+	}
+
+	return config, nil
+}
+
+// Reconciler compares the legs a strategy holds with the positions
+// of the broker. The orders of the strategy are told apart by the
+// prefix of their tags, and of the positions of the account only the
+// quantity their fills account for is taken to be the strategy's.
+type Reconciler struct {
+	Config   ReconcilerConfig
+	Broker   broker.Broker
+	Strategy string
+	last     time.Time
+}
+
+func NewReconciler(account account.Account, broker broker.Broker, strategy string) (Reconciler, error) {
+	config, err := NewReconcilerConfig(account)
+	if err != nil {
+		return Reconciler{}, err
+	}
+
+	return Reconciler{
+		Config:   config,
+		Broker:   broker,
+		Strategy: strategy,
+	}, nil
+}
+
+// Due tells whether the interval since the last reconcile is over
+func (r *Reconciler) Due() bool {
+	return time.Since(r.last) >= r.Config.Interval
+}
+
+// Reconcile gives the mismatches between the holdings of the strategy
+// and the positions of the broker in the instruments the strategy
+// holds or has traded today, and squares off the extra positions
+// if configured to. The positions are net over the account, so only
+// the quantity the fills of the orders of the strategy account for
+// is taken to be held by the strategy.
+func (r *Reconciler) Reconcile(expected Holdings) (Mismatches, error) {
+	return r.reconcile(expected, r.Config.SquareOffExtras)
+}
+
+// Report gives the mismatches like Reconcile without squaring off
+// anything, for when the holdings of the strategy aren't known like
+// at startup without a saved state, as every position it traded
+// before would look extra
+func (r *Reconciler) Report(expected Holdings) (Mismatches, error) {
+	return r.reconcile(expected, false)
+}
+
+func (r *Reconciler) reconcile(expected Holdings, squareOffExtras bool) (Mismatches, error) {
+	r.last = time.Now()
+
+	positions, err := r.Broker.GetPositions()
+	if err != nil {
+		return nil, err
+	}
+	orders, err := r.Broker.GetOrders()
+	if err != nil {
+		return nil, err
+	}
+
+	account := Holdings{}
+	held := map[Holding]models.Position{}
+	for _, position := range positions {
+		holding := Holding{TradingSymbol: position.TradingSymbol, Product: position.Product}
+		account[holding] += position.Quantity
+		held[holding] = position
+	}
+	holdings := map[Holding]bool{}
+	for holding := range expected {
+		holdings[holding] = true
+	}
+	prefix := broker.OrderTagPrefix(r.Strategy)
+	fills := Holdings{}
+	for _, order := range orders {
+		if len(prefix) > 0 && strings.HasPrefix(order.Tag, prefix) && (order.FilledQuantity > 0 || order.Status == models.StatusComplete) {
+			holdings[HoldingOf(order)] = true
+			fills[HoldingOf(order)] += filledQuantity(order)
+		}
+	}
+	sorted := []Holding{}
+	for holding := range holdings {
+		sorted = append(sorted, holding)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].TradingSymbol != sorted[j].TradingSymbol {
+			return sorted[i].TradingSymbol < sorted[j].TradingSymbol
+		}
+		return sorted[i].Product < sorted[j].Product
+	})
+
+	mismatches := Mismatches{}
+	for _, holding := range sorted {
+		mismatch := Mismatch{
+			TradingSymbol: holding.TradingSymbol,
+			Product:       holding.Product,
+			Expected:      expected[holding],
+			Actual:        account[holding],
+		}
+		if len(prefix) > 0 {
+			mismatch.Actual = attributed(fills[holding], account[holding])
+		}
+		switch {
+		case mismatch.Expected == mismatch.Actual:
+			continue
+		case mismatch.Actual == 0:
+			mismatch.Type = MismatchMissing
+		case mismatch.Expected == 0:
+			mismatch.Type = MismatchExtra
+			if squareOffExtras {
+				err = r.squareOff(mismatch, held[holding])
+				if err != nil {
+					return nil, err
+				}
+				mismatch.SquaredOff = true
+			}
+		default:
+			mismatch.Type = MismatchDrift
+		}
+		log.Printf("Reconciling %s: %s", r.Strategy, mismatch)
+		mismatches = append(mismatches, mismatch)
+	}
+
+	return mismatches, nil
+}
+
+// squareOff closes the extra quantity of the mismatch with
+// an order tagged like the orders of the strategy, leaving
+// the rest of the position of the account as it is
+func (r *Reconciler) squareOff(mismatch Mismatch, position models.Position) error {
+	instrument, err := r.Broker.GetInstrument(mismatch.TradingSymbol, position.Exchange)
+	if err != nil {
+		return err
+	}
+	if len(instrument.TradingSymbol) < 1 {
+		instrument = position.Instrument
+	}
+	order := models.Order{
+		Instrument:      instrument,
+		Product:         mismatch.Product,
+		OrderType:       models.OrderTypeLimit,
+		TransactionType: models.TransactionTypeSell,
+		Quantity:        mismatch.Actual,
+		Tag:             broker.NewOrderTag(r.Strategy, "reconcile", mismatch.TradingSymbol, string(mismatch.Product), time.Now().Format("20060102150405")),
+	}
+	if mismatch.Actual < 0 {
+		order.TransactionType = models.TransactionTypeBuy
+		order.Quantity = -mismatch.Actual
+	}
+	log.Printf("Squaring off the extra %d %s %s....", mismatch.Actual, mismatch.TradingSymbol, mismatch.Product)
+
+	return r.Broker.PlaceOrder(&order)
+}
@@ -0,0 +1,245 @@
+package reconciler
+
+import (
+	"testing"
+
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const (
+	testStrategy = "twelvethirty"
+	testCE       = "NIFTY2410421500CE"
+	testPE       = "NIFTY2410421500PE"
+)
+
+func newTestReconciler(t *testing.T, squareOffExtras bool) (*Reconciler, *broker.MockBroker) {
+	mockBroker, err := broker.NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tradingSymbol := range []string{testCE, testPE} {
+		mockBroker.Instruments = append(mockBroker.Instruments, models.Instrument{
+			TradingSymbol: tradingSymbol,
+			Exchange:      models.ExchangeNFO,
+			LotSize:       50,
+		})
+	}
+
+	return &Reconciler{
+		Config:   ReconcilerConfig{Interval: DefaultInterval, SquareOffExtras: squareOffExtras},
+		Broker:   &mockBroker,
+		Strategy: testStrategy,
+	}, &mockBroker
+}
+
+// placeLeg fills an order of the strategy or of another one
+func placeLeg(t *testing.T, mockBroker *broker.MockBroker, strategy, leg, tradingSymbol string, product models.Product, transactionType models.TransactionType, quantity int) models.Order {
+	order := models.Order{
+		Instrument:      models.Instrument{TradingSymbol: tradingSymbol, Exchange: models.ExchangeNFO},
+		Tag:             broker.NewOrderTag(strategy, "20240102", leg),
+		Product:         product,
+		OrderType:       models.OrderTypeLimit,
+		TransactionType: transactionType,
+		Quantity:        quantity,
+		Price:           100,
+	}
+	err := mockBroker.PlaceOrder(&order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return order
+}
+
+func TestReconcileMatchingLegs(t *testing.T) {
+	r, mockBroker := newTestReconciler(t, true)
+	sellCE := placeLeg(t, mockBroker, testStrategy, "sellce", testCE, models.ProductMIS, models.TransactionTypeSell, 50)
+	sellPE := placeLeg(t, mockBroker, testStrategy, "sellpe", testPE, models.ProductMIS, models.TransactionTypeSell, 50)
+
+	mismatches, err := r.Reconcile(HoldingsOf(sellCE, sellPE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("got mismatches %s, want none", mismatches)
+	}
+}
+
+func TestReconcileLeavesOtherStrategiesAlone(t *testing.T) {
+	r, mockBroker := newTestReconciler(t, true)
+	// the strategy bought back its CE while another
+	// strategy and a manual trade still hold some
+	placeLeg(t, mockBroker, testStrategy, "sellce", testCE, models.ProductMIS, models.TransactionTypeSell, 50)
+	placeLeg(t, mockBroker, testStrategy, "exitce", testCE, models.ProductMIS, models.TransactionTypeBuy, 50)
+	placeLeg(t, mockBroker, "ironcondor", "sellce", testCE, models.ProductMIS, models.TransactionTypeSell, 100)
+	mockBroker.Positions = models.Positions{
+		{Instrument: models.Instrument{TradingSymbol: testPE, Exchange: models.ExchangeNFO}, Product: models.ProductNRML, Quantity: -50},
+	}
+
+	mismatches, err := r.Reconcile(Holdings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 0 {
+		t.Errorf("got mismatches %s, want none", mismatches)
+	}
+	if placed := mockBroker.PlacedOrders(); len(placed) != 3 {
+		t.Errorf("got %d placed orders, want nothing squared off", len(placed))
+	}
+}
+
+func TestReconcileSquaresOffOnlyItsOwnExtra(t *testing.T) {
+	tests := []struct {
+		name string
+		// other is the quantity of the CE another strategy
+		// holds in the product
+		other        int
+		otherProduct models.Product
+		// want is the extra of the strategy and left what
+		// the account holds in MIS after the square off
+		want int
+		left int
+	}{
+		{"no other position", 0, models.ProductMIS, -50, 0},
+		{"another strategy in the same product", -100, models.ProductMIS, -50, -100},
+		{"another strategy in NRML", -100, models.ProductNRML, -50, 0},
+		{"another strategy long", 25, models.ProductMIS, -25, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, mockBroker := newTestReconciler(t, true)
+			placeLeg(t, mockBroker, testStrategy, "sellce", testCE, models.ProductMIS, models.TransactionTypeSell, 50)
+			if test.other < 0 {
+				placeLeg(t, mockBroker, "ironcondor", "sellce", testCE, test.otherProduct, models.TransactionTypeSell, -test.other)
+			} else if test.other > 0 {
+				placeLeg(t, mockBroker, "ironcondor", "buyce", testCE, test.otherProduct, models.TransactionTypeBuy, test.other)
+			}
+
+			// the strategy lost track of its CE
+			mismatches, err := r.Reconcile(Holdings{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mismatches) != 1 || mismatches[0].Type != MismatchExtra || mismatches[0].Actual != test.want || mismatches[0].Product != models.ProductMIS || !mismatches[0].SquaredOff {
+				t.Fatalf("got mismatches %s, want the extra %d of %s squared off", mismatches, test.want, testCE)
+			}
+
+			placed := mockBroker.PlacedOrders()
+			squareOff := placed[len(placed)-1]
+			if squareOff.TransactionType != models.TransactionTypeBuy || squareOff.Quantity != -test.want || squareOff.Product != models.ProductMIS {
+				t.Errorf("got square off %s %d %s, want BUY %d MIS", squareOff.TransactionType, squareOff.Quantity, squareOff.Product, -test.want)
+			}
+			positions, err := mockBroker.GetPositions()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, position := range positions {
+				want := test.left
+				if position.Product != models.ProductMIS {
+					want = test.other
+				}
+				if position.Quantity != want {
+					t.Errorf("got %d of %s %s after the square off, want %d", position.Quantity, position.TradingSymbol, position.Product, want)
+				}
+			}
+
+			// the square off is a fill of the strategy as well
+			mismatches, err = r.Reconcile(Holdings{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(mismatches) != 0 {
+				t.Errorf("got mismatches %s after the square off, want none", mismatches)
+			}
+		})
+	}
+}
+
+func TestReportDoesNotSquareOff(t *testing.T) {
+	r, mockBroker := newTestReconciler(t, true)
+	placeLeg(t, mockBroker, testStrategy, "sellce", testCE, models.ProductMIS, models.TransactionTypeSell, 50)
+
+	mismatches, err := r.Report(Holdings{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 1 || mismatches[0].Type != MismatchExtra || mismatches[0].SquaredOff {
+		t.Errorf("got mismatches %s, want the extra only reported", mismatches)
+	}
+	if placed := mockBroker.PlacedOrders(); len(placed) != 1 {
+		t.Errorf("got %d placed orders, want nothing squared off", len(placed))
+	}
+}
+
+func TestReconcileMissingAndDrift(t *testing.T) {
+	r, mockBroker := newTestReconciler(t, false)
+	sellCE := placeLeg(t, mockBroker, testStrategy, "sellce", testCE, models.ProductMIS, models.TransactionTypeSell, 50)
+	sellPE := placeLeg(t, mockBroker, testStrategy, "sellpe", testPE, models.ProductMIS, models.TransactionTypeSell, 100)
+	// the CE got bought back and half the PE by hand
+	placeLeg(t, mockBroker, "manual", "buyce", testCE, models.ProductMIS, models.TransactionTypeBuy, 50)
+	placeLeg(t, mockBroker, "manual", "buype", testPE, models.ProductMIS, models.TransactionTypeBuy, 50)
+
+	mismatches, err := r.Reconcile(HoldingsOf(sellCE, sellPE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mismatches) != 2 {
+		t.Fatalf("got mismatches %s, want the CE missing and the PE drifted", mismatches)
+	}
+	if mismatches[0].Type != MismatchMissing || mismatches[0].TradingSymbol != testCE {
+		t.Errorf("got %s, want the CE missing", mismatches[0])
+	}
+	if mismatches[1].Type != MismatchDrift || mismatches[1].TradingSymbol != testPE || mismatches[1].Actual != -50 {
+		t.Errorf("got %s, want the PE drifted to -50", mismatches[1])
+	}
+	if held := mismatches.Held(sellCE); held != 0 {
+		t.Errorf("got %d of the CE held, want 0", held)
+	}
+	if held := mismatches.Held(sellPE); held != 50 {
+		t.Errorf("got %d of the PE held, want 50", held)
+	}
+	nrml := sellPE
+	nrml.Product = models.ProductNRML
+	if held := mismatches.Held(nrml); held != 100 {
+		t.Errorf("got %d of the NRML PE held, want the mismatch of MIS ignored", held)
+	}
+}
+
+func TestHoldingsOf(t *testing.T) {
+	leg := models.Order{
+		Instrument:      models.Instrument{TradingSymbol: testCE},
+		OrderID:         "1",
+		Product:         models.ProductMIS,
+		TransactionType: models.TransactionTypeSell,
+		Quantity:        50,
+		FilledQuantity:  50,
+		Status:          models.StatusComplete,
+	}
+	stopLoss := func(status models.Status, filled int) models.Order {
+		order := leg
+		order.OrderID = "2"
+		order.OrderType = models.OrderTypeSL
+		order.TransactionType = models.TransactionTypeBuy
+		order.Status = status
+		order.FilledQuantity = filled
+		return order
+	}
+	tests := []struct {
+		name     string
+		stopLoss models.Order
+		want     int
+	}{
+		{"stop loss not placed", models.Order{}, -50},
+		{"stop loss waiting for the trigger with stale fills", stopLoss(models.StatusTriggerPending, 50), -50},
+		{"stop loss triggered and open", stopLoss(models.StatusOpen, 0), -50},
+		{"stop loss partly filled", stopLoss(models.StatusOpen, 20), -30},
+		{"stop loss filled", stopLoss(models.StatusComplete, 50), 0},
+	}
+	for _, test := range tests {
+		holdings := HoldingsOf(leg, test.stopLoss)
+		if got := holdings[HoldingOf(leg)]; got != test.want {
+			t.Errorf("got %d held when %s, want %d", got, test.name, test.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// EncryptedPrefix marks the values encrypted by EncryptString
+	EncryptedPrefix = "enc:v1:"
+
+	saltLength = 16
+	keyLength  = 32
+)
+
+// Cipher encrypts with AES-GCM under a key derived
+// from the master key with scrypt and a random salt
+type Cipher struct {
+	masterKey []byte
+}
+
+func NewCipher(masterKey string) (Cipher, error) {
+	if len(masterKey) < 1 {
+		return Cipher{}, errors.New("master key is empty")
+	}
+
+	return Cipher{
+		masterKey: []byte(masterKey),
+	}, nil
+}
+
+// Encrypt gives the salt, the nonce and the sealed data together
+func (c *Cipher) Encrypt(plaintext []byte) ([]byte, error) {
+	salt := make([]byte, saltLength)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := c.gcm(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	data := append(salt, nonce...)
+	return gcm.Seal(data, nonce, plaintext, nil), nil
+}
+
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	if len(data) < saltLength {
+		return nil, errors.New("encrypted data is too short")
+	}
+	gcm, err := c.gcm(data[:saltLength])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltLength:]
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("encrypted data is too short")
+	}
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("decrypting failed, the master key may be wrong")
+	}
+
+	return plaintext, nil
+}
+
+// EncryptString gives the value encrypted as text with
+// EncryptedPrefix in front of it
+func (c *Cipher) EncryptString(value string) (string, error) {
+	data, err := c.Encrypt([]byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// DecryptString gives back values which are not encrypted
+// as they are, so values saved before encryption still work
+func (c *Cipher) DecryptString(value string) (string, error) {
+	if !strings.HasPrefix(value, EncryptedPrefix) {
+		return value, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := c.Decrypt(data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+func (c *Cipher) gcm(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(c.masterKey, salt, 1<<15, 8, 1, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
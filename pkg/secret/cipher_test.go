@@ -0,0 +1,80 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	cipher, err := NewCipher("master key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := cipher.EncryptString("access token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, EncryptedPrefix) || strings.Contains(encrypted, "access token") {
+		t.Errorf("got %s, want the value encrypted behind %s", encrypted, EncryptedPrefix)
+	}
+	again, err := cipher.EncryptString("access token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == encrypted {
+		t.Error("got the same encryption twice, want a random salt and nonce")
+	}
+
+	decrypted, err := cipher.DecryptString(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != "access token" {
+		t.Errorf("got %s, want access token", decrypted)
+	}
+}
+
+func TestCipherWrongMasterKey(t *testing.T) {
+	cipher, err := NewCipher("master key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err := cipher.EncryptString("access token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong, err := NewCipher("another key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wrong.DecryptString(encrypted)
+	if err == nil {
+		t.Error("decrypted with the wrong master key, want an error")
+	}
+}
+
+func TestCipherPlaintextPassesThrough(t *testing.T) {
+	cipher, err := NewCipher("master key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{"", "access token saved before encryption"} {
+		decrypted, err := cipher.DecryptString(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decrypted != value {
+			t.Errorf("got %q, want %q as it is", decrypted, value)
+		}
+	}
+}
+
+func TestCipherWithoutMasterKey(t *testing.T) {
+	_, err := NewCipher("")
+	if err == nil {
+		t.Error("got a cipher without a master key, want an error")
+	}
+}
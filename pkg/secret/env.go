@@ -0,0 +1,23 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+)
+
+// EnvStore reads the secrets from the environment
+// variables of the same name
+type EnvStore struct{}
+
+func NewEnvStore() EnvStore {
+	return EnvStore{}
+}
+
+func (e *EnvStore) GetSecret(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: environment variable %s is not set", ErrSecretNotFound, name)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,57 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+)
+
+const (
+	DefaultFilePath   = "secrets.enc"
+	DefaultVaultMount = "secret"
+	DefaultVaultPath  = "strategies"
+)
+
+func GetStore(name string) (Store, error) {
+	switch name {
+	case "env":
+		envStore := NewEnvStore()
+		return &envStore, nil
+	case "file":
+		fileStore, err := NewFileStore(getEnv("SECRETS_FILE", DefaultFilePath), os.Getenv("SECRETS_MASTER_KEY"))
+		if err != nil {
+			return nil, err
+		}
+		return &fileStore, nil
+	case "vault":
+		vaultStore := NewVaultStore(getEnv("VAULT_ADDR", "http://127.0.0.1:8200"), os.Getenv("VAULT_TOKEN"),
+			getEnv("VAULT_MOUNT", DefaultVaultMount), getEnv("VAULT_PATH", DefaultVaultPath),
+		)
+		return &vaultStore, nil
+	}
+
+	return nil, fmt.Errorf("unknown secrets store %s", name)
+}
+
+// GetMasterKeyCipher gives the cipher of SECRETS_MASTER_KEY
+// and false if it is not set
+func GetMasterKeyCipher() (Cipher, bool, error) {
+	masterKey := os.Getenv("SECRETS_MASTER_KEY")
+	if len(masterKey) < 1 {
+		return Cipher{}, false, nil
+	}
+	cipher, err := NewCipher(masterKey)
+	if err != nil {
+		return Cipher{}, false, err
+	}
+
+	return cipher, true, nil
+}
+
+func getEnv(name, defaultValue string) string {
+	value := os.Getenv(name)
+	if len(value) < 1 {
+		return defaultValue
+	}
+
+	return value
+}
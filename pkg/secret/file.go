@@ -0,0 +1,158 @@
+package secret
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileStore keeps the secrets as JSON in a file
+// encrypted with the master key
+type FileStore struct {
+	Path    string
+	cipher  Cipher
+	secrets map[string]string
+	mutex   *sync.Mutex
+}
+
+func NewFileStore(path, masterKey string) (FileStore, error) {
+	cipher, err := NewCipher(masterKey)
+	if err != nil {
+		return FileStore{}, err
+	}
+
+	return FileStore{
+		Path:   path,
+		cipher: cipher,
+		mutex:  &sync.Mutex{},
+	}, nil
+}
+
+func (f *FileStore) GetSecret(name string) (string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := f.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not in %s", ErrSecretNotFound, name, f.Path)
+	}
+
+	return value, nil
+}
+
+// SetSecret saves the secret into the file
+func (f *FileStore) SetSecret(name, value string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.load()
+	if err != nil {
+		return err
+	}
+	f.secrets[name] = value
+
+	return f.save()
+}
+
+// DeleteSecret removes the secret from the file
+func (f *FileStore) DeleteSecret(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.load()
+	if err != nil {
+		return err
+	}
+	delete(f.secrets, name)
+
+	return f.save()
+}
+
+// Names gives the names of the secrets in the file
+func (f *FileStore) Names() ([]string, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for name := range f.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+func (f *FileStore) load() error {
+	if f.secrets != nil {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			f.secrets = map[string]string{}
+			return nil
+		}
+		return err
+	}
+	encrypted, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return err
+	}
+	plaintext, err := f.cipher.Decrypt(encrypted)
+	if err != nil {
+		return err
+	}
+	secrets := map[string]string{}
+	err = json.Unmarshal(plaintext, &secrets)
+	if err != nil {
+		return err
+	}
+	f.secrets = secrets
+
+	return nil
+}
+
+func (f *FileStore) save() error {
+	plaintext, err := json.Marshal(f.secrets)
+	if err != nil {
+		return err
+	}
+	encrypted, err := f.cipher.Encrypt(plaintext)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(f.Path)
+	temp, err := ioutil.TempFile(dir, filepath.Base(f.Path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = temp.WriteString(base64.StdEncoding.EncodeToString(encrypted))
+	if err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	err = temp.Close()
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	return os.Rename(temp.Name(), f.Path)
+}
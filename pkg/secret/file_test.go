@@ -0,0 +1,82 @@
+package secret
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store, err := NewFileStore(path, "master key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a missing file has no secrets yet
+	_, err = store.GetSecret("KITE_PASSWORD")
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Fatalf("got error %v, want %v", err, ErrSecretNotFound)
+	}
+	for name, value := range map[string]string{"KITE_PASSWORD": "password", "TOTP_KEY": "totp key", "KITE_APISECRET": "api secret"} {
+		err = store.SetSecret(name, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = store.DeleteSecret("KITE_APISECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "password") {
+		t.Errorf("got the secrets in the clear in %s", path)
+	}
+
+	// a new store reads the file back
+	reopened, err := NewFileStore(path, "master key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := reopened.GetSecret("KITE_PASSWORD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "password" {
+		t.Errorf("got %s, want password", value)
+	}
+	names, err := reopened.Names()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"KITE_PASSWORD", "TOTP_KEY"}) {
+		t.Errorf("got names %v, want KITE_PASSWORD and TOTP_KEY", names)
+	}
+}
+
+func TestFileStoreWrongMasterKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	store, err := NewFileStore(path, "master key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.SetSecret("KITE_PASSWORD", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrong, err := NewFileStore(path, "another key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wrong.GetSecret("KITE_PASSWORD")
+	if err == nil || errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("got error %v, want decrypting to fail", err)
+	}
+}
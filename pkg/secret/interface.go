@@ -0,0 +1,11 @@
+package secret
+
+import "errors"
+
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Store gives the secrets like passwords, api secrets
+// and TOTP keys by their name, e.g. KITE_PASSWORD
+type Store interface {
+	GetSecret(name string) (string, error)
+}
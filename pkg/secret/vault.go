@@ -0,0 +1,100 @@
+package secret
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// VaultStore reads the secrets from a key value version 2
+// engine of HashiCorp Vault, like the one of `vault server -dev`.
+// All the secrets are fields of the one secret at Path.
+type VaultStore struct {
+	Address string
+	Token   string
+	Mount   string
+	Path    string
+	client  *http.Client
+	secrets map[string]string
+	mutex   *sync.Mutex
+}
+
+type vaultResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func NewVaultStore(address, token, mount, path string) VaultStore {
+	return VaultStore{
+		Address: strings.TrimSuffix(address, "/"),
+		Token:   token,
+		Mount:   mount,
+		Path:    path,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		mutex:   &sync.Mutex{},
+	}
+}
+
+func (v *VaultStore) GetSecret(name string) (string, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if v.secrets == nil {
+		secrets, err := v.fetch()
+		if err != nil {
+			return "", err
+		}
+		v.secrets = secrets
+	}
+	value, ok := v.secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s is not in vault at %s/%s", ErrSecretNotFound, name, v.Mount, v.Path)
+	}
+
+	return value, nil
+}
+
+func (v *VaultStore) fetch() (map[string]string, error) {
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/v1/%s/data/%s", v.Address, v.Mount, v.Path), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("X-Vault-Token", v.Token)
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var response vaultResponse
+	if resp.StatusCode != http.StatusOK {
+		// the errors are json from vault but a proxy
+		// in front of it may answer with anything
+		message := strings.TrimSpace(string(body))
+		if json.Unmarshal(body, &response) == nil && len(response.Errors) > 0 {
+			message = strings.Join(response.Errors, ", ")
+		}
+		return nil, fmt.Errorf("reading secrets from vault failed with status %d and errors %s", resp.StatusCode, message)
+	}
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := map[string]string{}
+	for name, value := range response.Data.Data {
+		secrets[name] = fmt.Sprint(value)
+	}
+
+	return secrets, nil
+}
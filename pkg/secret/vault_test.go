@@ -0,0 +1,62 @@
+package secret
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVaultStore(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors": ["permission denied"]}`))
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/strategies" {
+			// like a proxy in front of vault
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>bad gateway</html>"))
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"KITE_PASSWORD": "password"}}}`))
+	}))
+	defer server.Close()
+
+	store := NewVaultStore(server.URL+"/", "token", DefaultVaultMount, DefaultVaultPath)
+	value, err := store.GetSecret("KITE_PASSWORD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value != "password" {
+		t.Errorf("got %s, want password", value)
+	}
+	_, err = store.GetSecret("TOTP_KEY")
+	if !errors.Is(err, ErrSecretNotFound) {
+		t.Errorf("got error %v, want %v", err, ErrSecretNotFound)
+	}
+
+	tests := []struct {
+		name  string
+		store VaultStore
+		want  string
+	}{
+		{"vault errors", NewVaultStore(server.URL, "wrong", DefaultVaultMount, DefaultVaultPath), "status 403 and errors permission denied"},
+		{"not json", NewVaultStore(server.URL, "token", "kv", DefaultVaultPath), "status 502 and errors <html>bad gateway</html>"},
+	}
+	for _, test := range tests {
+		_, err := test.store.GetSecret("KITE_PASSWORD")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("got error %v with %s, want %s", err, test.name, test.want)
+		}
+	}
+}
+
+func TestGetUnknownStore(t *testing.T) {
+	store, err := GetStore("keychain")
+	if err == nil || store != nil {
+		t.Errorf("got store %v and error %v, want an unknown secrets store error", store, err)
+	}
+}
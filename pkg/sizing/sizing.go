@@ -0,0 +1,230 @@
+package sizing
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const (
+	// MethodFixed trades the lots of the strategy
+	// times the lot multiplier of the account
+	MethodFixed = "fixed"
+	// MethodMargin trades as many lots as a percentage
+	// of the available margin allows
+	MethodMargin = "margin"
+	// MethodCapital trades as many lots as the
+	// capital allocated to the strategy allows
+	MethodCapital = "capital"
+	// MethodRisk trades as many lots as keep the loss at
+	// the stop loss within the maximum loss per trade
+	MethodRisk = "risk"
+)
+
+// SizingConfig decides how many lots the strategies trade
+type SizingConfig struct {
+	Method           string
+	MarginPercentage float64
+	Capital          float64
+	MaxLoss          float64
+	// MinLots and MaxLots cap the lots of every method, a
+	// trade below MinLots is not taken and zero MaxLots
+	// means no cap
+	MinLots int
+	MaxLots int
+}
+
+func DefaultSizingConfig() SizingConfig {
+	return SizingConfig{
+		Method:           MethodFixed,
+		MarginPercentage: 100,
+		MinLots:          1,
+	}
+}
+
+// NewSizingConfig returns the default sizing config overridden
+// by the SIZING_* environment variables of the account
+func NewSizingConfig(account account.Account) (SizingConfig, error) {
+	var err error
+	config := DefaultSizingConfig()
+
+	if value := account.Getenv("SIZING"); len(value) > 0 {
+		switch value {
+		case MethodFixed, MethodMargin, MethodCapital, MethodRisk:
+			config.Method = value
+		default:
+			return SizingConfig{}, fmt.Errorf("invalid %s %s", account.Key("SIZING"), value)
+		}
+	}
+	if value := account.Getenv("SIZING_MARGIN_PERCENTAGE"); len(value) > 0 {
+		config.MarginPercentage, err = strconv.ParseFloat(value, 64)
+		if err != nil || config.MarginPercentage <= 0 || config.MarginPercentage > 100 {
+			return SizingConfig{}, fmt.Errorf("invalid %s %s", account.Key("SIZING_MARGIN_PERCENTAGE"), value)
+		}
+	}
+	if value := account.Getenv("SIZING_CAPITAL"); len(value) > 0 {
+		config.Capital, err = strconv.ParseFloat(value, 64)
+		if err != nil || config.Capital < 0 {
+			return SizingConfig{}, fmt.Errorf("invalid %s %s", account.Key("SIZING_CAPITAL"), value)
+		}
+	}
+	if value := account.Getenv("SIZING_MAX_LOSS"); len(value) > 0 {
+		config.MaxLoss, err = strconv.ParseFloat(value, 64)
+		if err != nil || config.MaxLoss < 0 {
+			return SizingConfig{}, fmt.Errorf("invalid %s %s", account.Key("SIZING_MAX_LOSS"), value)
+		}
+	}
+	if value := account.Getenv("SIZING_MIN_LOTS"); len(value) > 0 {
+		config.MinLots, err = strconv.Atoi(value)
+		if err != nil || config.MinLots < 1 {
+			return SizingConfig{}, fmt.Errorf("invalid %s %s", account.Key("SIZING_MIN_LOTS"), value)
+		}
+	}
+	if value := account.Getenv("SIZING_MAX_LOTS"); len(value) > 0 {
+		config.MaxLots, err = strconv.Atoi(value)
+		if err != nil || config.MaxLots < 0 {
+			return SizingConfig{}, fmt.Errorf("invalid %s %s", account.Key("SIZING_MAX_LOTS"), value)
+		}
+	}
+
+	if config.Method == MethodCapital && config.Capital <= 0 {
+		return SizingConfig{}, fmt.Errorf("%s capital needs %s", account.Key("SIZING"), account.Key("SIZING_CAPITAL"))
+	}
+	if config.Method == MethodRisk && config.MaxLoss <= 0 {
+		return SizingConfig{}, fmt.Errorf("%s risk needs %s", account.Key("SIZING"), account.Key("SIZING_MAX_LOSS"))
+	}
+	if config.MaxLots > 0 && config.MaxLots < config.MinLots {
+		return SizingConfig{}, fmt.Errorf("%s is below %s", account.Key("SIZING_MAX_LOTS"), account.Key("SIZING_MIN_LOTS"))
+	}
+
+	return config, nil
+}
+
+// Trade is what the sizer needs to know of a trade
+type Trade struct {
+	// Legs are the orders of a lot of the trade
+	Legs models.Orders
+	// Lots are the lots the strategy trades with fixed sizing
+	Lots int
+	// LossPerLot is the loss of a lot at the stop loss
+	LossPerLot float64
+	// Scale multiplies the lots before the caps, like for
+	// a resized entry, zero keeps them as they are and the
+	// scaled lots are kept at MinLots at least
+	Scale float64
+}
+
+// Sizer gives the lots of the trades of an account
+type Sizer struct {
+	Config        SizingConfig
+	Broker        broker.Broker
+	LotMultiplier int
+}
+
+func NewSizer(account account.Account, broker broker.Broker) (Sizer, error) {
+	config, err := NewSizingConfig(account)
+	if err != nil {
+		return Sizer{}, err
+	}
+
+	return Sizer{
+		Config:        config,
+		Broker:        broker,
+		LotMultiplier: account.LotMultiplier,
+	}, nil
+}
+
+// Lots gives the lots of the trade within the caps
+func (s Sizer) Lots(trade Trade) (int, error) {
+	var lots int
+	switch s.Config.Method {
+	case MethodFixed, "":
+		multiplier := s.LotMultiplier
+		if multiplier < 1 {
+			multiplier = 1
+		}
+		lots = trade.Lots * multiplier
+	case MethodMargin:
+		available, err := s.Broker.GetMargin()
+		if err != nil {
+			return 0, err
+		}
+		lots, err = s.lotsFor(available*s.Config.MarginPercentage/100, trade)
+		if err != nil {
+			return 0, err
+		}
+	case MethodCapital:
+		var err error
+		lots, err = s.lotsFor(s.Config.Capital, trade)
+		if err != nil {
+			return 0, err
+		}
+	case MethodRisk:
+		if trade.LossPerLot <= 0 {
+			return 0, fmt.Errorf("risk sizing needs the loss of a lot at the stop loss")
+		}
+		lots = int(math.Floor(s.Config.MaxLoss / trade.LossPerLot))
+	default:
+		return 0, fmt.Errorf("unknown sizing method %s", s.Config.Method)
+	}
+
+	if trade.Scale > 0 {
+		// a resized trade is a smaller trade, not none, so
+		// the scale never takes the lots below MinLots
+		scaled := int(float64(lots) * trade.Scale)
+		if scaled < s.Config.MinLots && lots >= s.Config.MinLots {
+			scaled = s.Config.MinLots
+		}
+		lots = scaled
+	}
+	if s.Config.MaxLots > 0 && lots > s.Config.MaxLots {
+		lots = s.Config.MaxLots
+	}
+	if lots < s.Config.MinLots {
+		return 0, fmt.Errorf("%s sizing gives %d lots, less than the minimum of %d", s.Config.Method, lots, s.Config.MinLots)
+	}
+	log.Printf("Sizing the trade with %s to %d lots", s.Config.Method, lots)
+
+	return lots, nil
+}
+
+// SizeLegs sizes the trade of the legs, which are a lot
+// each, and sets their quantity to the lots it gives
+func (s Sizer) SizeLegs(trade Trade, legs ...*models.Order) (int, error) {
+	trade.Legs = models.Orders{}
+	for _, leg := range legs {
+		oneLot := *leg
+		oneLot.Quantity = leg.LotSize
+		trade.Legs = append(trade.Legs, oneLot)
+	}
+	lots, err := s.Lots(trade)
+	if err != nil {
+		return 0, err
+	}
+	for _, leg := range legs {
+		leg.Quantity = lots * leg.LotSize
+	}
+
+	return lots, nil
+}
+
+// lotsFor gives the lots the amount is enough margin for
+func (s Sizer) lotsFor(amount float64, trade Trade) (int, error) {
+	if len(trade.Legs) <= 0 {
+		return 0, fmt.Errorf("%s sizing needs the legs of the trade", s.Config.Method)
+	}
+	marginPerLot, err := s.Broker.GetBasketMargin(trade.Legs)
+	if err != nil {
+		return 0, err
+	}
+	if marginPerLot <= 0 {
+		return 0, fmt.Errorf("no margin for a lot of %s", trade.Legs[0].TradingSymbol)
+	}
+
+	return int(math.Floor(amount / marginPerLot)), nil
+}
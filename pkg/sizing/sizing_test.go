@@ -0,0 +1,116 @@
+package sizing
+
+import (
+	"testing"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+func newTestSizer(t *testing.T, config SizingConfig) Sizer {
+	mockBroker, err := broker.NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a lot of the straddle needs 50 * (600 + 400) of margin
+	mockBroker.Margin = 250000
+	mockBroker.Margins["NIFTY2410421500CE"] = 600
+	mockBroker.Margins["NIFTY2410421500PE"] = 400
+
+	return Sizer{Config: config, Broker: &mockBroker, LotMultiplier: 2}
+}
+
+func straddle() models.Orders {
+	return models.Orders{
+		{Instrument: models.Instrument{TradingSymbol: "NIFTY2410421500CE", LotSize: 50}, Quantity: 50},
+		{Instrument: models.Instrument{TradingSymbol: "NIFTY2410421500PE", LotSize: 50}, Quantity: 50},
+	}
+}
+
+func TestLots(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SizingConfig
+		trade   Trade
+		want    int
+		wantErr bool
+	}{
+		{"fixed times the lot multiplier", SizingConfig{Method: MethodFixed, MinLots: 1}, Trade{Lots: 3}, 6, false},
+		{"fixed capped at MaxLots", SizingConfig{Method: MethodFixed, MinLots: 1, MaxLots: 4}, Trade{Lots: 3}, 4, false},
+		{"fixed below MinLots", SizingConfig{Method: MethodFixed, MinLots: 8}, Trade{Lots: 3}, 0, true},
+		{"margin of all the margin", SizingConfig{Method: MethodMargin, MarginPercentage: 100, MinLots: 1}, Trade{Legs: straddle()}, 5, false},
+		{"margin of a percentage of the margin", SizingConfig{Method: MethodMargin, MarginPercentage: 50, MinLots: 1}, Trade{Legs: straddle()}, 2, false},
+		{"margin without legs", SizingConfig{Method: MethodMargin, MarginPercentage: 100, MinLots: 1}, Trade{}, 0, true},
+		{"capital", SizingConfig{Method: MethodCapital, Capital: 160000, MinLots: 1}, Trade{Legs: straddle()}, 3, false},
+		{"capital below a lot", SizingConfig{Method: MethodCapital, Capital: 40000, MinLots: 1}, Trade{Legs: straddle()}, 0, true},
+		{"risk", SizingConfig{Method: MethodRisk, MaxLoss: 10000, MinLots: 1}, Trade{LossPerLot: 3000}, 3, false},
+		{"risk without the loss of a lot", SizingConfig{Method: MethodRisk, MaxLoss: 10000, MinLots: 1}, Trade{}, 0, true},
+		{"risk capped at MaxLots", SizingConfig{Method: MethodRisk, MaxLoss: 10000, MinLots: 1, MaxLots: 2}, Trade{LossPerLot: 1000}, 2, false},
+		{"unknown method", SizingConfig{Method: "kelly", MinLots: 1}, Trade{Lots: 1}, 0, true},
+		{"scaled", SizingConfig{Method: MethodFixed, MinLots: 1}, Trade{Lots: 3, Scale: 0.5}, 3, false},
+		{"scaled before MaxLots", SizingConfig{Method: MethodFixed, MinLots: 1, MaxLots: 2}, Trade{Lots: 3, Scale: 0.5}, 2, false},
+		{"scaled below MinLots keeps MinLots", SizingConfig{Method: MethodFixed, MinLots: 1}, Trade{Lots: 1, Scale: 0.2}, 1, false},
+		{"scaled below a MinLots the trade is below", SizingConfig{Method: MethodRisk, MaxLoss: 1000, MinLots: 1}, Trade{LossPerLot: 3000, Scale: 0.5}, 0, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sizer := newTestSizer(t, test.config)
+			got, err := sizer.Lots(test.trade)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %d lots, want %d", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSizeLegs(t *testing.T) {
+	sizer := newTestSizer(t, SizingConfig{Method: MethodMargin, MarginPercentage: 100, MinLots: 1})
+	legs := straddle()
+	// the quantities of the legs are not a lot
+	legs[0].Quantity = 500
+	legs[1].Quantity = 0
+
+	lots, err := sizer.SizeLegs(Trade{}, &legs[0], &legs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lots != 5 || legs[0].Quantity != 250 || legs[1].Quantity != 250 {
+		t.Errorf("got %d lots of %d and %d, want 5 lots of 250", lots, legs[0].Quantity, legs[1].Quantity)
+	}
+}
+
+func TestNewSizingConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    SizingConfig
+		wantErr bool
+	}{
+		{"default", map[string]string{}, DefaultSizingConfig(), false},
+		{"margin", map[string]string{"SIZING_TEST": "margin", "SIZING_MARGIN_PERCENTAGE_TEST": "40", "SIZING_MAX_LOTS_TEST": "10"}, SizingConfig{Method: MethodMargin, MarginPercentage: 40, MinLots: 1, MaxLots: 10}, false},
+		{"unknown method", map[string]string{"SIZING_TEST": "kelly"}, SizingConfig{}, true},
+		{"margin percentage above 100", map[string]string{"SIZING_MARGIN_PERCENTAGE_TEST": "120"}, SizingConfig{}, true},
+		{"capital without capital", map[string]string{"SIZING_TEST": "capital"}, SizingConfig{}, true},
+		{"risk without the maximum loss", map[string]string{"SIZING_TEST": "risk"}, SizingConfig{}, true},
+		{"MinLots of 0", map[string]string{"SIZING_MIN_LOTS_TEST": "0"}, SizingConfig{}, true},
+		{"MaxLots below MinLots", map[string]string{"SIZING_MIN_LOTS_TEST": "3", "SIZING_MAX_LOTS_TEST": "2"}, SizingConfig{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			got, err := NewSizingConfig(account.Account{Name: "test"})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, want error %t", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
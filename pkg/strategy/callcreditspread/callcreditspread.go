@@ -1,12 +1,14 @@
 package callcreditspread
 
 import (
+	"fmt"
 	"log"
 	"time"
 
 	"github.com/rohitsakala/strategies/pkg/broker"
 	"github.com/rohitsakala/strategies/pkg/database"
 	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/strategy"
 	"github.com/rohitsakala/strategies/pkg/utils/maths"
 	"github.com/rohitsakala/strategies/pkg/utils/options"
 	"github.com/rohitsakala/strategies/pkg/watcher"
@@ -23,7 +25,34 @@ type CallCreditSpreadStrategy struct {
 	Watcher                        watcher.Watcher
 }
 
-func NewCallCreditSpreadStrategy(broker broker.Broker, timeZone time.Location, database database.Database, watcher watcher.Watcher) (CallCreditSpreadStrategy, error) {
+func init() {
+	strategy.Register(strategy.Definition{
+		Name:        "callcreditspread",
+		Description: "Sells a far OTM NIFTY PE with a stop loss",
+		Parameters: []strategy.Parameter{
+			{Name: "strikepercentage", Type: strategy.IntParameter, Default: "11", Description: "how far below the spot in percent the PE is sold"},
+			{Name: "stoplossmultiple", Type: strategy.IntParameter, Default: "500", Description: "stop loss as percent of the premium"},
+		},
+		Validate: func(parameters strategy.Parameters) error {
+			if parameters.Int("strikepercentage") <= 0 || parameters.Int("strikepercentage") >= 100 {
+				return fmt.Errorf("strikepercentage %d is not between 0 and 100", parameters.Int("strikepercentage"))
+			}
+			if parameters.Int("stoplossmultiple") <= 0 {
+				return fmt.Errorf("stoplossmultiple %d is not positive", parameters.Int("stoplossmultiple"))
+			}
+			return nil
+		},
+		New: func(dependencies strategy.Dependencies, parameters strategy.Parameters) (strategy.Strategy, error) {
+			callcreditspread, err := NewCallCreditSpreadStrategy(dependencies.Broker, dependencies.TimeZone, dependencies.Database, dependencies.Watcher, parameters.Int("strikepercentage"), parameters.Int("stoplossmultiple"))
+			if err != nil {
+				return nil, err
+			}
+			return &callcreditspread, nil
+		},
+	})
+}
+
+func NewCallCreditSpreadStrategy(broker broker.Broker, timeZone time.Location, database database.Database, watcher watcher.Watcher, strikePricePercentage, stopLossMultiple int) (CallCreditSpreadStrategy, error) {
 	// Create a collection in the database
 	err := database.CreateCollection("callcreditspread")
 	if err != nil {
@@ -34,8 +63,8 @@ func NewCallCreditSpreadStrategy(broker broker.Broker, timeZone time.Location, d
 	return CallCreditSpreadStrategy{
 		Broker:                         broker,
 		TimeZone:                       timeZone,
-		SellingPEStopLossMultiple:      500,
-		SellingPEStrikePricePercentage: 11,
+		SellingPEStopLossMultiple:      stopLossMultiple,
+		SellingPEStrikePricePercentage: strikePricePercentage,
 		Database:                       database,
 		Watcher:                        watcher,
 	}, nil
@@ -43,7 +72,7 @@ func NewCallCreditSpreadStrategy(broker broker.Broker, timeZone time.Location, d
 
 func (c *CallCreditSpreadStrategy) Start() error {
 	// Start PE Selling leg
-	sellPEPosition := models.Position{Type: "PE"}
+	sellPEPosition := models.Order{Instrument: models.Instrument{InstrumentType: "PE"}}
 
 	// Check if database has PE Selling leg
 	collectionRaw, err := c.Database.GetCollection(bson.D{}, "callcreditspread")
@@ -54,7 +83,11 @@ func (c *CallCreditSpreadStrategy) Start() error {
 	}
 
 	// Get NIFTY 50 LTP
-	LTP, err := c.Broker.GetLTP("NIFTY 50")
+	indexSymbol, err := options.GetIndexSymbol("NIFTY", c.Broker)
+	if err != nil {
+		return err
+	}
+	LTP, err := c.Broker.GetLTP(indexSymbol)
 	if err != nil {
 		return err
 	}
@@ -79,11 +112,11 @@ func (c *CallCreditSpreadStrategy) Start() error {
 	sellPEPosition.TradingSymbol = PEOptionSymbol
 	log.Printf("NIFTY PE Symbol : %s", PEOptionSymbol)
 
-	sellPEPosition.SellPrice, err = c.Broker.GetLTP(sellPEPosition.TradingSymbol)
+	sellPEPosition.Price, err = c.Broker.GetLTP(sellPEPosition.TradingSymbol)
 	if err != nil {
 		return err
 	}
-	log.Printf("NIFTY PE Symbol LTP : %f", sellPEPosition.SellPrice)
+	log.Printf("NIFTY PE Symbol LTP : %f", sellPEPosition.Price)
 
 	// Check if position already exists
 	_, err = c.Broker.CheckPosition(sellPEPosition.TradingSymbol)
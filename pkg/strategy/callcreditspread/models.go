@@ -5,7 +5,7 @@ import (
 )
 
 type CallCreditSpreadStrategyPositions struct {
-	SellPEOptionPoistion  models.Position
-	BuyCEOptionPosition   models.Position
-	SellCEOptionsPosition models.Position
+	SellPEOptionPoistion  models.Order
+	BuyCEOptionPosition   models.Order
+	SellCEOptionsPosition models.Order
 }
@@ -0,0 +1,80 @@
+package filter
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const eventDayFormat = "2006-01-02"
+
+// EventCalendar are the names of the events, like the RBI
+// policy, the budget or results, by their day
+type EventCalendar map[string][]string
+
+// LoadEventCalendar reads the calendar from a file with a
+// line per event, like `2024-02-01 Union Budget`. Blank
+// lines and lines starting with # are left out.
+func LoadEventCalendar(path string) (EventCalendar, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	calendar := EventCalendar{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) < 1 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		day, err := time.Parse(eventDayFormat, fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid day %s on line %d of %s, expected YYYY-MM-DD", fields[0], line, path)
+		}
+		name := "event"
+		if len(fields) > 1 {
+			name = strings.TrimSpace(fields[1])
+		}
+		key := day.Format(eventDayFormat)
+		calendar[key] = append(calendar[key], name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return calendar, nil
+}
+
+// Events gives the events on the day of the time
+func (c EventCalendar) Events(t time.Time) []string {
+	return c[t.Format(eventDayFormat)]
+}
+
+// EventFilter skips the entry on the days of the events
+// of the calendar or resizes it by Scale if it is set
+type EventFilter struct {
+	Calendar EventCalendar
+	Scale    float64
+}
+
+func (f EventFilter) Name() string {
+	return "event"
+}
+
+func (f EventFilter) Check(market Market) (Decision, error) {
+	events := f.Calendar.Events(market.Now.In(&market.TimeZone))
+	if len(events) <= 0 {
+		return Trade(), nil
+	}
+	reason := fmt.Sprintf("today is %s", strings.Join(events, ", "))
+	if f.Scale > 0 {
+		return Resize(f.Scale, reason), nil
+	}
+
+	return Skip(reason), nil
+}
@@ -0,0 +1,122 @@
+package filter
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/utils/options"
+)
+
+// marketOpen is when the candles of the day start
+const marketOpen = 9*time.Hour + 15*time.Minute
+
+// Market is what the filters look at before an entry
+type Market struct {
+	Broker     broker.Broker
+	Underlying string
+	TimeZone   time.Location
+	Now        time.Time
+}
+
+// Decision of a filter on the entry. Scale multiplies
+// the lots of the entry, one keeps them as they are.
+type Decision struct {
+	Skip   bool
+	Scale  float64
+	Reason string
+}
+
+// Trade lets the entry go ahead as it is
+func Trade() Decision {
+	return Decision{Scale: 1}
+}
+
+func Skip(reason string) Decision {
+	return Decision{Skip: true, Scale: 0, Reason: reason}
+}
+
+func Resize(scale float64, reason string) Decision {
+	return Decision{Scale: scale, Reason: reason}
+}
+
+// Filter decides whether a strategy enters
+// under the conditions of the market
+type Filter interface {
+	Name() string
+	Check(market Market) (Decision, error)
+}
+
+// Chain is evaluated before a strategy places its legs. The
+// first filter skipping the entry ends it and the scales of
+// the filters resizing the entry multiply.
+type Chain []Filter
+
+func (c Chain) Evaluate(market Market) (Decision, error) {
+	result := Trade()
+	reasons := []string{}
+	for _, filter := range c {
+		decision, err := filter.Check(market)
+		if err != nil {
+			return Decision{}, fmt.Errorf("%s filter: %w", filter.Name(), err)
+		}
+		if len(decision.Reason) > 0 {
+			log.Printf("%s filter: %s", filter.Name(), decision.Reason)
+			reasons = append(reasons, decision.Reason)
+		}
+		if decision.Skip {
+			return Skip(strings.Join(reasons, ", ")), nil
+		}
+		result.Scale *= decision.Scale
+	}
+	result.Reason = strings.Join(reasons, ", ")
+
+	return result, nil
+}
+
+// todayCandles gives the minute candles of the
+// underlying index from the open till now
+func todayCandles(market Market) (models.Candles, error) {
+	indexSymbol, err := options.GetIndexSymbol(market.Underlying, market.Broker)
+	if err != nil {
+		return nil, err
+	}
+	now := market.Now.In(&market.TimeZone)
+	open := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, &market.TimeZone).Add(marketOpen)
+	candles, err := options.GetCandles(indexSymbol, models.IntervalMinute, open, now, market.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) <= 0 {
+		return nil, fmt.Errorf("no candles of %s today", indexSymbol)
+	}
+
+	return candles, nil
+}
+
+// dailyCandles gives the day candles of the underlying
+// index of the days before today, at most days of them
+func dailyCandles(market Market, days int) (models.Candles, error) {
+	indexSymbol, err := options.GetIndexSymbol(market.Underlying, market.Broker)
+	if err != nil {
+		return nil, err
+	}
+	now := market.Now.In(&market.TimeZone)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, &market.TimeZone)
+	// twice the days leaves room for the weekends and holidays
+	candles, err := options.GetCandles(indexSymbol, models.IntervalDay, today.AddDate(0, 0, -2*days-7), today.Add(-time.Second), market.Broker)
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) <= 0 {
+		return nil, fmt.Errorf("no day candles of %s before today", indexSymbol)
+	}
+	if len(candles) > days {
+		candles = candles[len(candles)-days:]
+	}
+
+	return candles, nil
+}
@@ -0,0 +1,94 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/rohitsakala/strategies/pkg/utils/indicators"
+)
+
+// GapFilter skips the entry when the underlying opened
+// more than MaxPercentage away from the close before
+type GapFilter struct {
+	MaxPercentage float64
+}
+
+func (f GapFilter) Name() string {
+	return "gap"
+}
+
+func (f GapFilter) Check(market Market) (Decision, error) {
+	days, err := dailyCandles(market, 1)
+	if err != nil {
+		return Decision{}, err
+	}
+	today, err := todayCandles(market)
+	if err != nil {
+		return Decision{}, err
+	}
+	previousClose := days[len(days)-1].Close
+	open := today[0].Open
+	if previousClose <= 0 {
+		return Decision{}, fmt.Errorf("previous close of %s is %f", market.Underlying, previousClose)
+	}
+
+	gap := (open - previousClose) / previousClose * 100
+	if math.Abs(gap) > f.MaxPercentage {
+		return Skip(fmt.Sprintf("%s opened with a gap of %.2f%%, more than %.2f%%", market.Underlying, gap, f.MaxPercentage)), nil
+	}
+
+	return Trade(), nil
+}
+
+// RangeFilter skips the entry when the range of the day so
+// far is more than MaxPercentage of the open or more than
+// MaxATRMultiple of the ATR of the days before. A zero
+// limit is not checked.
+type RangeFilter struct {
+	MaxPercentage  float64
+	MaxATRMultiple float64
+	ATRPeriod      int
+}
+
+func (f RangeFilter) Name() string {
+	return "range"
+}
+
+func (f RangeFilter) Check(market Market) (Decision, error) {
+	today, err := todayCandles(market)
+	if err != nil {
+		return Decision{}, err
+	}
+	high, low := math.Inf(-1), math.Inf(1)
+	for _, candle := range today {
+		high = math.Max(high, candle.High)
+		low = math.Min(low, candle.Low)
+	}
+	dayRange := high - low
+
+	if f.MaxPercentage > 0 {
+		percentage := dayRange / today[0].Open * 100
+		if percentage > f.MaxPercentage {
+			return Skip(fmt.Sprintf("%s moved %.2f%% today, more than %.2f%%", market.Underlying, percentage, f.MaxPercentage)), nil
+		}
+	}
+	if f.MaxATRMultiple > 0 {
+		atr, err := indicators.NewATR(f.ATRPeriod)
+		if err != nil {
+			return Decision{}, err
+		}
+		days, err := dailyCandles(market, f.ATRPeriod+1)
+		if err != nil {
+			return Decision{}, err
+		}
+		value, err := indicators.Last(&atr, days)
+		if err != nil {
+			return Decision{}, err
+		}
+		if dayRange > f.MaxATRMultiple*value {
+			return Skip(fmt.Sprintf("%s moved %.2f today, more than %.2f times the ATR %.2f", market.Underlying, dayRange, f.MaxATRMultiple, value)), nil
+		}
+	}
+
+	return Trade(), nil
+}
@@ -0,0 +1,44 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/rohitsakala/strategies/pkg/utils/options"
+)
+
+const vixUnderlying = "INDIAVIX"
+
+// VIXFilter skips the entry above Max and resizes it by
+// Scale above ResizeAbove. A zero level is not checked.
+type VIXFilter struct {
+	Max         float64
+	ResizeAbove float64
+	Scale       float64
+}
+
+func (f VIXFilter) Name() string {
+	return "vix"
+}
+
+func (f VIXFilter) Check(market Market) (Decision, error) {
+	vixSymbol, err := options.GetIndexSymbol(vixUnderlying, market.Broker)
+	if err != nil {
+		return Decision{}, err
+	}
+	vix, err := options.GetLTP(vixSymbol, market.Broker)
+	if err != nil {
+		return Decision{}, err
+	}
+	if vix <= 0 {
+		return Decision{}, fmt.Errorf("no LTP of %s", vixSymbol)
+	}
+
+	if f.Max > 0 && vix > f.Max {
+		return Skip(fmt.Sprintf("India VIX %.2f is above %.2f", vix, f.Max)), nil
+	}
+	if f.ResizeAbove > 0 && vix > f.ResizeAbove {
+		return Resize(f.Scale, fmt.Sprintf("India VIX %.2f is above %.2f", vix, f.ResizeAbove)), nil
+	}
+
+	return Trade(), nil
+}
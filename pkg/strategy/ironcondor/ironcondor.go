@@ -0,0 +1,641 @@
+package ironcondor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/reconciler"
+	"github.com/rohitsakala/strategies/pkg/sizing"
+	"github.com/rohitsakala/strategies/pkg/strategy"
+	"github.com/rohitsakala/strategies/pkg/utils"
+	"github.com/rohitsakala/strategies/pkg/utils/duration"
+	"github.com/rohitsakala/strategies/pkg/utils/maths"
+	"github.com/rohitsakala/strategies/pkg/utils/options"
+	"github.com/rohitsakala/strategies/pkg/watcher"
+)
+
+const (
+	IronCondorStrategyDatabaseName    = "ironcondor"
+	IronButterflyStrategyDatabaseName = "ironbutterfly"
+	IronCondorBasketTimeout           = 3 * time.Minute
+)
+
+func init() {
+	strategy.Register(definition(IronCondorStrategyDatabaseName, false))
+	strategy.Register(definition(IronButterflyStrategyDatabaseName, true))
+}
+
+// definition registers the butterfly as a condor
+// which always sells the ATM strikes
+func definition(name string, butterfly bool) strategy.Definition {
+	parameters := []strategy.Parameter{
+		{Name: "product", Type: strategy.StringParameter, Default: string(models.ProductNRML), Choices: []string{string(models.ProductMIS), string(models.ProductNRML)}, Description: "product type of the orders"},
+		{Name: "underlying", Type: strategy.StringParameter, Default: "NIFTY"},
+		{Name: "lots", Type: strategy.IntParameter, Default: "1", Description: "lots of every leg before the lot multiplier of the account"},
+		{Name: "expiryoffset", Type: strategy.IntParameter, Default: "0", Description: "0 is the current weekly expiry, 1 the next one"},
+		{Name: "strikestep", Type: strategy.FloatParameter, Default: "50", Description: "difference between the strikes of the underlying"},
+	}
+	if !butterfly {
+		parameters = append(parameters, strategy.Parameter{Name: "shortdistance", Type: strategy.FloatParameter, Default: "200", Description: "points of the short strikes away from ATM"})
+	}
+	parameters = append(parameters, []strategy.Parameter{
+		{Name: "wingwidth", Type: strategy.FloatParameter, Default: "500", Description: "points of the long strikes away from the short strikes"},
+		{Name: "entry", Type: strategy.StringParameter, Default: "12:25", Description: "earliest entry time"},
+		{Name: "entryend", Type: strategy.StringParameter, Default: "15:00", Description: "no entry after this time"},
+		{Name: "exit", Type: strategy.StringParameter, Default: "15:20", Description: "exit time of the open legs"},
+		{Name: "stoploss", Type: strategy.StringParameter, Default: StopLossLeg, Choices: []string{StopLossLeg, StopLossNet, StopLossNone}, Description: "SL order on each short leg or exit on the net loss"},
+		{Name: "stoplosspercentage", Type: strategy.FloatParameter, Default: "30", Description: "of the premium of a short leg or of the net credit"},
+		{Name: "target", Type: strategy.FloatParameter, Default: "0", Description: "percentage of the net credit to exit at, 0 for none"},
+		{Name: "pollinterval", Type: strategy.DurationParameter, Default: "1m"},
+	}...)
+
+	return strategy.Definition{
+		Name:        name,
+		Description: "Sells CE and PE around ATM hedged by wings with per leg or net stop losses and a profit target",
+		Parameters:  parameters,
+		Validate: func(parameters strategy.Parameters) error {
+			_, err := newConfig(parameters, butterfly)
+			return err
+		},
+		New: func(dependencies strategy.Dependencies, parameters strategy.Parameters) (strategy.Strategy, error) {
+			config, err := newConfig(parameters, butterfly)
+			if err != nil {
+				return nil, err
+			}
+			ironCondorStrategy, err := NewIronCondorStrategy(name, config, dependencies.Account, dependencies.Broker, dependencies.TimeZone, dependencies.Watcher, dependencies.Sizer)
+			if err != nil {
+				return nil, err
+			}
+			return &ironCondorStrategy, nil
+		},
+	}
+}
+
+func newConfig(parameters strategy.Parameters, butterfly bool) (IronCondorConfig, error) {
+	config := IronCondorConfig{
+		Underlying:         parameters.String("underlying"),
+		ProductType:        parameters.String("product"),
+		Lots:               parameters.Int("lots"),
+		ExpiryOffset:       parameters.Int("expiryoffset"),
+		StrikeStep:         parameters.Float("strikestep"),
+		ShortDistance:      parameters.Float("shortdistance"),
+		WingWidth:          parameters.Float("wingwidth"),
+		EntryStart:         parameters.String("entry"),
+		EntryEnd:           parameters.String("entryend"),
+		Exit:               parameters.String("exit"),
+		StopLoss:           parameters.String("stoploss"),
+		StopLossPercentage: parameters.Float("stoplosspercentage"),
+		TargetPercentage:   parameters.Float("target"),
+		PollInterval:       parameters.Duration("pollinterval"),
+	}
+	if butterfly {
+		config.ShortDistance = 0
+	}
+
+	if config.Lots < 1 {
+		return IronCondorConfig{}, fmt.Errorf("lots %d is less than 1", config.Lots)
+	}
+	if config.ExpiryOffset < 0 {
+		return IronCondorConfig{}, fmt.Errorf("expiryoffset %d is negative", config.ExpiryOffset)
+	}
+	if config.StrikeStep <= 0 {
+		return IronCondorConfig{}, fmt.Errorf("strikestep %v is not positive", config.StrikeStep)
+	}
+	if config.ShortDistance < 0 {
+		return IronCondorConfig{}, fmt.Errorf("shortdistance %v is negative", config.ShortDistance)
+	}
+	if config.WingWidth < config.StrikeStep {
+		return IronCondorConfig{}, fmt.Errorf("wingwidth %v is less than the strikestep %v", config.WingWidth, config.StrikeStep)
+	}
+	if config.StopLoss != StopLossNone && config.StopLossPercentage <= 0 {
+		return IronCondorConfig{}, fmt.Errorf("stoplosspercentage %v is not positive", config.StopLossPercentage)
+	}
+	if config.TargetPercentage < 0 || config.TargetPercentage > 100 {
+		return IronCondorConfig{}, fmt.Errorf("target %v is not between 0 and 100", config.TargetPercentage)
+	}
+	if config.PollInterval <= 0 {
+		return IronCondorConfig{}, fmt.Errorf("pollinterval %s is not positive", config.PollInterval)
+	}
+
+	var clocks [3]time.Time
+	for i, clock := range []string{config.EntryStart, config.EntryEnd, config.Exit} {
+		var err error
+		clocks[i], err = duration.TodayAt(clock, *time.UTC)
+		if err != nil {
+			return IronCondorConfig{}, err
+		}
+	}
+	if !clocks[0].Before(clocks[1]) || !clocks[1].Before(clocks[2]) {
+		return IronCondorConfig{}, fmt.Errorf("entry %s, entryend %s and exit %s are not in order", config.EntryStart, config.EntryEnd, config.Exit)
+	}
+
+	return config, nil
+}
+
+type IronCondorStrategy struct {
+	Name           string
+	Config         IronCondorConfig
+	EntryStartTime time.Time
+	EntryEndTime   time.Time
+	ExitStartTime  time.Time
+	ExitEndTime    time.Time
+	Data           IronCondorPositions
+	Broker         broker.Broker
+	TimeZone       time.Location
+	Watcher        watcher.Watcher
+	// RunID identifies the run of the day in the order tags
+	RunID   string
+	Account account.Account
+	Sizer   sizing.Sizer
+	// Lots are the lots of the legs, one till they are sized
+	Lots       int
+	Reconciler reconciler.Reconciler
+}
+
+func NewIronCondorStrategy(name string, config IronCondorConfig, account account.Account, broker broker.Broker, timeZone time.Location, watcher watcher.Watcher, sizer sizing.Sizer) (IronCondorStrategy, error) {
+	entryStartTime, err := duration.TodayAt(config.EntryStart, timeZone)
+	if err != nil {
+		return IronCondorStrategy{}, err
+	}
+	entryEndTime, err := duration.TodayAt(config.EntryEnd, timeZone)
+	if err != nil {
+		return IronCondorStrategy{}, err
+	}
+	exitStartTime, err := duration.TodayAt(config.Exit, timeZone)
+	if err != nil {
+		return IronCondorStrategy{}, err
+	}
+	exitEndTime, err := duration.TodayAt("15:30", timeZone)
+	if err != nil {
+		return IronCondorStrategy{}, err
+	}
+	if !exitEndTime.After(exitStartTime) {
+		exitEndTime = exitStartTime.Add(5 * time.Minute)
+	}
+	positionsReconciler, err := reconciler.NewReconciler(account, broker, name)
+	if err != nil {
+		return IronCondorStrategy{}, err
+	}
+
+	return IronCondorStrategy{
+		Name:           name,
+		Config:         config,
+		EntryStartTime: entryStartTime,
+		EntryEndTime:   entryEndTime,
+		ExitStartTime:  exitStartTime,
+		ExitEndTime:    exitEndTime,
+		Broker:         broker,
+		TimeZone:       timeZone,
+		Watcher:        watcher,
+		RunID:          time.Now().In(&timeZone).Format("20060102"),
+		Account:        account,
+		Sizer:          sizer,
+		Lots:           1,
+		Reconciler:     positionsReconciler,
+	}, nil
+}
+
+func (i *IronCondorStrategy) Start() error {
+	// Check if markets are open today ?
+	open, err := i.Broker.IsMarketOpen()
+	if err != nil {
+		return err
+	}
+	if !open {
+		log.Println("Market is closed")
+		return nil
+	}
+	_, err = i.reconcile("startup", false)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Waiting for %s to %s....", i.Config.EntryStart, i.Config.EntryEnd)
+	for !duration.ValidateTime(i.EntryStartTime, i.EntryEndTime, i.TimeZone) {
+		if time.Now().After(i.EntryEndTime) {
+			return fmt.Errorf("entry time till %s is over", i.Config.EntryEnd)
+		}
+		time.Sleep(1 * time.Minute)
+		log.Printf("Time : %v", time.Now().In(&i.TimeZone))
+	}
+	log.Printf("Entering %s to %s.", i.Config.EntryStart, i.Config.EntryEnd)
+
+	indexSymbol, err := options.GetIndexSymbol(i.Config.Underlying, i.Broker)
+	if err != nil {
+		return err
+	}
+	ltp, err := options.GetLTP(indexSymbol, i.Broker)
+	if err != nil {
+		return err
+	}
+	atm := maths.GetNearestMultiple(ltp, i.Config.StrikeStep)
+	shortCE := atm + i.Config.ShortDistance
+	shortPE := atm - i.Config.ShortDistance
+
+	i.Data.BuyCEOptionPosition, err = i.calculateLeg("CE", shortCE+i.Config.WingWidth, models.TransactionTypeBuy, "buyce")
+	if err != nil {
+		return err
+	}
+	i.Data.BuyPEOptionPosition, err = i.calculateLeg("PE", shortPE-i.Config.WingWidth, models.TransactionTypeBuy, "buype")
+	if err != nil {
+		return err
+	}
+	i.Data.SellCEOptionPosition, err = i.calculateLeg("CE", shortCE, models.TransactionTypeSell, "sellce")
+	if err != nil {
+		return err
+	}
+	i.Data.SellPEOptionPosition, err = i.calculateLeg("PE", shortPE, models.TransactionTypeSell, "sellpe")
+	if err != nil {
+		return err
+	}
+	err = i.size()
+	if err != nil {
+		return err
+	}
+	log.Printf("Placing basket of %s %s %s %s with quantity %d....", i.Data.BuyCEOptionPosition.TradingSymbol, i.Data.BuyPEOptionPosition.TradingSymbol,
+		i.Data.SellCEOptionPosition.TradingSymbol, i.Data.SellPEOptionPosition.TradingSymbol, i.Data.SellCEOptionPosition.Quantity)
+
+	basket := models.RefOrders{&i.Data.BuyCEOptionPosition, &i.Data.BuyPEOptionPosition, &i.Data.SellCEOptionPosition, &i.Data.SellPEOptionPosition}
+	err = i.Broker.PlaceBasketOrder(basket, IronCondorBasketTimeout)
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("Placed Buy CE Leg with Avg Price %f, Buy PE Leg with Avg Price %f, CE Leg with Avg Price %f and PE Leg with Avg Price %f for a net credit of %f",
+		i.Data.BuyCEOptionPosition.AveragePrice, i.Data.BuyPEOptionPosition.AveragePrice, i.Data.SellCEOptionPosition.AveragePrice, i.Data.SellPEOptionPosition.AveragePrice, i.credit())
+	log.Println(message)
+	err = i.sendEmail(message)
+	if err != nil {
+		return err
+	}
+
+	if i.Config.StopLoss == StopLossLeg {
+		err = i.placeStopLosses()
+		if err != nil {
+			return err
+		}
+	}
+
+	return i.WaitAndWatch()
+}
+
+func (i *IronCondorStrategy) Stop() error {
+	// Check if markets are open today ?
+	open, err := i.Broker.IsMarketOpen()
+	if err != nil {
+		return err
+	}
+	if !open {
+		log.Println("Market is closed")
+		return nil
+	}
+	if len(i.Data.SellCEOptionPosition.OrderID) < 1 {
+		log.Printf("No positions to exit.")
+		return nil
+	}
+
+	log.Printf("Cancelling all pending orders...")
+	stopLossLegs := models.RefOrders{}
+	for _, stopLossLeg := range (models.RefOrders{&i.Data.SellCEStopLossOptionPosition, &i.Data.SellPEStopLossOptionPosition}) {
+		if len(stopLossLeg.OrderID) > 0 && !stopLossLeg.Status.IsFinal() {
+			stopLossLegs = append(stopLossLegs, stopLossLeg)
+		}
+	}
+	i.Watcher.Remove(stopLossLegs...)
+	err = i.Broker.CancelOrders(stopLossLegs)
+	if err != nil {
+		return err
+	}
+	log.Printf("Cancelled all pending orders.")
+	mismatches, err := i.reconcile("exit", true)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Exiting all current positions...")
+	positionList := models.Orders{}
+	if i.Data.SellCEStopLossOptionPosition.Status != models.StatusComplete {
+		positionList = append(positionList, i.Data.SellCEOptionPosition)
+	}
+	if i.Data.SellPEStopLossOptionPosition.Status != models.StatusComplete {
+		positionList = append(positionList, i.Data.SellPEOptionPosition)
+	}
+	positionList = append(positionList, i.Data.BuyPEOptionPosition, i.Data.BuyCEOptionPosition)
+	// only what the broker still holds is squared off
+	heldList := models.Orders{}
+	for _, position := range positionList {
+		position.Quantity = mismatches.Held(position)
+		if position.Quantity <= 0 {
+			log.Printf("Not exiting %s as it isn't held anymore", position.TradingSymbol)
+			continue
+		}
+		heldList = append(heldList, position)
+	}
+	positionList = heldList
+	err = i.cancelPositions(positionList)
+	if err != nil {
+		return err
+	}
+	log.Printf("Exited all current positions.")
+	for _, position := range positionList {
+		err = i.sendEmail(fmt.Sprintf("Cancelled position %s", position.TradingSymbol))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WaitAndWatch polls the stop loss legs till the exit time
+// or till the net stop loss or the target is hit
+func (i *IronCondorStrategy) WaitAndWatch() error {
+	if i.Config.StopLoss == StopLossLeg {
+		i.Watcher.Add(i.stopLossHandlers(), &i.Data.SellCEStopLossOptionPosition, &i.Data.SellPEStopLossOptionPosition)
+	}
+
+	log.Printf("Waiting for %s....", i.Config.Exit)
+	for !duration.ValidateTime(i.ExitStartTime, i.ExitEndTime, i.TimeZone) && time.Now().Before(i.ExitEndTime) {
+		time.Sleep(i.Config.PollInterval)
+		err := i.Watcher.Poll()
+		if err != nil {
+			return err
+		}
+		if i.Reconciler.Due() {
+			_, err = i.reconcile("watch", true)
+			if err != nil {
+				return err
+			}
+		}
+		reason, err := i.checkExit()
+		if err != nil {
+			// the exit time still exits the position
+			log.Printf("Couldn't check the net stop loss and target because %s", err)
+			continue
+		}
+		if len(reason) > 0 {
+			log.Println(reason)
+			return i.sendEmail(reason)
+		}
+	}
+	log.Printf("Time : %v", time.Now().In(&i.TimeZone))
+
+	return nil
+}
+
+// checkExit tells why the position should be exited
+// before the exit time if it should be
+func (i *IronCondorStrategy) checkExit() (string, error) {
+	if i.Config.StopLoss != StopLossNet && i.Config.TargetPercentage <= 0 {
+		return "", nil
+	}
+	pnl, err := i.pnl()
+	if err != nil {
+		return "", err
+	}
+	credit := i.credit()
+	log.Printf("PnL %f of net credit %f", pnl, credit)
+	if credit <= 0 {
+		return "", nil
+	}
+
+	if i.Config.StopLoss == StopLossNet && -pnl >= credit*i.Config.StopLossPercentage/100 {
+		return fmt.Sprintf("Net stop loss hit with PnL %f on net credit %f", pnl, credit), nil
+	}
+	if i.Config.TargetPercentage > 0 && pnl >= credit*i.Config.TargetPercentage/100 {
+		return fmt.Sprintf("Target hit with PnL %f on net credit %f", pnl, credit), nil
+	}
+
+	return "", nil
+}
+
+// reconcile compares the legs with the positions of the broker
+// and emails the mismatches if there are any. Extra positions
+// are only squared off as configured once the legs are known.
+func (i *IronCondorStrategy) reconcile(when string, known bool) (reconciler.Mismatches, error) {
+	holdings := reconciler.HoldingsOf(i.Data.BuyCEOptionPosition, i.Data.BuyPEOptionPosition, i.Data.SellCEOptionPosition, i.Data.SellPEOptionPosition,
+		i.Data.SellCEStopLossOptionPosition, i.Data.SellPEStopLossOptionPosition)
+	reconcile := i.Reconciler.Report
+	if known {
+		reconcile = i.Reconciler.Reconcile
+	}
+	mismatches, err := reconcile(holdings)
+	if err != nil {
+		return nil, err
+	}
+	if len(mismatches) <= 0 {
+		return mismatches, nil
+	}
+
+	return mismatches, i.sendEmail(fmt.Sprintf("Positions differ from the legs at %s\n%s", when, mismatches))
+}
+
+// credit is the net premium received for the position
+func (i *IronCondorStrategy) credit() float64 {
+	credit := 0.0
+	for _, leg := range (models.Orders{i.Data.SellCEOptionPosition, i.Data.SellPEOptionPosition, i.Data.BuyCEOptionPosition, i.Data.BuyPEOptionPosition}) {
+		value := leg.AveragePrice * float64(leg.Quantity)
+		if leg.TransactionType == models.TransactionTypeSell {
+			credit += value
+		} else {
+			credit -= value
+		}
+	}
+
+	return credit
+}
+
+// pnl marks the open legs to their LTP and the short
+// legs stopped out to the price of their stop loss
+func (i *IronCondorStrategy) pnl() (float64, error) {
+	pnl := 0.0
+	legs := []struct {
+		position *models.Order
+		stopLoss *models.Order
+	}{
+		{&i.Data.SellCEOptionPosition, &i.Data.SellCEStopLossOptionPosition},
+		{&i.Data.SellPEOptionPosition, &i.Data.SellPEStopLossOptionPosition},
+		{&i.Data.BuyCEOptionPosition, nil},
+		{&i.Data.BuyPEOptionPosition, nil},
+	}
+	for _, leg := range legs {
+		var price float64
+		var err error
+		if leg.stopLoss != nil && leg.stopLoss.Status == models.StatusComplete {
+			price = leg.stopLoss.AveragePrice
+		} else {
+			price, err = options.GetLTP(leg.position.TradingSymbol, i.Broker)
+			if err != nil {
+				return 0, err
+			}
+			if price <= 0 {
+				return 0, errors.New("no LTP for " + leg.position.TradingSymbol)
+			}
+		}
+		change := (price - leg.position.AveragePrice) * float64(leg.position.Quantity)
+		if leg.position.TransactionType == models.TransactionTypeSell {
+			change = -change
+		}
+		pnl += change
+	}
+
+	return pnl, nil
+}
+
+func (i *IronCondorStrategy) placeStopLosses() error {
+	var err error
+	i.Data.SellCEStopLossOptionPosition, err = i.calculateStopLossLeg(i.Data.SellCEOptionPosition, "slce")
+	if err != nil {
+		return err
+	}
+	i.Data.SellPEStopLossOptionPosition, err = i.calculateStopLossLeg(i.Data.SellPEOptionPosition, "slpe")
+	if err != nil {
+		return err
+	}
+	for _, stopLossLeg := range (models.RefOrders{&i.Data.SellCEStopLossOptionPosition, &i.Data.SellPEStopLossOptionPosition}) {
+		err = i.Broker.PlaceOrder(stopLossLeg)
+		if err != nil {
+			return err
+		}
+		message := fmt.Sprintf("Placed %s Stop Loss Leg with Trigger Price %f", stopLossLeg.TradingSymbol, stopLossLeg.TriggerPrice)
+		log.Println(message)
+		err = i.sendEmail(message)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// stopLossHandlers sends an email on every change of the stop loss
+// legs and completes the legs which stay open after getting triggered
+func (i *IronCondorStrategy) stopLossHandlers() watcher.Handlers {
+	notify := func(event watcher.Event) error {
+		return i.sendEmail(fmt.Sprintf("Order %s Changed from %s to %s", event.Order.TradingSymbol, event.PreviousStatus, event.Order.Status))
+	}
+
+	return watcher.Handlers{
+		watcher.EventTriggered: notify,
+		watcher.EventFilled:    notify,
+		watcher.EventRejected:  notify,
+		watcher.EventCancelled: notify,
+		watcher.EventStuckOpen: func(event watcher.Event) error {
+			event.Order.OrderType = models.OrderTypeLimit
+			err := i.Broker.PlaceOrder(event.Order)
+			if err != nil {
+				return err
+			}
+			message := fmt.Sprintf("Order %s Changed from OPEN to %s", event.Order.TradingSymbol, event.Order.Status)
+			log.Println(message)
+			return i.sendEmail(message)
+		},
+	}
+}
+
+// sendEmail sends the update to the account
+func (i *IronCondorStrategy) sendEmail(body string) error {
+	return utils.SendEmailTo(i.Account.Email, i.Account.Subject(fmt.Sprintf("%s Trade Update", i.Name)), body)
+}
+
+func (i *IronCondorStrategy) cancelPositions(positions models.Orders) error {
+	for _, position := range positions {
+		position = position.Unplaced()
+		position.TransactionType = position.TransactionType.Opposite()
+		position.Tag = broker.NewOrderTag(position.Tag, "exit")
+		err := i.Broker.PlaceOrder(&position)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (i *IronCondorStrategy) calculateLeg(optionType string, strikePrice float64, transactionType models.TransactionType, legName string) (models.Order, error) {
+	leg := models.Order{
+		Instrument: models.Instrument{
+			Exchange:       models.ExchangeNFO,
+			InstrumentType: optionType,
+		},
+		TransactionType: transactionType,
+		Product:         models.Product(i.Config.ProductType),
+		OrderType:       models.OrderTypeLimit,
+		Tag:             broker.NewOrderTag(i.Name, i.RunID, legName),
+	}
+
+	legSymbol, err := options.GetSymbol(i.Config.Underlying, options.WEEK, i.Config.ExpiryOffset, strikePrice, optionType, i.Broker)
+	if err != nil {
+		return models.Order{}, err
+	}
+	leg.TradingSymbol = legSymbol
+
+	leg.LotSize, err = options.GetLotSize(legSymbol, i.Broker)
+	if err != nil {
+		return models.Order{}, err
+	}
+	leg.Quantity = i.Lots * leg.LotSize
+
+	leg.Expiry, err = options.GetExpiry(i.Config.Underlying, options.WEEK, i.Config.ExpiryOffset, strikePrice, optionType, i.Broker)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return leg, nil
+}
+
+// size consults the sizer for the lots of the legs. The loss
+// of a lot is where the stop loss exits, at most the width of
+// the wings less the credit.
+func (i *IronCondorStrategy) size() error {
+	legs := []*models.Order{&i.Data.BuyCEOptionPosition, &i.Data.BuyPEOptionPosition, &i.Data.SellCEOptionPosition, &i.Data.SellPEOptionPosition}
+	credit, shortPremium := 0.0, 0.0
+	for _, leg := range legs {
+		ltp, err := options.GetLTP(leg.TradingSymbol, i.Broker)
+		if err != nil {
+			return err
+		}
+		if leg.TransactionType == models.TransactionTypeSell {
+			credit += ltp
+			shortPremium += ltp
+		} else {
+			credit -= ltp
+		}
+	}
+	loss := i.Config.WingWidth - credit
+	switch i.Config.StopLoss {
+	case StopLossLeg:
+		loss = math.Min(loss, shortPremium*i.Config.StopLossPercentage/100)
+	case StopLossNet:
+		loss = math.Min(loss, credit*i.Config.StopLossPercentage/100)
+	}
+
+	lots, err := i.Sizer.SizeLegs(sizing.Trade{Lots: i.Config.Lots, LossPerLot: loss * float64(i.Data.SellCEOptionPosition.LotSize)}, legs...)
+	if err != nil {
+		return err
+	}
+	i.Lots = lots
+
+	return nil
+}
+
+// calculateStopLossLeg gives the stop loss of the filled leg
+// as a new order, without the fills of the leg
+func (i *IronCondorStrategy) calculateStopLossLeg(leg models.Order, legName string) (models.Order, error) {
+	entryPrice := leg.AveragePrice
+	leg = leg.Unplaced()
+	leg.Tag = broker.NewOrderTag(i.Name, i.RunID, legName)
+	leg.TransactionType = models.TransactionTypeBuy
+	leg.OrderType = models.OrderTypeSL
+
+	stopLossPrice := entryPrice * (1 + i.Config.StopLossPercentage/100)
+	leg.TriggerPrice = float64(int(stopLossPrice*10)) / 10
+	leg.Price = float64(int(leg.TriggerPrice) + 5)
+
+	return leg, nil
+}
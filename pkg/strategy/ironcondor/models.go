@@ -0,0 +1,45 @@
+package ironcondor
+
+import (
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const (
+	StopLossLeg  = "leg"
+	StopLossNet  = "net"
+	StopLossNone = "none"
+)
+
+type IronCondorPositions struct {
+	SellCEOptionPosition         models.Order
+	SellPEOptionPosition         models.Order
+	BuyCEOptionPosition          models.Order
+	BuyPEOptionPosition          models.Order
+	SellCEStopLossOptionPosition models.Order
+	SellPEStopLossOptionPosition models.Order
+}
+
+// IronCondorConfig is the shape of the condor. A short distance
+// of zero sells the ATM strikes, which makes it a butterfly.
+type IronCondorConfig struct {
+	Underlying    string
+	ProductType   string
+	Lots          int
+	ExpiryOffset  int
+	StrikeStep    float64
+	ShortDistance float64
+	WingWidth     float64
+	EntryStart    string
+	EntryEnd      string
+	Exit          string
+	// StopLoss is per leg with a SL order for each short leg,
+	// net on the loss of the whole position or none
+	StopLoss           string
+	StopLossPercentage float64
+	// TargetPercentage of the net credit exits the position
+	// early, zero means no target
+	TargetPercentage float64
+	PollInterval     time.Duration
+}
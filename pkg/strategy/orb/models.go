@@ -0,0 +1,40 @@
+package orb
+
+import (
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+type ORBPositions struct {
+	FuturePosition         models.Order
+	FutureStopLossPosition models.Order
+	FutureExitPosition     models.Order
+}
+
+// Range is the opening range of the future
+type Range struct {
+	High float64
+	Low  float64
+}
+
+func (r Range) Size() float64 {
+	return r.High - r.Low
+}
+
+type ORBConfig struct {
+	Underlying   string
+	ProductType  string
+	Lots         int
+	ExpiryOffset int
+	// RangeStart and RangeMinutes are the opening
+	// range whose high and low are broken out of
+	RangeStart   string
+	RangeMinutes int
+	// TargetMultiple of the range size away from the entry
+	// is the target, zero means no target
+	TargetMultiple float64
+	EntryEnd       string
+	Exit           string
+	PollInterval   time.Duration
+}
@@ -0,0 +1,502 @@
+package orb
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"github.com/avast/retry-go"
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/reconciler"
+	"github.com/rohitsakala/strategies/pkg/sizing"
+	"github.com/rohitsakala/strategies/pkg/strategy"
+	"github.com/rohitsakala/strategies/pkg/utils"
+	"github.com/rohitsakala/strategies/pkg/utils/duration"
+	"github.com/rohitsakala/strategies/pkg/utils/options"
+	"github.com/rohitsakala/strategies/pkg/watcher"
+)
+
+const (
+	ORBStrategyDatabaseName = "orb"
+	// stopLossBuffer is how far the limit price of the
+	// stop loss order is beyond its trigger price
+	stopLossBuffer = 5
+)
+
+func init() {
+	strategy.Register(strategy.Definition{
+		Name:        ORBStrategyDatabaseName,
+		Description: "Trades the breakout of the opening range of the index future with the stop loss at the other end of the range",
+		Parameters: []strategy.Parameter{
+			{Name: "product", Type: strategy.StringParameter, Default: string(models.ProductMIS), Choices: []string{string(models.ProductMIS), string(models.ProductNRML)}, Description: "product type of the orders"},
+			{Name: "underlying", Type: strategy.StringParameter, Default: "NIFTY"},
+			{Name: "lots", Type: strategy.IntParameter, Default: "1", Description: "lots before the lot multiplier of the account"},
+			{Name: "expiryoffset", Type: strategy.IntParameter, Default: "0", Description: "0 is the current month future, 1 the next one"},
+			{Name: "rangestart", Type: strategy.StringParameter, Default: "09:15", Description: "start of the opening range"},
+			{Name: "rangeminutes", Type: strategy.IntParameter, Default: "15", Description: "minutes of the opening range"},
+			{Name: "target", Type: strategy.FloatParameter, Default: "2", Description: "multiple of the range size to take profit at, 0 for none"},
+			{Name: "entryend", Type: strategy.StringParameter, Default: "14:30", Description: "no entry after this time"},
+			{Name: "exit", Type: strategy.StringParameter, Default: "15:15", Description: "square off time"},
+			{Name: "pollinterval", Type: strategy.DurationParameter, Default: "5s", Description: "how often the LTP is checked"},
+		},
+		Validate: func(parameters strategy.Parameters) error {
+			_, err := newConfig(parameters)
+			return err
+		},
+		New: func(dependencies strategy.Dependencies, parameters strategy.Parameters) (strategy.Strategy, error) {
+			config, err := newConfig(parameters)
+			if err != nil {
+				return nil, err
+			}
+			orbStrategy, err := NewORBStrategy(config, dependencies.Account, dependencies.Broker, dependencies.TimeZone, dependencies.Watcher, dependencies.Sizer)
+			if err != nil {
+				return nil, err
+			}
+			return &orbStrategy, nil
+		},
+	})
+}
+
+func newConfig(parameters strategy.Parameters) (ORBConfig, error) {
+	config := ORBConfig{
+		Underlying:     parameters.String("underlying"),
+		ProductType:    parameters.String("product"),
+		Lots:           parameters.Int("lots"),
+		ExpiryOffset:   parameters.Int("expiryoffset"),
+		RangeStart:     parameters.String("rangestart"),
+		RangeMinutes:   parameters.Int("rangeminutes"),
+		TargetMultiple: parameters.Float("target"),
+		EntryEnd:       parameters.String("entryend"),
+		Exit:           parameters.String("exit"),
+		PollInterval:   parameters.Duration("pollinterval"),
+	}
+
+	if config.Lots < 1 {
+		return ORBConfig{}, fmt.Errorf("lots %d is less than 1", config.Lots)
+	}
+	if config.ExpiryOffset < 0 {
+		return ORBConfig{}, fmt.Errorf("expiryoffset %d is negative", config.ExpiryOffset)
+	}
+	if config.RangeMinutes < 1 {
+		return ORBConfig{}, fmt.Errorf("rangeminutes %d is less than 1", config.RangeMinutes)
+	}
+	if config.TargetMultiple < 0 {
+		return ORBConfig{}, fmt.Errorf("target %v is negative", config.TargetMultiple)
+	}
+	if config.PollInterval <= 0 {
+		return ORBConfig{}, fmt.Errorf("pollinterval %s is not positive", config.PollInterval)
+	}
+	rangeStart, err := duration.TodayAt(config.RangeStart, *time.UTC)
+	if err != nil {
+		return ORBConfig{}, err
+	}
+	entryEnd, err := duration.TodayAt(config.EntryEnd, *time.UTC)
+	if err != nil {
+		return ORBConfig{}, err
+	}
+	exit, err := duration.TodayAt(config.Exit, *time.UTC)
+	if err != nil {
+		return ORBConfig{}, err
+	}
+	rangeEnd := rangeStart.Add(time.Duration(config.RangeMinutes) * time.Minute)
+	if !rangeEnd.Before(entryEnd) || !entryEnd.Before(exit) {
+		return ORBConfig{}, fmt.Errorf("range end %s, entryend %s and exit %s are not in order", rangeEnd.Format("15:04"), config.EntryEnd, config.Exit)
+	}
+
+	return config, nil
+}
+
+type ORBStrategy struct {
+	Config         ORBConfig
+	RangeStartTime time.Time
+	RangeEndTime   time.Time
+	EntryEndTime   time.Time
+	ExitTime       time.Time
+	Range          Range
+	Target         float64
+	Data           ORBPositions
+	Broker         broker.Broker
+	TimeZone       time.Location
+	Watcher        watcher.Watcher
+	// RunID identifies the run of the day in the order tags
+	RunID      string
+	Account    account.Account
+	Sizer      sizing.Sizer
+	Reconciler reconciler.Reconciler
+}
+
+func NewORBStrategy(config ORBConfig, account account.Account, broker broker.Broker, timeZone time.Location, watcher watcher.Watcher, sizer sizing.Sizer) (ORBStrategy, error) {
+	rangeStartTime, err := duration.TodayAt(config.RangeStart, timeZone)
+	if err != nil {
+		return ORBStrategy{}, err
+	}
+	entryEndTime, err := duration.TodayAt(config.EntryEnd, timeZone)
+	if err != nil {
+		return ORBStrategy{}, err
+	}
+	exitTime, err := duration.TodayAt(config.Exit, timeZone)
+	if err != nil {
+		return ORBStrategy{}, err
+	}
+	positionsReconciler, err := reconciler.NewReconciler(account, broker, ORBStrategyDatabaseName)
+	if err != nil {
+		return ORBStrategy{}, err
+	}
+
+	return ORBStrategy{
+		Config:         config,
+		RangeStartTime: rangeStartTime,
+		RangeEndTime:   rangeStartTime.Add(time.Duration(config.RangeMinutes) * time.Minute),
+		EntryEndTime:   entryEndTime,
+		ExitTime:       exitTime,
+		Broker:         broker,
+		TimeZone:       timeZone,
+		Watcher:        watcher,
+		RunID:          time.Now().In(&timeZone).Format("20060102"),
+		Account:        account,
+		Sizer:          sizer,
+		Reconciler:     positionsReconciler,
+	}, nil
+}
+
+func (o *ORBStrategy) Start() error {
+	// Check if markets are open today ?
+	open, err := o.Broker.IsMarketOpen()
+	if err != nil {
+		return err
+	}
+	if !open {
+		log.Println("Market is closed")
+		return nil
+	}
+	_, err = o.reconcile("startup", false)
+	if err != nil {
+		return err
+	}
+
+	future, err := o.calculateFuture()
+	if err != nil {
+		return err
+	}
+	err = o.recordRange(future.TradingSymbol)
+	if err != nil {
+		return err
+	}
+	err = o.size(&future)
+	if err != nil {
+		return err
+	}
+	log.Printf("Trading %s with quantity %d", future.TradingSymbol, future.Quantity)
+	message := fmt.Sprintf("Opening range of %s is %f to %f", future.TradingSymbol, o.Range.Low, o.Range.High)
+	log.Println(message)
+	err = o.sendEmail(message)
+	if err != nil {
+		return err
+	}
+
+	transactionType, found, err := o.waitForBreakout(future.TradingSymbol)
+	if err != nil {
+		return err
+	}
+	if !found {
+		message = fmt.Sprintf("No breakout of %s till %s", future.TradingSymbol, o.Config.EntryEnd)
+		log.Println(message)
+		return o.sendEmail(message)
+	}
+
+	err = o.enter(future, transactionType)
+	if err != nil {
+		return err
+	}
+
+	return o.WaitAndWatch()
+}
+
+func (o *ORBStrategy) Stop() error {
+	// Check if markets are open today ?
+	open, err := o.Broker.IsMarketOpen()
+	if err != nil {
+		return err
+	}
+	if !open {
+		log.Println("Market is closed")
+		return nil
+	}
+
+	return o.exit("Squared off at exit time")
+}
+
+// recordRange takes the high and low of the minute candles
+// of the future over the opening range once it is over
+func (o *ORBStrategy) recordRange(tradingSymbol string) error {
+	log.Printf("Waiting for the opening range till %s....", o.RangeEndTime.Format("15:04"))
+	for time.Now().Before(o.RangeEndTime) {
+		time.Sleep(o.Config.PollInterval)
+	}
+
+	// the last candle of the range may take a while to show up
+	lastCandle := o.RangeEndTime.Add(-models.IntervalMinute.Duration())
+	var candles models.Candles
+	err := retry.Do(
+		func() error {
+			var err error
+			candles, err = o.Broker.GetHistoricalCandles(tradingSymbol, models.IntervalMinute, o.RangeStartTime, lastCandle)
+			if err != nil {
+				return err
+			}
+			if len(candles) <= 0 || candles[len(candles)-1].Time.Before(lastCandle) {
+				return fmt.Errorf("candles of %s till %s are not there yet", tradingSymbol, lastCandle.Format("15:04"))
+			}
+			return nil
+		},
+		retry.OnRetry(func(_ uint, err error) {
+			log.Println(fmt.Sprintf("%s because %s", "Retrying getting the opening range candles", err))
+		}),
+		retry.Delay(5*time.Second),
+		retry.Attempts(5),
+	)
+	if err != nil {
+		return err
+	}
+
+	o.Range = Range{High: math.Inf(-1), Low: math.Inf(1)}
+	for _, candle := range candles {
+		o.Range.High = math.Max(o.Range.High, candle.High)
+		o.Range.Low = math.Min(o.Range.Low, candle.Low)
+	}
+	if o.Range.Size() <= 0 {
+		return fmt.Errorf("opening range of %s couldn't be recorded", tradingSymbol)
+	}
+
+	return nil
+}
+
+// waitForBreakout gives the side of the first break of
+// the range before the entry end time if there is one
+func (o *ORBStrategy) waitForBreakout(tradingSymbol string) (models.TransactionType, bool, error) {
+	log.Printf("Waiting for breakout of %f to %f till %s....", o.Range.Low, o.Range.High, o.Config.EntryEnd)
+	for time.Now().Before(o.EntryEndTime) {
+		ltp, err := options.GetLTP(tradingSymbol, o.Broker)
+		if err != nil {
+			return "", false, err
+		}
+		if ltp > o.Range.High {
+			return models.TransactionTypeBuy, true, nil
+		}
+		if ltp < o.Range.Low {
+			return models.TransactionTypeSell, true, nil
+		}
+		time.Sleep(o.Config.PollInterval)
+	}
+
+	return "", false, nil
+}
+
+// enter places the future order along with a stop
+// loss order at the other end of the range
+func (o *ORBStrategy) enter(future models.Order, transactionType models.TransactionType) error {
+	o.Data.FuturePosition = future
+	o.Data.FuturePosition.TransactionType = transactionType
+	o.Data.FuturePosition.Tag = broker.NewOrderTag(ORBStrategyDatabaseName, o.RunID, "entry")
+	err := o.Broker.PlaceOrder(&o.Data.FuturePosition)
+	if err != nil {
+		return err
+	}
+
+	stopLoss := o.Data.FuturePosition.Unplaced()
+	stopLoss.TransactionType = transactionType.Opposite()
+	stopLoss.Tag = broker.NewOrderTag(ORBStrategyDatabaseName, o.RunID, "sl")
+	stopLoss.OrderType = models.OrderTypeSL
+	if transactionType == models.TransactionTypeBuy {
+		stopLoss.TriggerPrice = o.Range.Low
+		stopLoss.Price = broker.RoundDownToTick(o.Range.Low-stopLossBuffer, future.TickSize)
+		o.Target = o.Data.FuturePosition.AveragePrice + o.Config.TargetMultiple*o.Range.Size()
+	} else {
+		stopLoss.TriggerPrice = o.Range.High
+		stopLoss.Price = broker.RoundUpToTick(o.Range.High+stopLossBuffer, future.TickSize)
+		o.Target = o.Data.FuturePosition.AveragePrice - o.Config.TargetMultiple*o.Range.Size()
+	}
+	o.Data.FutureStopLossPosition = stopLoss
+	err = o.Broker.PlaceOrder(&o.Data.FutureStopLossPosition)
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("Placed %s %s with Avg Price %f and Stop Loss at %f", transactionType, o.Data.FuturePosition.TradingSymbol,
+		o.Data.FuturePosition.AveragePrice, o.Data.FutureStopLossPosition.TriggerPrice)
+	if o.Config.TargetMultiple > 0 {
+		message = fmt.Sprintf("%s and Target at %f", message, o.Target)
+	}
+	log.Println(message)
+
+	return o.sendEmail(message)
+}
+
+// WaitAndWatch watches the stop loss and the
+// target till the exit time
+func (o *ORBStrategy) WaitAndWatch() error {
+	o.Watcher.Add(o.stopLossHandlers(), &o.Data.FutureStopLossPosition)
+
+	log.Printf("Waiting for %s....", o.Config.Exit)
+	for time.Now().Before(o.ExitTime) {
+		time.Sleep(o.Config.PollInterval)
+		err := o.Watcher.Poll()
+		if err != nil {
+			return err
+		}
+		if o.Data.FutureStopLossPosition.Status == models.StatusComplete {
+			log.Printf("Stop loss of %s is hit", o.Data.FuturePosition.TradingSymbol)
+			return nil
+		}
+		if o.Reconciler.Due() {
+			_, err = o.reconcile("watch", true)
+			if err != nil {
+				return err
+			}
+		}
+		if o.Config.TargetMultiple <= 0 {
+			continue
+		}
+		ltp, err := options.GetLTP(o.Data.FuturePosition.TradingSymbol, o.Broker)
+		if err != nil {
+			log.Printf("Couldn't check the target because %s", err)
+			continue
+		}
+		if (o.Data.FuturePosition.TransactionType == models.TransactionTypeBuy && ltp >= o.Target) ||
+			(o.Data.FuturePosition.TransactionType == models.TransactionTypeSell && ltp <= o.Target) {
+			return o.exit(fmt.Sprintf("Target %f hit at %f", o.Target, ltp))
+		}
+	}
+
+	return nil
+}
+
+// exit cancels the stop loss and squares off the future
+// unless the stop loss already did or it is already exited
+func (o *ORBStrategy) exit(reason string) error {
+	if len(o.Data.FuturePosition.OrderID) < 1 || len(o.Data.FutureExitPosition.OrderID) > 0 ||
+		o.Data.FutureStopLossPosition.Status == models.StatusComplete {
+		return nil
+	}
+
+	if !o.Data.FutureStopLossPosition.Status.IsFinal() {
+		o.Watcher.Remove(&o.Data.FutureStopLossPosition)
+		err := o.Broker.CancelOrder(&o.Data.FutureStopLossPosition)
+		if err != nil {
+			return err
+		}
+	}
+	// the stop loss may have filled before getting cancelled
+	if o.Data.FutureStopLossPosition.Status == models.StatusComplete {
+		return o.sendEmail(fmt.Sprintf("Stop loss of %s got hit while exiting", o.Data.FuturePosition.TradingSymbol))
+	}
+	mismatches, err := o.reconcile("exit", true)
+	if err != nil {
+		return err
+	}
+	held := mismatches.Held(o.Data.FuturePosition)
+	if held <= 0 {
+		log.Printf("Not exiting %s as it isn't held anymore", o.Data.FuturePosition.TradingSymbol)
+		return nil
+	}
+
+	o.Data.FutureExitPosition = o.Data.FuturePosition.Unplaced()
+	o.Data.FutureExitPosition.Quantity = held
+	o.Data.FutureExitPosition.TransactionType = o.Data.FuturePosition.TransactionType.Opposite()
+	o.Data.FutureExitPosition.Tag = broker.NewOrderTag(o.Data.FuturePosition.Tag, "exit")
+	o.Data.FutureExitPosition.OrderType = models.OrderTypeLimit
+	err = o.Broker.PlaceOrder(&o.Data.FutureExitPosition)
+	if err != nil {
+		return err
+	}
+
+	message := fmt.Sprintf("%s, exited %s with Avg Price %f", reason, o.Data.FutureExitPosition.TradingSymbol, o.Data.FutureExitPosition.AveragePrice)
+	log.Println(message)
+
+	return o.sendEmail(message)
+}
+
+// reconcile compares the future with the positions of the broker
+// and emails the mismatches if there are any. Extra positions
+// are only squared off as configured once the future is known.
+func (o *ORBStrategy) reconcile(when string, known bool) (reconciler.Mismatches, error) {
+	holdings := reconciler.HoldingsOf(o.Data.FuturePosition, o.Data.FutureStopLossPosition, o.Data.FutureExitPosition)
+	reconcile := o.Reconciler.Report
+	if known {
+		reconcile = o.Reconciler.Reconcile
+	}
+	mismatches, err := reconcile(holdings)
+	if err != nil {
+		return nil, err
+	}
+	if len(mismatches) <= 0 {
+		return mismatches, nil
+	}
+
+	return mismatches, o.sendEmail(fmt.Sprintf("Positions differ from the future at %s\n%s", when, mismatches))
+}
+
+// stopLossHandlers sends an email on every change of the stop loss
+// and completes it if it stays open after getting triggered
+func (o *ORBStrategy) stopLossHandlers() watcher.Handlers {
+	notify := func(event watcher.Event) error {
+		return o.sendEmail(fmt.Sprintf("Order %s Changed from %s to %s", event.Order.TradingSymbol, event.PreviousStatus, event.Order.Status))
+	}
+
+	return watcher.Handlers{
+		watcher.EventTriggered: notify,
+		watcher.EventFilled:    notify,
+		watcher.EventRejected:  notify,
+		watcher.EventCancelled: notify,
+		watcher.EventStuckOpen: func(event watcher.Event) error {
+			event.Order.OrderType = models.OrderTypeLimit
+			err := o.Broker.PlaceOrder(event.Order)
+			if err != nil {
+				return err
+			}
+			message := fmt.Sprintf("Order %s Changed from OPEN to %s", event.Order.TradingSymbol, event.Order.Status)
+			log.Println(message)
+			return o.sendEmail(message)
+		},
+	}
+}
+
+func (o *ORBStrategy) calculateFuture() (models.Order, error) {
+	futureSymbol, err := options.GetFutureSymbol(o.Config.Underlying, o.Config.ExpiryOffset, o.Broker)
+	if err != nil {
+		return models.Order{}, err
+	}
+	instrument, err := o.Broker.GetInstrument(futureSymbol, models.ExchangeNFO)
+	if err != nil {
+		return models.Order{}, err
+	}
+	if instrument.LotSize < 1 {
+		return models.Order{}, fmt.Errorf("lot size of %s is missing", futureSymbol)
+	}
+
+	return models.Order{
+		Instrument:      instrument,
+		Product:         models.Product(o.Config.ProductType),
+		OrderType:       models.OrderTypeLimit,
+		TransactionType: models.TransactionTypeBuy,
+		Quantity:        instrument.LotSize,
+	}, nil
+}
+
+// size consults the sizer for the lots of the future. The
+// loss of a lot is the whole range as the entry is at one
+// end of it and the stop loss at the other.
+func (o *ORBStrategy) size(future *models.Order) error {
+	lossPerLot := (o.Range.Size() + stopLossBuffer) * float64(future.LotSize)
+	_, err := o.Sizer.SizeLegs(sizing.Trade{Lots: o.Config.Lots, LossPerLot: lossPerLot}, future)
+
+	return err
+}
+
+// sendEmail sends the update to the account
+func (o *ORBStrategy) sendEmail(body string) error {
+	return utils.SendEmailTo(o.Account.Email, o.Account.Subject("ORB Trade Update"), body)
+}
@@ -0,0 +1,144 @@
+package strategy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ParameterType string
+
+const (
+	StringParameter   ParameterType = "string"
+	IntParameter      ParameterType = "int"
+	FloatParameter    ParameterType = "float"
+	BoolParameter     ParameterType = "bool"
+	DurationParameter ParameterType = "duration"
+)
+
+// Parameter describes an argument of a strategy. Parameters
+// without a default are required and Choices, if any, are
+// the only values allowed.
+type Parameter struct {
+	Name        string
+	Type        ParameterType
+	Default     string
+	Choices     []string
+	Description string
+}
+
+func (p Parameter) Required() bool {
+	return len(p.Default) < 1
+}
+
+// parse converts the value to the type of the parameter
+func (p Parameter) parse(value string) (interface{}, error) {
+	if len(p.Choices) > 0 {
+		allowed := false
+		for _, choice := range p.Choices {
+			if value == choice {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return nil, fmt.Errorf("invalid %s %s, must be one of %s", p.Name, value, strings.Join(p.Choices, ", "))
+		}
+	}
+
+	var parsed interface{}
+	var err error
+	switch p.Type {
+	case StringParameter, "":
+		parsed = value
+	case IntParameter:
+		parsed, err = strconv.Atoi(value)
+	case FloatParameter:
+		parsed, err = strconv.ParseFloat(value, 64)
+	case BoolParameter:
+		parsed, err = strconv.ParseBool(value)
+	case DurationParameter:
+		parsed, err = time.ParseDuration(value)
+	default:
+		return nil, fmt.Errorf("parameter %s has unknown type %s", p.Name, p.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %s %s", p.Type, p.Name, value)
+	}
+
+	return parsed, nil
+}
+
+// Parameters are the typed values of the parameters
+// of a strategy after the defaults are applied
+type Parameters map[string]interface{}
+
+func (p Parameters) String(name string) string {
+	value, _ := p[name].(string)
+	return value
+}
+
+func (p Parameters) Int(name string) int {
+	value, _ := p[name].(int)
+	return value
+}
+
+func (p Parameters) Float(name string) float64 {
+	value, _ := p[name].(float64)
+	return value
+}
+
+func (p Parameters) Bool(name string) bool {
+	value, _ := p[name].(bool)
+	return value
+}
+
+func (p Parameters) Duration(name string) time.Duration {
+	value, _ := p[name].(time.Duration)
+	return value
+}
+
+// ParseParameters reads the arguments against the schema. Arguments
+// are either name=value or positional in the order of the schema,
+// so the older `twelvethirty NRML variable` form keeps working.
+func ParseParameters(schema []Parameter, args []string) (Parameters, error) {
+	values := map[string]string{}
+	position := 0
+	for _, arg := range args {
+		if i := strings.Index(arg, "="); i > 0 {
+			values[arg[:i]] = arg[i+1:]
+			continue
+		}
+		if position >= len(schema) {
+			return nil, fmt.Errorf("unexpected argument %s", arg)
+		}
+		values[schema[position].Name] = arg
+		position++
+	}
+
+	known := map[string]bool{}
+	parameters := Parameters{}
+	for _, parameter := range schema {
+		known[parameter.Name] = true
+		value, ok := values[parameter.Name]
+		if !ok {
+			if parameter.Required() {
+				return nil, fmt.Errorf("missing parameter %s", parameter.Name)
+			}
+			value = parameter.Default
+		}
+		parsed, err := parameter.parse(value)
+		if err != nil {
+			return nil, err
+		}
+		parameters[parameter.Name] = parsed
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+
+	return parameters, nil
+}
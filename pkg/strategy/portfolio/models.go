@@ -0,0 +1,58 @@
+package portfolio
+
+import (
+	"time"
+)
+
+// Exposure is the net risk of the positions on an underlying.
+// The greeks are for the quantity held, so Delta is in units
+// of the underlying, Theta per day and Vega per percent of
+// volatility.
+type Exposure struct {
+	Underlying string
+	Spot       float64
+	Positions  int
+	Delta      float64
+	Gamma      float64
+	Theta      float64
+	Vega       float64
+	// Notional is the value of the underlying the net delta
+	// stands for and GrossNotional the value of the underlying
+	// of all the quantity held
+	Notional      float64
+	GrossNotional float64
+	// Skipped are the positions left out as their instrument,
+	// the spot of the underlying or their greeks couldn't be had
+	Skipped []string
+}
+
+// Partial tells whether positions were left out of the exposure
+func (e Exposure) Partial() bool {
+	return len(e.Skipped) > 0
+}
+
+// Thresholds are the absolute limits of the exposure of an
+// underlying which raise an alert, zero is not checked
+type Thresholds struct {
+	Delta    float64
+	Gamma    float64
+	Theta    float64
+	Vega     float64
+	Notional float64
+}
+
+// Alert is a measure of an underlying past its threshold
+type Alert struct {
+	Underlying string
+	Measure    string
+	Value      float64
+	Threshold  float64
+	// Skipped are the positions left out of the value
+	Skipped []string
+}
+
+type PortfolioConfig struct {
+	Thresholds   Thresholds
+	PollInterval time.Duration
+	Exit         string
+}
@@ -0,0 +1,358 @@
+package portfolio
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/strategy"
+	"github.com/rohitsakala/strategies/pkg/symbol"
+	"github.com/rohitsakala/strategies/pkg/utils"
+	"github.com/rohitsakala/strategies/pkg/utils/duration"
+	"github.com/rohitsakala/strategies/pkg/utils/options"
+)
+
+const (
+	PortfolioMonitorName = "portfolio"
+)
+
+func init() {
+	strategy.Register(strategy.Definition{
+		Name:        PortfolioMonitorName,
+		Description: "Monitors the net greeks and notional exposure per underlying of all the positions and alerts past the thresholds",
+		Parameters: []strategy.Parameter{
+			{Name: "maxdelta", Type: strategy.FloatParameter, Default: "0", Description: "absolute net delta of an underlying in its units, 0 is off"},
+			{Name: "maxgamma", Type: strategy.FloatParameter, Default: "0", Description: "absolute net gamma of an underlying, 0 is off"},
+			{Name: "maxtheta", Type: strategy.FloatParameter, Default: "0", Description: "absolute net theta of an underlying per day, 0 is off"},
+			{Name: "maxvega", Type: strategy.FloatParameter, Default: "0", Description: "absolute net vega of an underlying per percent, 0 is off"},
+			{Name: "maxnotional", Type: strategy.FloatParameter, Default: "0", Description: "absolute delta notional of an underlying, 0 is off"},
+			{Name: "pollinterval", Type: strategy.DurationParameter, Default: "1m", Description: "how often the positions are checked"},
+			{Name: "exit", Type: strategy.StringParameter, Default: "15:30", Description: "time the monitoring stops"},
+		},
+		Validate: func(parameters strategy.Parameters) error {
+			_, err := newConfig(parameters)
+			return err
+		},
+		New: func(dependencies strategy.Dependencies, parameters strategy.Parameters) (strategy.Strategy, error) {
+			config, err := newConfig(parameters)
+			if err != nil {
+				return nil, err
+			}
+			monitor, err := NewPortfolioMonitor(config, dependencies.Account, dependencies.Broker, dependencies.TimeZone)
+			if err != nil {
+				return nil, err
+			}
+			return &monitor, nil
+		},
+	})
+}
+
+func newConfig(parameters strategy.Parameters) (PortfolioConfig, error) {
+	config := PortfolioConfig{
+		Thresholds: Thresholds{
+			Delta:    parameters.Float("maxdelta"),
+			Gamma:    parameters.Float("maxgamma"),
+			Theta:    parameters.Float("maxtheta"),
+			Vega:     parameters.Float("maxvega"),
+			Notional: parameters.Float("maxnotional"),
+		},
+		PollInterval: parameters.Duration("pollinterval"),
+		Exit:         parameters.String("exit"),
+	}
+	if config.PollInterval <= 0 {
+		return PortfolioConfig{}, fmt.Errorf("pollinterval %s is not positive", config.PollInterval)
+	}
+	_, err := duration.TodayAt(config.Exit, *time.UTC)
+	if err != nil {
+		return PortfolioConfig{}, err
+	}
+
+	return config, nil
+}
+
+// PortfolioMonitor watches the positions of the account as a
+// whole, whichever strategy or hand placed them
+type PortfolioMonitor struct {
+	Config   PortfolioConfig
+	ExitTime time.Time
+	Broker   broker.Broker
+	TimeZone time.Location
+	Account  account.Account
+	// alerted are the alerts raised and not cleared yet, so
+	// an alert is sent once when its threshold is crossed
+	alerted map[string]bool
+}
+
+func NewPortfolioMonitor(config PortfolioConfig, account account.Account, broker broker.Broker, timeZone time.Location) (PortfolioMonitor, error) {
+	exitTime, err := duration.TodayAt(config.Exit, timeZone)
+	if err != nil {
+		return PortfolioMonitor{}, err
+	}
+
+	return PortfolioMonitor{
+		Config:   config,
+		ExitTime: exitTime,
+		Broker:   broker,
+		TimeZone: timeZone,
+		Account:  account,
+		alerted:  map[string]bool{},
+	}, nil
+}
+
+func (p *PortfolioMonitor) Start() error {
+	// Check if markets are open today ?
+	open, err := p.Broker.IsMarketOpen()
+	if err != nil {
+		return err
+	}
+	if !open {
+		log.Println("Market is closed")
+		return nil
+	}
+
+	log.Printf("Monitoring the portfolio till %s....", p.Config.Exit)
+	for time.Now().Before(p.ExitTime) {
+		exposures, err := p.Exposures()
+		if err != nil {
+			return err
+		}
+		for _, exposure := range exposures {
+			log.Printf("%s at %.2f: delta %.2f gamma %.4f theta %.2f vega %.2f notional %.2f of %.2f over %d positions", exposure.Underlying, exposure.Spot,
+				exposure.Delta, exposure.Gamma, exposure.Theta, exposure.Vega, exposure.Notional, exposure.GrossNotional, exposure.Positions)
+			if exposure.Partial() {
+				log.Printf("%s leaves out %s", exposure.Underlying, strings.Join(exposure.Skipped, ", "))
+			}
+		}
+		err = p.Check(exposures)
+		if err != nil {
+			return err
+		}
+		time.Sleep(p.Config.PollInterval)
+	}
+
+	return nil
+}
+
+func (p *PortfolioMonitor) Stop() error {
+	return nil
+}
+
+// Exposures gives the exposure of the open positions
+// per underlying, sorted by the underlying. Positions
+// which can't be valued are left out of it and listed in
+// the skipped positions of their underlying.
+func (p *PortfolioMonitor) Exposures() ([]Exposure, error) {
+	positions, err := p.Broker.GetPositions()
+	if err != nil {
+		return nil, err
+	}
+
+	exposures := map[string]*Exposure{}
+	// spotErrors are the underlyings whose spot is not found
+	spotErrors := map[string]error{}
+	skip := func(underlying, tradingSymbol string) {
+		exposure, ok := exposures[underlying]
+		if !ok {
+			exposure = &Exposure{Underlying: underlying}
+			exposures[underlying] = exposure
+		}
+		exposure.Skipped = append(exposure.Skipped, tradingSymbol)
+	}
+	for _, position := range positions {
+		if position.Quantity == 0 {
+			continue
+		}
+		// a position which can't be valued is left out with the
+		// rest still monitored rather than none of them at all
+		instrument, err := p.getInstrument(position)
+		if err != nil {
+			log.Printf("Skipping position %s as its instrument is not found because %s", position.TradingSymbol, err)
+			skip(p.getUnderlying(position), position.TradingSymbol)
+			continue
+		}
+		if err, ok := spotErrors[instrument.Name]; ok {
+			log.Printf("Skipping position %s as the spot of %s is not found because %s", position.TradingSymbol, instrument.Name, err)
+			skip(instrument.Name, position.TradingSymbol)
+			continue
+		}
+		exposure, ok := exposures[instrument.Name]
+		if !ok || exposure.Spot <= 0 {
+			spot, err := p.getSpot(instrument.Name)
+			if err != nil {
+				log.Printf("Skipping position %s as the spot of %s is not found because %s", position.TradingSymbol, instrument.Name, err)
+				spotErrors[instrument.Name] = err
+				skip(instrument.Name, position.TradingSymbol)
+				continue
+			}
+			if !ok {
+				exposure = &Exposure{Underlying: instrument.Name}
+				exposures[instrument.Name] = exposure
+			}
+			exposure.Spot = spot
+		}
+
+		quantity := float64(position.Quantity)
+		switch instrument.InstrumentType {
+		case symbol.InstrumentTypeCall, symbol.InstrumentTypePut:
+			greeks, err := options.GetGreeks(instrument, exposure.Spot, p.Broker)
+			if err != nil {
+				log.Printf("Skipping position %s as its greeks are not found because %s", position.TradingSymbol, err)
+				exposure.Skipped = append(exposure.Skipped, position.TradingSymbol)
+				continue
+			}
+			exposure.Delta += greeks.Delta * quantity
+			exposure.Gamma += greeks.Gamma * quantity
+			exposure.Theta += greeks.Theta * quantity
+			exposure.Vega += greeks.Vega * quantity
+		default:
+			// futures and stocks move one to one with the underlying
+			exposure.Delta += quantity
+		}
+		exposure.Positions++
+		exposure.GrossNotional += math.Abs(quantity) * exposure.Spot
+	}
+
+	result := []Exposure{}
+	for _, exposure := range exposures {
+		exposure.Notional = exposure.Delta * exposure.Spot
+		result = append(result, *exposure)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Underlying < result[j].Underlying
+	})
+
+	return result, nil
+}
+
+// Check emails the alerts of the exposures which crossed their
+// thresholds since the last check and clears the ones back in
+func (p *PortfolioMonitor) Check(exposures []Exposure) error {
+	alerts := Alerts(exposures, p.Config.Thresholds)
+	active := map[string]bool{}
+	messages := []string{}
+	for _, alert := range alerts {
+		key := alert.Underlying + ":" + alert.Measure
+		active[key] = true
+		if p.alerted[key] {
+			continue
+		}
+		p.alerted[key] = true
+		message := fmt.Sprintf("Net %s of %s is %.2f, past %.2f", alert.Measure, alert.Underlying, alert.Value, alert.Threshold)
+		if len(alert.Skipped) > 0 {
+			message += fmt.Sprintf(" leaving out %s", strings.Join(alert.Skipped, ", "))
+		}
+		messages = append(messages, message)
+	}
+	for key := range p.alerted {
+		if !active[key] {
+			log.Printf("%s is back within its threshold", key)
+			delete(p.alerted, key)
+		}
+	}
+	if len(messages) <= 0 {
+		return nil
+	}
+	message := strings.Join(messages, "\n")
+	log.Println(message)
+
+	return p.sendEmail(message)
+}
+
+// Alerts gives the measures of the exposures past the thresholds
+func Alerts(exposures []Exposure, thresholds Thresholds) []Alert {
+	alerts := []Alert{}
+	for _, exposure := range exposures {
+		for _, measure := range []struct {
+			name      string
+			value     float64
+			threshold float64
+		}{
+			{"delta", exposure.Delta, thresholds.Delta},
+			{"gamma", exposure.Gamma, thresholds.Gamma},
+			{"theta", exposure.Theta, thresholds.Theta},
+			{"vega", exposure.Vega, thresholds.Vega},
+			{"notional", exposure.Notional, thresholds.Notional},
+		} {
+			if measure.threshold > 0 && math.Abs(measure.value) > measure.threshold {
+				alerts = append(alerts, Alert{
+					Underlying: exposure.Underlying,
+					Measure:    measure.name,
+					Value:      measure.value,
+					Threshold:  measure.threshold,
+					Skipped:    exposure.Skipped,
+				})
+			}
+		}
+	}
+
+	return alerts
+}
+
+// getInstrument gives the instrument of the position with its
+// underlying, type, strike and expiry, which positions lack
+func (p *PortfolioMonitor) getInstrument(position models.Position) (models.Instrument, error) {
+	parsed, err := p.Broker.GetSymbolMapper().Parse(position.TradingSymbol)
+	if err != nil {
+		return models.Instrument{}, err
+	}
+	if !parsed.IsDerivative() {
+		return models.Instrument{
+			TradingSymbol:  position.TradingSymbol,
+			Exchange:       position.Exchange,
+			Name:           parsed.Underlying,
+			InstrumentType: parsed.InstrumentType,
+		}, nil
+	}
+	exchange := position.Exchange
+	if len(exchange) < 1 {
+		exchange = parsed.Exchange()
+	}
+	instrument, err := p.Broker.GetInstrument(position.TradingSymbol, exchange)
+	if err != nil {
+		return models.Instrument{}, err
+	}
+	if len(instrument.TradingSymbol) < 1 {
+		return models.Instrument{}, fmt.Errorf("instrument %s is not found", position.TradingSymbol)
+	}
+	if len(instrument.Name) < 1 {
+		instrument.Name = parsed.Underlying
+	}
+
+	return instrument, nil
+}
+
+// getUnderlying gives the underlying the trading symbol of the
+// position tells, or the trading symbol if it tells none
+func (p *PortfolioMonitor) getUnderlying(position models.Position) string {
+	parsed, err := p.Broker.GetSymbolMapper().Parse(position.TradingSymbol)
+	if err != nil || len(parsed.Underlying) < 1 {
+		return position.TradingSymbol
+	}
+
+	return parsed.Underlying
+}
+
+// getSpot gives the LTP of the index or
+// else the stock of the underlying
+func (p *PortfolioMonitor) getSpot(underlying string) (float64, error) {
+	spotSymbol, err := options.GetIndexSymbol(underlying, p.Broker)
+	if err != nil {
+		spotSymbol, err = p.Broker.GetSymbolMapper().Format(symbol.NewEquity(underlying))
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return options.GetLTP(spotSymbol, p.Broker)
+}
+
+// sendEmail sends the alerts to the account
+func (p *PortfolioMonitor) sendEmail(body string) error {
+	return utils.SendEmailTo(p.Account.Email, p.Account.Subject("Portfolio Alert"), body)
+}
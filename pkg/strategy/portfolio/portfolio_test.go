@@ -0,0 +1,52 @@
+package portfolio
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/symbol"
+)
+
+func TestExposuresListSkippedPositions(t *testing.T) {
+	mockBroker, err := broker.NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockBroker.Instruments = models.Instruments{
+		{TradingSymbol: "NIFTY24JANFUT", Exchange: models.ExchangeNFO, Name: "NIFTY", InstrumentType: symbol.InstrumentTypeFuture, Expiry: time.Date(2024, 1, 25, 0, 0, 0, 0, time.UTC)},
+	}
+	mockBroker.SetLTP("NIFTY 50", 21500)
+	mockBroker.SetLTP("RELIANCE", 2500)
+	mockBroker.Positions = models.Positions{
+		{Instrument: models.Instrument{TradingSymbol: "NIFTY24JANFUT", Exchange: models.ExchangeNFO}, Quantity: 50},
+		// the instruments of the options are not found
+		{Instrument: models.Instrument{TradingSymbol: "NIFTY24JAN21500CE", Exchange: models.ExchangeNFO}, Quantity: -50},
+		{Instrument: models.Instrument{TradingSymbol: "BANKNIFTY24JAN47000PE", Exchange: models.ExchangeNFO}, Quantity: -15},
+		{Instrument: models.Instrument{TradingSymbol: "RELIANCE", Exchange: models.ExchangeNSE}, Quantity: 10},
+	}
+	monitor := PortfolioMonitor{Broker: &mockBroker, alerted: map[string]bool{}}
+
+	exposures, err := monitor.Exposures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Exposure{
+		{Underlying: "BANKNIFTY", Skipped: []string{"BANKNIFTY24JAN47000PE"}},
+		{Underlying: "NIFTY", Spot: 21500, Positions: 1, Delta: 50, Notional: 50 * 21500, GrossNotional: 50 * 21500, Skipped: []string{"NIFTY24JAN21500CE"}},
+		{Underlying: "RELIANCE", Spot: 2500, Positions: 1, Delta: 10, Notional: 10 * 2500, GrossNotional: 10 * 2500},
+	}
+	if !reflect.DeepEqual(exposures, want) {
+		t.Fatalf("got exposures %+v, want %+v", exposures, want)
+	}
+	if !exposures[0].Partial() || !exposures[1].Partial() || exposures[2].Partial() {
+		t.Errorf("got partial exposures %t %t %t, want BANKNIFTY and NIFTY only", exposures[0].Partial(), exposures[1].Partial(), exposures[2].Partial())
+	}
+
+	alerts := Alerts(exposures, Thresholds{Delta: 20})
+	if len(alerts) != 1 || alerts[0].Underlying != "NIFTY" || !reflect.DeepEqual(alerts[0].Skipped, []string{"NIFTY24JAN21500CE"}) {
+		t.Errorf("got alerts %+v, want the delta of NIFTY leaving out its call", alerts)
+	}
+}
@@ -0,0 +1,125 @@
+package strategy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/database"
+	"github.com/rohitsakala/strategies/pkg/sizing"
+	"github.com/rohitsakala/strategies/pkg/watcher"
+)
+
+// Dependencies are what every strategy gets to run with
+type Dependencies struct {
+	Account  account.Account
+	Broker   broker.Broker
+	TimeZone time.Location
+	Database database.Database
+	Watcher  watcher.Watcher
+	Sizer    sizing.Sizer
+}
+
+// Definition is how a strategy registers itself. Validate is
+// optional and checks the parameters together after each
+// one is parsed on its own.
+type Definition struct {
+	Name        string
+	Description string
+	Parameters  []Parameter
+	Validate    func(parameters Parameters) error
+	New         func(dependencies Dependencies, parameters Parameters) (Strategy, error)
+}
+
+var (
+	definitions      = map[string]Definition{}
+	definitionsMutex = &sync.RWMutex{}
+)
+
+// Register makes the strategy available by name. Strategies
+// call it from init, so importing the package of a strategy
+// is enough to run it. It panics on a duplicate name.
+func Register(definition Definition) {
+	definitionsMutex.Lock()
+	defer definitionsMutex.Unlock()
+
+	if len(definition.Name) < 1 || definition.New == nil {
+		panic("strategy: Register needs a name and a constructor")
+	}
+	if _, ok := definitions[definition.Name]; ok {
+		panic("strategy: Register called twice for " + definition.Name)
+	}
+	definitions[definition.Name] = definition
+}
+
+// Names gives the names of the registered strategies
+func Names() []string {
+	definitionsMutex.RLock()
+	defer definitionsMutex.RUnlock()
+
+	names := []string{}
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func GetDefinition(name string) (Definition, error) {
+	definitionsMutex.RLock()
+	definition, ok := definitions[name]
+	definitionsMutex.RUnlock()
+	if !ok {
+		return Definition{}, fmt.Errorf("unknown strategy %s, known ones are %s", name, strings.Join(Names(), ", "))
+	}
+
+	return definition, nil
+}
+
+// GetStrategy creates the strategy with the arguments
+// parsed against its parameters
+func GetStrategy(name string, dependencies Dependencies, args []string) (Strategy, error) {
+	definition, err := GetDefinition(name)
+	if err != nil {
+		return nil, err
+	}
+	parameters, err := ParseParameters(definition.Parameters, args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v\n%s", name, err, definition.Usage())
+	}
+	if definition.Validate != nil {
+		err = definition.Validate(parameters)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	return definition.New(dependencies, parameters)
+}
+
+// Usage describes the parameters of the strategy
+func (d Definition) Usage() string {
+	lines := []string{fmt.Sprintf("usage: %s", d.Name)}
+	for _, parameter := range d.Parameters {
+		line := fmt.Sprintf("  %s (%s)", parameter.Name, parameter.Type)
+		if len(parameter.Choices) > 0 {
+			line += " one of " + strings.Join(parameter.Choices, "|")
+		}
+		if parameter.Required() {
+			line += ", required"
+		} else {
+			line += ", default " + parameter.Default
+		}
+		if len(parameter.Description) > 0 {
+			line += ": " + parameter.Description
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
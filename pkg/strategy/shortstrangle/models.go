@@ -0,0 +1,64 @@
+package shortstrangle
+
+import (
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const (
+	// AdjustUntested rolls the untested leg in towards the
+	// delta of the tested leg
+	AdjustUntested = "untested"
+	// AdjustTested rolls the tested leg out to the entry delta
+	AdjustTested = "tested"
+)
+
+type ShortStranglePositions struct {
+	SellCEOptionPosition models.Order
+	SellPEOptionPosition models.Order
+}
+
+// Adjustment is a roll of one leg of the strangle
+type Adjustment struct {
+	Number   int
+	Time     time.Time
+	Leg      string
+	Closed   string
+	Opened   string
+	NetDelta float64
+}
+
+// ShortStrangleState is saved in the database after every
+// change so a restarted run carries on with the same legs
+type ShortStrangleState struct {
+	Data        ShortStranglePositions
+	Adjustments []Adjustment
+	Exited      bool
+	// Lots are the lots of the legs, the rolled legs
+	// keep the lots the strangle was sized with
+	Lots int
+	// FailedAttempts counts the rolls and exits which failed, so
+	// the orders of the next try get tags of their own instead of
+	// finding the orders of the failed one
+	FailedAttempts int
+}
+
+type ShortStrangleConfig struct {
+	Underlying   string
+	ProductType  string
+	Lots         int
+	ExpiryOffset int
+	StrikeStep   float64
+	// Delta is the absolute delta of the legs sold on entry
+	Delta float64
+	// AdjustAt is the absolute net delta of the position
+	// per lot which triggers an adjustment
+	AdjustAt       float64
+	Adjustment     string
+	MaxAdjustments int
+	EntryStart     string
+	EntryEnd       string
+	Exit           string
+	PollInterval   time.Duration
+}
@@ -0,0 +1,611 @@
+package shortstrangle
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/database"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/reconciler"
+	"github.com/rohitsakala/strategies/pkg/sizing"
+	"github.com/rohitsakala/strategies/pkg/strategy"
+	"github.com/rohitsakala/strategies/pkg/utils"
+	"github.com/rohitsakala/strategies/pkg/utils/duration"
+	"github.com/rohitsakala/strategies/pkg/utils/maths"
+	"github.com/rohitsakala/strategies/pkg/utils/options"
+	"github.com/rohitsakala/strategies/pkg/watcher"
+)
+
+const (
+	ShortStrangleStrategyDatabaseName = "shortstrangle"
+	ShortStrangleBasketTimeout        = 3 * time.Minute
+	// maxStrikeSearch is the most strikes tried around the
+	// estimated strike when looking for a delta
+	maxStrikeSearch = 6
+)
+
+func init() {
+	strategy.Register(strategy.Definition{
+		Name:        ShortStrangleStrategyDatabaseName,
+		Description: "Sells OTM CE and PE at a delta and rolls a leg when the net delta grows",
+		Parameters: []strategy.Parameter{
+			{Name: "product", Type: strategy.StringParameter, Default: string(models.ProductNRML), Choices: []string{string(models.ProductMIS), string(models.ProductNRML)}, Description: "product type of the orders"},
+			{Name: "underlying", Type: strategy.StringParameter, Default: "NIFTY"},
+			{Name: "lots", Type: strategy.IntParameter, Default: "1", Description: "lots of every leg before the lot multiplier of the account"},
+			{Name: "expiryoffset", Type: strategy.IntParameter, Default: "0", Description: "0 is the current weekly expiry, 1 the next one"},
+			{Name: "strikestep", Type: strategy.FloatParameter, Default: "50", Description: "difference between the strikes of the underlying"},
+			{Name: "delta", Type: strategy.FloatParameter, Default: "0.16", Description: "absolute delta of the legs sold"},
+			{Name: "adjustat", Type: strategy.FloatParameter, Default: "0.15", Description: "absolute net delta per lot which triggers an adjustment"},
+			{Name: "adjustment", Type: strategy.StringParameter, Default: AdjustUntested, Choices: []string{AdjustUntested, AdjustTested}, Description: "roll the untested leg in or the tested leg out"},
+			{Name: "maxadjustments", Type: strategy.IntParameter, Default: "3"},
+			{Name: "entry", Type: strategy.StringParameter, Default: "09:30", Description: "earliest entry time"},
+			{Name: "entryend", Type: strategy.StringParameter, Default: "14:00", Description: "no entry after this time"},
+			{Name: "exit", Type: strategy.StringParameter, Default: "15:15", Description: "exit time of the legs"},
+			{Name: "pollinterval", Type: strategy.DurationParameter, Default: "1m"},
+		},
+		Validate: func(parameters strategy.Parameters) error {
+			_, err := newConfig(parameters)
+			return err
+		},
+		New: func(dependencies strategy.Dependencies, parameters strategy.Parameters) (strategy.Strategy, error) {
+			config, err := newConfig(parameters)
+			if err != nil {
+				return nil, err
+			}
+			shortStrangleStrategy, err := NewShortStrangleStrategy(config, dependencies.Account, dependencies.Broker, dependencies.TimeZone, dependencies.Database, dependencies.Watcher, dependencies.Sizer)
+			if err != nil {
+				return nil, err
+			}
+			return &shortStrangleStrategy, nil
+		},
+	})
+}
+
+func newConfig(parameters strategy.Parameters) (ShortStrangleConfig, error) {
+	config := ShortStrangleConfig{
+		Underlying:     parameters.String("underlying"),
+		ProductType:    parameters.String("product"),
+		Lots:           parameters.Int("lots"),
+		ExpiryOffset:   parameters.Int("expiryoffset"),
+		StrikeStep:     parameters.Float("strikestep"),
+		Delta:          parameters.Float("delta"),
+		AdjustAt:       parameters.Float("adjustat"),
+		Adjustment:     parameters.String("adjustment"),
+		MaxAdjustments: parameters.Int("maxadjustments"),
+		EntryStart:     parameters.String("entry"),
+		EntryEnd:       parameters.String("entryend"),
+		Exit:           parameters.String("exit"),
+		PollInterval:   parameters.Duration("pollinterval"),
+	}
+
+	if config.Lots < 1 {
+		return ShortStrangleConfig{}, fmt.Errorf("lots %d is less than 1", config.Lots)
+	}
+	if config.ExpiryOffset < 0 {
+		return ShortStrangleConfig{}, fmt.Errorf("expiryoffset %d is negative", config.ExpiryOffset)
+	}
+	if config.StrikeStep <= 0 {
+		return ShortStrangleConfig{}, fmt.Errorf("strikestep %v is not positive", config.StrikeStep)
+	}
+	if config.Delta <= 0 || config.Delta >= 0.5 {
+		return ShortStrangleConfig{}, fmt.Errorf("delta %v is not between 0 and 0.5", config.Delta)
+	}
+	if config.AdjustAt <= 0 {
+		return ShortStrangleConfig{}, fmt.Errorf("adjustat %v is not positive", config.AdjustAt)
+	}
+	if config.MaxAdjustments < 0 {
+		return ShortStrangleConfig{}, fmt.Errorf("maxadjustments %d is negative", config.MaxAdjustments)
+	}
+	if config.PollInterval <= 0 {
+		return ShortStrangleConfig{}, fmt.Errorf("pollinterval %s is not positive", config.PollInterval)
+	}
+	entryStart, err := duration.TodayAt(config.EntryStart, *time.UTC)
+	if err != nil {
+		return ShortStrangleConfig{}, err
+	}
+	entryEnd, err := duration.TodayAt(config.EntryEnd, *time.UTC)
+	if err != nil {
+		return ShortStrangleConfig{}, err
+	}
+	exit, err := duration.TodayAt(config.Exit, *time.UTC)
+	if err != nil {
+		return ShortStrangleConfig{}, err
+	}
+	if !entryStart.Before(entryEnd) || !entryEnd.Before(exit) {
+		return ShortStrangleConfig{}, fmt.Errorf("entry %s, entryend %s and exit %s are not in order", config.EntryStart, config.EntryEnd, config.Exit)
+	}
+
+	return config, nil
+}
+
+type ShortStrangleStrategy struct {
+	Config         ShortStrangleConfig
+	EntryStartTime time.Time
+	EntryEndTime   time.Time
+	ExitTime       time.Time
+	State          ShortStrangleState
+	Broker         broker.Broker
+	TimeZone       time.Location
+	States         database.StrategyStateRepo
+	Watcher        watcher.Watcher
+	// RunID identifies the run of the day in the order tags
+	RunID      string
+	Account    account.Account
+	Sizer      sizing.Sizer
+	Reconciler reconciler.Reconciler
+	// Mail sends the trade updates
+	Mail func(to, subject, body string) error
+}
+
+func NewShortStrangleStrategy(config ShortStrangleConfig, account account.Account, broker broker.Broker, timeZone time.Location, db database.Database, watcher watcher.Watcher, sizer sizing.Sizer) (ShortStrangleStrategy, error) {
+	entryStartTime, err := duration.TodayAt(config.EntryStart, timeZone)
+	if err != nil {
+		return ShortStrangleStrategy{}, err
+	}
+	entryEndTime, err := duration.TodayAt(config.EntryEnd, timeZone)
+	if err != nil {
+		return ShortStrangleStrategy{}, err
+	}
+	exitTime, err := duration.TodayAt(config.Exit, timeZone)
+	if err != nil {
+		return ShortStrangleStrategy{}, err
+	}
+	states, err := database.NewStrategyStateRepo(db)
+	if err != nil {
+		return ShortStrangleStrategy{}, err
+	}
+	positionsReconciler, err := reconciler.NewReconciler(account, broker, ShortStrangleStrategyDatabaseName)
+	if err != nil {
+		return ShortStrangleStrategy{}, err
+	}
+
+	return ShortStrangleStrategy{
+		Config:         config,
+		EntryStartTime: entryStartTime,
+		EntryEndTime:   entryEndTime,
+		ExitTime:       exitTime,
+		Broker:         broker,
+		TimeZone:       timeZone,
+		States:         states,
+		Watcher:        watcher,
+		RunID:          time.Now().In(&timeZone).Format("20060102"),
+		Account:        account,
+		Sizer:          sizer,
+		Reconciler:     positionsReconciler,
+		Mail:           utils.SendEmailTo,
+	}, nil
+}
+
+func (s *ShortStrangleStrategy) Start() error {
+	// Check if markets are open today ?
+	open, err := s.Broker.IsMarketOpen()
+	if err != nil {
+		return err
+	}
+	if !open {
+		log.Println("Market is closed")
+		return nil
+	}
+
+	found, err := s.States.Load(ShortStrangleStrategyDatabaseName, s.stateID(), &s.State)
+	if err != nil {
+		return err
+	}
+	if s.State.Exited {
+		log.Printf("Short strangle already exited today.")
+		return nil
+	}
+	_, err = s.reconcile("startup", found)
+	if err != nil {
+		return err
+	}
+	if found && len(s.State.Data.SellCEOptionPosition.OrderID) > 0 && len(s.State.Data.SellPEOptionPosition.OrderID) > 0 {
+		log.Printf("Resuming short strangle of %s and %s with %d adjustments", s.State.Data.SellCEOptionPosition.TradingSymbol,
+			s.State.Data.SellPEOptionPosition.TradingSymbol, len(s.State.Adjustments))
+		return s.WaitAndWatch()
+	}
+
+	log.Printf("Waiting for %s to %s....", s.Config.EntryStart, s.Config.EntryEnd)
+	for !duration.ValidateTime(s.EntryStartTime, s.EntryEndTime, s.TimeZone) {
+		if time.Now().After(s.EntryEndTime) {
+			return fmt.Errorf("entry time till %s is over", s.Config.EntryEnd)
+		}
+		time.Sleep(1 * time.Minute)
+		log.Printf("Time : %v", time.Now().In(&s.TimeZone))
+	}
+	log.Printf("Entering %s to %s.", s.Config.EntryStart, s.Config.EntryEnd)
+
+	spot, err := s.getSpot()
+	if err != nil {
+		return err
+	}
+	s.State.Data.SellCEOptionPosition, _, err = s.findLeg("CE", s.Config.Delta, spot, "sellce")
+	if err != nil {
+		return err
+	}
+	s.State.Data.SellPEOptionPosition, _, err = s.findLeg("PE", s.Config.Delta, spot, "sellpe")
+	if err != nil {
+		return err
+	}
+	// the strangle has no stop loss, so risk sizing can't size it
+	s.State.Lots, err = s.Sizer.SizeLegs(sizing.Trade{Lots: s.Config.Lots}, &s.State.Data.SellCEOptionPosition, &s.State.Data.SellPEOptionPosition)
+	if err != nil {
+		return err
+	}
+	log.Printf("Placing basket of %s and %s with quantity %d....", s.State.Data.SellCEOptionPosition.TradingSymbol,
+		s.State.Data.SellPEOptionPosition.TradingSymbol, s.State.Data.SellCEOptionPosition.Quantity)
+
+	basket := models.RefOrders{&s.State.Data.SellCEOptionPosition, &s.State.Data.SellPEOptionPosition}
+	err = s.Broker.PlaceBasketOrder(basket, ShortStrangleBasketTimeout)
+	if err != nil {
+		return err
+	}
+	err = s.saveState()
+	if err != nil {
+		return err
+	}
+	err = s.sendEmail(fmt.Sprintf("Sold %s with Avg Price %f and %s with Avg Price %f",
+		s.State.Data.SellCEOptionPosition.TradingSymbol, s.State.Data.SellCEOptionPosition.AveragePrice,
+		s.State.Data.SellPEOptionPosition.TradingSymbol, s.State.Data.SellPEOptionPosition.AveragePrice))
+	if err != nil {
+		return err
+	}
+
+	return s.WaitAndWatch()
+}
+
+func (s *ShortStrangleStrategy) Stop() error {
+	// Check if markets are open today ?
+	open, err := s.Broker.IsMarketOpen()
+	if err != nil {
+		return err
+	}
+	if !open {
+		log.Println("Market is closed")
+		return nil
+	}
+	if s.State.Exited || len(s.State.Data.SellCEOptionPosition.OrderID) < 1 {
+		log.Printf("No positions to exit.")
+		return nil
+	}
+
+	mismatches, err := s.reconcile("exit", true)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Exiting all current positions...")
+	exits := models.RefOrders{}
+	for _, leg := range (models.Orders{s.State.Data.SellCEOptionPosition, s.State.Data.SellPEOptionPosition}) {
+		exit := s.exitOrder(leg)
+		// only what the broker still holds is bought back
+		exit.Quantity = mismatches.Held(leg)
+		if exit.Quantity <= 0 {
+			log.Printf("Not exiting %s as it isn't held anymore", leg.TradingSymbol)
+			continue
+		}
+		exits = append(exits, &exit)
+	}
+	err = s.Broker.PlaceBasketOrder(exits, ShortStrangleBasketTimeout)
+	if err != nil {
+		return s.failAttempt(err)
+	}
+	s.State.Exited = true
+	err = s.saveState()
+	if err != nil {
+		return err
+	}
+	log.Printf("Exited all current positions.")
+
+	return s.sendEmail(fmt.Sprintf("Exited %s and %s after %d adjustments", s.State.Data.SellCEOptionPosition.TradingSymbol,
+		s.State.Data.SellPEOptionPosition.TradingSymbol, len(s.State.Adjustments)))
+}
+
+// WaitAndWatch checks the net delta every poll interval
+// and adjusts the strangle till the exit time
+func (s *ShortStrangleStrategy) WaitAndWatch() error {
+	log.Printf("Watching net delta till %s....", s.Config.Exit)
+	for time.Now().Before(s.ExitTime) {
+		time.Sleep(s.Config.PollInterval)
+		err := s.Watcher.Poll()
+		if err != nil {
+			return err
+		}
+		if s.Reconciler.Due() {
+			_, err = s.reconcile("watch", true)
+			if err != nil {
+				return err
+			}
+		}
+		err = s.checkAdjustment()
+		if err != nil {
+			// the legs are still exited at the exit time
+			log.Printf("Couldn't check the adjustment because %s", err)
+			err = s.sendEmail(fmt.Sprintf("Couldn't check the adjustment because %s", err))
+			if err != nil {
+				log.Println(err)
+			}
+		}
+	}
+	log.Printf("Time : %v", time.Now().In(&s.TimeZone))
+
+	return nil
+}
+
+// checkAdjustment rolls a leg if the net delta
+// per lot of the strangle crossed the threshold
+func (s *ShortStrangleStrategy) checkAdjustment() error {
+	spot, err := s.getSpot()
+	if err != nil {
+		return err
+	}
+	ceGreeks, err := options.GetGreeks(s.State.Data.SellCEOptionPosition.Instrument, spot, s.Broker)
+	if err != nil {
+		return err
+	}
+	peGreeks, err := options.GetGreeks(s.State.Data.SellPEOptionPosition.Instrument, spot, s.Broker)
+	if err != nil {
+		return err
+	}
+	// both legs are short, so their deltas flip
+	netDelta := -(ceGreeks.Delta + peGreeks.Delta)
+	log.Printf("Net delta %f with CE delta %f and PE delta %f", netDelta, ceGreeks.Delta, peGreeks.Delta)
+	if math.Abs(netDelta) < s.Config.AdjustAt {
+		return nil
+	}
+	if len(s.State.Adjustments) >= s.Config.MaxAdjustments {
+		log.Printf("Net delta %f is past %f but all %d adjustments are done", netDelta, s.Config.AdjustAt, s.Config.MaxAdjustments)
+		return nil
+	}
+
+	// a rally makes the net delta negative and tests the CE
+	tested, untested := "CE", "PE"
+	testedDelta := ceGreeks.Delta
+	if netDelta > 0 {
+		tested, untested = "PE", "CE"
+		testedDelta = peGreeks.Delta
+	}
+
+	legName := s.adjustmentLegName()
+	var roll string
+	var leg models.Order
+	if s.Config.Adjustment == AdjustUntested {
+		roll = untested
+		leg, _, err = s.findLeg(untested, math.Abs(testedDelta), spot, legName)
+	} else {
+		roll = tested
+		leg, _, err = s.findLeg(tested, s.Config.Delta, spot, legName)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.roll(roll, leg, netDelta)
+}
+
+// roll buys back the leg of the option type and sells the new leg
+func (s *ShortStrangleStrategy) roll(optionType string, leg models.Order, netDelta float64) error {
+	current := &s.State.Data.SellCEOptionPosition
+	other := s.State.Data.SellPEOptionPosition
+	if optionType == "PE" {
+		current = &s.State.Data.SellPEOptionPosition
+		other = s.State.Data.SellCEOptionPosition
+	}
+	// an untested leg stops at a straddle
+	if (optionType == "CE" && leg.StrikePrice < other.StrikePrice) || (optionType == "PE" && leg.StrikePrice > other.StrikePrice) {
+		log.Printf("Rolling %s to the strike %f of the other leg instead of %f", optionType, other.StrikePrice, leg.StrikePrice)
+		var err error
+		leg, err = s.calculateLeg(optionType, other.StrikePrice, s.adjustmentLegName())
+		if err != nil {
+			return err
+		}
+	}
+	if leg.TradingSymbol == current.TradingSymbol {
+		log.Printf("Not rolling %s as it is already at the strike", current.TradingSymbol)
+		return nil
+	}
+
+	log.Printf("Rolling %s to %s at net delta %f....", current.TradingSymbol, leg.TradingSymbol, netDelta)
+	exit := s.exitOrder(*current)
+	err := s.Broker.PlaceBasketOrder(models.RefOrders{&exit, &leg}, ShortStrangleBasketTimeout)
+	if err != nil {
+		return s.failAttempt(err)
+	}
+	adjustment := Adjustment{
+		Number:   len(s.State.Adjustments) + 1,
+		Time:     time.Now(),
+		Leg:      optionType,
+		Closed:   current.TradingSymbol,
+		Opened:   leg.TradingSymbol,
+		NetDelta: netDelta,
+	}
+	s.State.Adjustments = append(s.State.Adjustments, adjustment)
+	*current = leg
+	err = s.saveState()
+	if err != nil {
+		return err
+	}
+
+	return s.sendEmail(fmt.Sprintf("Adjustment %d rolled %s at %f to %s at %f on net delta %f", adjustment.Number,
+		adjustment.Closed, exit.AveragePrice, adjustment.Opened, leg.AveragePrice, netDelta))
+}
+
+// findLeg gives the leg of the option type whose absolute delta is
+// the closest to the given one. The strike is estimated from the
+// volatility of the ATM option and then searched around.
+func (s *ShortStrangleStrategy) findLeg(optionType string, delta float64, spot float64, legName string) (models.Order, options.Greeks, error) {
+	atm := maths.GetNearestMultiple(spot, s.Config.StrikeStep)
+	atmLeg, err := s.calculateLeg(optionType, atm, legName)
+	if err != nil {
+		return models.Order{}, options.Greeks{}, err
+	}
+	atmGreeks, err := options.GetGreeks(atmLeg.Instrument, spot, s.Broker)
+	if err != nil {
+		return models.Order{}, options.Greeks{}, err
+	}
+	estimate, err := options.StrikeForDelta(optionType, spot, delta, options.TimeToExpiry(atmLeg.Expiry, time.Now()), atmGreeks.ImpliedVolatility)
+	if err != nil {
+		return models.Order{}, options.Greeks{}, err
+	}
+
+	// further out of the money is up for CE and down for PE
+	outwards := s.Config.StrikeStep
+	if optionType == "PE" {
+		outwards = -outwards
+	}
+	strike := maths.GetNearestMultiple(estimate, s.Config.StrikeStep)
+	var best models.Order
+	var bestGreeks options.Greeks
+	direction := 0.0
+	for i := 0; i < maxStrikeSearch; i++ {
+		leg, err := s.calculateLeg(optionType, strike, legName)
+		if err != nil {
+			return models.Order{}, options.Greeks{}, err
+		}
+		greeks, err := options.GetGreeks(leg.Instrument, spot, s.Broker)
+		if err != nil {
+			return models.Order{}, options.Greeks{}, err
+		}
+		if len(best.TradingSymbol) < 1 || math.Abs(math.Abs(greeks.Delta)-delta) < math.Abs(math.Abs(bestGreeks.Delta)-delta) {
+			best, bestGreeks = leg, greeks
+		}
+
+		next := outwards
+		if math.Abs(greeks.Delta) < delta {
+			next = -outwards
+		}
+		if direction != 0 && next != direction {
+			// crossed the delta
+			break
+		}
+		direction = next
+		strike += next
+	}
+	log.Printf("Found %s with delta %f for delta %f", best.TradingSymbol, bestGreeks.Delta, delta)
+
+	return best, bestGreeks, nil
+}
+
+func (s *ShortStrangleStrategy) calculateLeg(optionType string, strikePrice float64, legName string) (models.Order, error) {
+	leg := models.Order{
+		Instrument: models.Instrument{
+			Exchange:       models.ExchangeNFO,
+			Name:           s.Config.Underlying,
+			InstrumentType: optionType,
+			StrikePrice:    strikePrice,
+		},
+		Tag:             broker.NewOrderTag(ShortStrangleStrategyDatabaseName, s.RunID, legName),
+		TransactionType: models.TransactionTypeSell,
+		Product:         models.Product(s.Config.ProductType),
+		OrderType:       models.OrderTypeLimit,
+	}
+
+	legSymbol, err := options.GetSymbol(s.Config.Underlying, options.WEEK, s.Config.ExpiryOffset, strikePrice, optionType, s.Broker)
+	if err != nil {
+		return models.Order{}, err
+	}
+	leg.TradingSymbol = legSymbol
+
+	leg.LotSize, err = options.GetLotSize(legSymbol, s.Broker)
+	if err != nil {
+		return models.Order{}, err
+	}
+	leg.Quantity = s.lots() * leg.LotSize
+
+	leg.Expiry, err = options.GetExpiry(s.Config.Underlying, options.WEEK, s.Config.ExpiryOffset, strikePrice, optionType, s.Broker)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	return leg, nil
+}
+
+// lots gives the lots of the strangle, one till it is sized.
+// A state saved before the lots were kept has them in the
+// quantity of its legs.
+func (s *ShortStrangleStrategy) lots() int {
+	if s.State.Lots > 0 {
+		return s.State.Lots
+	}
+	leg := s.State.Data.SellCEOptionPosition
+	if leg.LotSize > 0 && leg.Quantity >= leg.LotSize {
+		return leg.Quantity / leg.LotSize
+	}
+
+	return 1
+}
+
+func (s *ShortStrangleStrategy) getSpot() (float64, error) {
+	indexSymbol, err := options.GetIndexSymbol(s.Config.Underlying, s.Broker)
+	if err != nil {
+		return 0, err
+	}
+
+	return options.GetLTP(indexSymbol, s.Broker)
+}
+
+// reconcile compares the legs with the positions of the broker
+// and emails the mismatches if there are any. Extra positions
+// are only squared off as configured once the legs are known.
+func (s *ShortStrangleStrategy) reconcile(when string, known bool) (reconciler.Mismatches, error) {
+	holdings := reconciler.HoldingsOf(s.State.Data.SellCEOptionPosition, s.State.Data.SellPEOptionPosition)
+	reconcile := s.Reconciler.Report
+	if known {
+		reconcile = s.Reconciler.Reconcile
+	}
+	mismatches, err := reconcile(holdings)
+	if err != nil {
+		return nil, err
+	}
+	if len(mismatches) <= 0 {
+		return mismatches, nil
+	}
+
+	return mismatches, s.sendEmail(fmt.Sprintf("Positions differ from the legs at %s\n%s", when, mismatches))
+}
+
+// stateID keeps the state of the accounts running
+// the strategy on the same day apart
+func (s *ShortStrangleStrategy) stateID() string {
+	return fmt.Sprintf("%s-%s", s.RunID, s.Account)
+}
+
+func (s *ShortStrangleStrategy) saveState() error {
+	return s.States.Save(ShortStrangleStrategyDatabaseName, s.stateID(), s.State)
+}
+
+// sendEmail sends the update to the account
+func (s *ShortStrangleStrategy) sendEmail(body string) error {
+	return s.Mail(s.Account.Email, s.Account.Subject("Short Strangle Trade Update"), body)
+}
+
+// adjustmentLegName names the leg the next adjustment sells
+func (s *ShortStrangleStrategy) adjustmentLegName() string {
+	return fmt.Sprintf("adj%d.%d", len(s.State.Adjustments)+1, s.State.FailedAttempts)
+}
+
+// exitOrder buys back the leg
+func (s *ShortStrangleStrategy) exitOrder(leg models.Order) models.Order {
+	leg = leg.Unplaced()
+	leg.TransactionType = leg.TransactionType.Opposite()
+	leg.Tag = broker.NewOrderTag(leg.Tag, "exit", strconv.Itoa(s.State.FailedAttempts))
+
+	return leg
+}
+
+// failAttempt saves that a basket failed and was rolled back,
+// so the orders of the next try don't find the ones of this one
+func (s *ShortStrangleStrategy) failAttempt(err error) error {
+	s.State.FailedAttempts++
+	saveErr := s.saveState()
+	if saveErr != nil {
+		return fmt.Errorf("%s and could not save the failed attempt because %s", err, saveErr)
+	}
+
+	return err
+}
@@ -0,0 +1,177 @@
+package shortstrangle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/database"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/reconciler"
+)
+
+func TestCalculateLegOnExpiryOffset(t *testing.T) {
+	firstWeek := time.Date(2024, time.January, 4, 0, 0, 0, 0, time.UTC)
+	secondWeek := time.Date(2024, time.January, 11, 0, 0, 0, 0, time.UTC)
+	mockBroker, err := broker.NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, instrument := range []struct {
+		tradingSymbol string
+		expiry        time.Time
+	}{
+		{"NIFTY2410421500PE", firstWeek},
+		{"NIFTY2411121500PE", secondWeek},
+	} {
+		mockBroker.Instruments = append(mockBroker.Instruments, models.Instrument{
+			TradingSymbol:  instrument.tradingSymbol,
+			Exchange:       models.ExchangeNFO,
+			Name:           "NIFTY",
+			InstrumentType: "PE",
+			StrikePrice:    21500,
+			Expiry:         instrument.expiry,
+			LotSize:        50,
+		})
+	}
+
+	tests := []struct {
+		expiryOffset int
+		wantSymbol   string
+		wantExpiry   time.Time
+	}{
+		{expiryOffset: 0, wantSymbol: "NIFTY2410421500PE", wantExpiry: firstWeek},
+		{expiryOffset: 1, wantSymbol: "NIFTY2411121500PE", wantExpiry: secondWeek},
+	}
+	for _, test := range tests {
+		s := ShortStrangleStrategy{
+			Config: ShortStrangleConfig{
+				Underlying:   "NIFTY",
+				ProductType:  string(models.ProductNRML),
+				ExpiryOffset: test.expiryOffset,
+			},
+			Broker: &mockBroker,
+			RunID:  "20240102",
+		}
+		leg, err := s.calculateLeg("PE", 21500, "sellpe")
+		if err != nil {
+			t.Fatalf("offset %d: %s", test.expiryOffset, err)
+		}
+		if leg.TradingSymbol != test.wantSymbol || !leg.Expiry.Equal(test.wantExpiry) {
+			t.Errorf("offset %d: got %s expiring %s, want %s expiring %s", test.expiryOffset, leg.TradingSymbol, leg.Expiry, test.wantSymbol, test.wantExpiry)
+		}
+		if leg.Quantity != 50 {
+			t.Errorf("offset %d: got quantity %d, want 50", test.expiryOffset, leg.Quantity)
+		}
+	}
+}
+
+func TestFailedRollThenRollAndStopLeaveAccountFlat(t *testing.T) {
+	expiry := time.Now().AddDate(0, 0, 7)
+	mockBroker, err := broker.NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, instrument := range []struct {
+		tradingSymbol string
+		optionType    string
+		strike        float64
+	}{
+		{"NIFTY24JAN21500CE", "CE", 21500},
+		{"NIFTY24JAN21600CE", "CE", 21600},
+		{"NIFTY24JAN21400PE", "PE", 21400},
+	} {
+		mockBroker.Instruments = append(mockBroker.Instruments, models.Instrument{
+			TradingSymbol:  instrument.tradingSymbol,
+			Exchange:       models.ExchangeNFO,
+			Name:           "NIFTY",
+			InstrumentType: instrument.optionType,
+			StrikePrice:    instrument.strike,
+			Expiry:         expiry,
+			LotSize:        50,
+		})
+	}
+	memoryDatabase := database.NewMemoryDatabase()
+	states, err := database.NewStrategyStateRepo(&memoryDatabase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	positionsReconciler, err := reconciler.NewReconciler(account.Account{}, &mockBroker, ShortStrangleStrategyDatabaseName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := ShortStrangleStrategy{
+		Config: ShortStrangleConfig{
+			Underlying:  "NIFTY",
+			ProductType: string(models.ProductNRML),
+		},
+		Broker:     &mockBroker,
+		States:     states,
+		RunID:      "20240102",
+		Reconciler: positionsReconciler,
+		Mail: func(to, subject, body string) error {
+			return nil
+		},
+	}
+	s.State.Data.SellCEOptionPosition, err = s.calculateLeg("CE", 21500, "sellce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.State.Data.SellPEOptionPosition, err = s.calculateLeg("PE", 21400, "sellpe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = mockBroker.PlaceBasketOrder(models.RefOrders{&s.State.Data.SellCEOptionPosition, &s.State.Data.SellPEOptionPosition}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPositions := func(when string, want map[string]int) {
+		t.Helper()
+		positions, err := mockBroker.GetPositions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, position := range positions {
+			if position.Quantity != want[position.TradingSymbol] {
+				t.Errorf("got %d of %s after %s, want %d", position.Quantity, position.TradingSymbol, when, want[position.TradingSymbol])
+			}
+		}
+	}
+
+	// the CE is bought back but the new CE can't be sold,
+	// so the CE bought back gets sold again
+	leg, err := s.calculateLeg("CE", 21600, s.adjustmentLegName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockBroker.FailPlaceOrder(leg.Tag, broker.MockPlaceError{Err: errors.New("insufficient margin"), Times: 1})
+	err = s.roll("CE", leg, -0.3)
+	if err == nil {
+		t.Fatal("got no error, want the roll to fail")
+	}
+	checkPositions("the failed roll", map[string]int{"NIFTY24JAN21500CE": -50, "NIFTY24JAN21400PE": -50})
+	if len(s.State.Adjustments) != 0 || s.State.Data.SellCEOptionPosition.TradingSymbol != "NIFTY24JAN21500CE" {
+		t.Fatalf("got %d adjustments with CE %s, want the CE kept", len(s.State.Adjustments), s.State.Data.SellCEOptionPosition.TradingSymbol)
+	}
+
+	leg, err = s.calculateLeg("CE", 21600, s.adjustmentLegName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.roll("CE", leg, -0.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPositions("the second roll", map[string]int{"NIFTY24JAN21600CE": -50, "NIFTY24JAN21400PE": -50})
+
+	err = s.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPositions("the stop", map[string]int{})
+	if !s.State.Exited {
+		t.Error("got the strangle not exited")
+	}
+}
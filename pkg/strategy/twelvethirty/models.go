@@ -5,10 +5,10 @@ import (
 )
 
 type TwelveThiryStrategyPositions struct {
-	SellPEOptionPoistion         models.Position
-	SellCEOptionPosition         models.Position
-	BuyPEOptionPoistion          models.Position
-	BuyCEOptionPosition          models.Position
-	SellPEStopLossOptionPosition models.Position
-	SellCEStopLossOptionPosition models.Position
+	SellPEOptionPoistion         models.Order
+	SellCEOptionPosition         models.Order
+	BuyPEOptionPoistion          models.Order
+	BuyCEOptionPosition          models.Order
+	SellPEStopLossOptionPosition models.Order
+	SellCEStopLossOptionPosition models.Order
 }
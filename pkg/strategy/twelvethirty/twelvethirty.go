@@ -7,18 +7,23 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/rohitsakala/strategies/pkg/account"
 	"github.com/rohitsakala/strategies/pkg/broker"
 	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/reconciler"
+	"github.com/rohitsakala/strategies/pkg/sizing"
+	"github.com/rohitsakala/strategies/pkg/strategy"
+	"github.com/rohitsakala/strategies/pkg/strategy/filter"
 	"github.com/rohitsakala/strategies/pkg/utils"
 	"github.com/rohitsakala/strategies/pkg/utils/duration"
 	"github.com/rohitsakala/strategies/pkg/utils/options"
 	"github.com/rohitsakala/strategies/pkg/watcher"
-	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
 const (
 	TwelveThirtyStrategyDatabaseName = "twelvethirty"
+	TwelveThirtyBasketTimeout        = 3 * time.Minute
 )
 
 type TwelveThirtyStrategy struct {
@@ -33,9 +38,97 @@ type TwelveThirtyStrategy struct {
 	Watcher         watcher.Watcher
 	ProductType     string
 	StopLossVariant string
+	// RunID identifies the run of the day in the order tags
+	RunID   string
+	Account account.Account
+	// Filters decide before the entry whether to skip it
+	// or to scale the lots by LotScale
+	Filters  filter.Chain
+	LotScale float64
+	Skipped  bool
+	Sizer    sizing.Sizer
+	// Lots are the lots of the legs, one till they are sized
+	Lots       int
+	Reconciler reconciler.Reconciler
+	// Clock waits for the entry and exit windows and Mail sends
+	// the updates, both are replaced in tests
+	Clock duration.Clock
+	Mail  func(to, subject, body string) error
 }
 
-func NewTwelveThirtyStrategy(broker broker.Broker, timeZone time.Location, watcher watcher.Watcher, productType, stopLossVariant string) (TwelveThirtyStrategy, error) {
+func init() {
+	strategy.Register(strategy.Definition{
+		Name:        TwelveThirtyStrategyDatabaseName,
+		Description: "Sells NIFTY ATM straddle at 12:30 pm with stop losses and exits at 15:20 pm",
+		Parameters: []strategy.Parameter{
+			{Name: "product", Type: strategy.StringParameter, Choices: []string{string(models.ProductMIS), string(models.ProductNRML)}, Description: "product type of the orders"},
+			{Name: "stoploss", Type: strategy.StringParameter, Default: "variable", Choices: []string{"fixed", "variable"}, Description: "fixed is a constant 30% stop loss"},
+			{Name: "maxvix", Type: strategy.FloatParameter, Default: "0", Description: "skips the entry above the India VIX, 0 is off"},
+			{Name: "resizevix", Type: strategy.FloatParameter, Default: "0", Description: "resizes the entry above the India VIX, 0 is off"},
+			{Name: "maxgap", Type: strategy.FloatParameter, Default: "0", Description: "skips the entry on a gap opening of more percent, 0 is off"},
+			{Name: "maxrange", Type: strategy.FloatParameter, Default: "0", Description: "skips the entry when the day moved more percent, 0 is off"},
+			{Name: "maxrangeatr", Type: strategy.FloatParameter, Default: "0", Description: "skips the entry when the day moved more times the 14 day ATR, 0 is off"},
+			{Name: "onevent", Type: strategy.StringParameter, Default: "trade", Choices: []string{"trade", "skip", "resize"}, Description: "entry on the days of EVENT_CALENDAR"},
+			{Name: "resizescale", Type: strategy.FloatParameter, Default: "0.5", Description: "scale of the lots of a resized entry"},
+		},
+		Validate: func(parameters strategy.Parameters) error {
+			if parameters.Float("resizescale") <= 0 || parameters.Float("resizescale") > 1 {
+				return fmt.Errorf("resizescale %f must be above 0 and at most 1", parameters.Float("resizescale"))
+			}
+			return nil
+		},
+		New: func(dependencies strategy.Dependencies, parameters strategy.Parameters) (strategy.Strategy, error) {
+			filters, err := newFilters(parameters)
+			if err != nil {
+				return nil, err
+			}
+			twelvethirtyStrategy, err := NewTwelveThirtyStrategy(dependencies.Account, dependencies.Broker, dependencies.TimeZone, dependencies.Watcher, dependencies.Sizer, parameters.String("product"), parameters.String("stoploss"), filters)
+			if err != nil {
+				return nil, err
+			}
+			return &twelvethirtyStrategy, nil
+		},
+	})
+}
+
+// newFilters makes the entry filters turned on by the parameters
+func newFilters(parameters strategy.Parameters) (filter.Chain, error) {
+	scale := parameters.Float("resizescale")
+	filters := filter.Chain{}
+	if parameters.Float("maxvix") > 0 || parameters.Float("resizevix") > 0 {
+		filters = append(filters, filter.VIXFilter{Max: parameters.Float("maxvix"), ResizeAbove: parameters.Float("resizevix"), Scale: scale})
+	}
+	if parameters.Float("maxgap") > 0 {
+		filters = append(filters, filter.GapFilter{MaxPercentage: parameters.Float("maxgap")})
+	}
+	if parameters.Float("maxrange") > 0 || parameters.Float("maxrangeatr") > 0 {
+		filters = append(filters, filter.RangeFilter{MaxPercentage: parameters.Float("maxrange"), MaxATRMultiple: parameters.Float("maxrangeatr"), ATRPeriod: 14})
+	}
+	if parameters.String("onevent") != "trade" {
+		path := os.Getenv("EVENT_CALENDAR")
+		if len(path) < 1 {
+			return nil, fmt.Errorf("onevent=%s needs the EVENT_CALENDAR file", parameters.String("onevent"))
+		}
+		calendar, err := filter.LoadEventCalendar(path)
+		if err != nil {
+			return nil, err
+		}
+		eventFilter := filter.EventFilter{Calendar: calendar}
+		if parameters.String("onevent") == "resize" {
+			eventFilter.Scale = scale
+		}
+		filters = append(filters, eventFilter)
+	}
+
+	return filters, nil
+}
+
+func NewTwelveThirtyStrategy(account account.Account, broker broker.Broker, timeZone time.Location, watcher watcher.Watcher, sizer sizing.Sizer, productType, stopLossVariant string, filters filter.Chain) (TwelveThirtyStrategy, error) {
+	positionsReconciler, err := reconciler.NewReconciler(account, broker, TwelveThirtyStrategyDatabaseName)
+	if err != nil {
+		return TwelveThirtyStrategy{}, err
+	}
+
 	return TwelveThirtyStrategy{
 		EntryStartTime:  time.Date(time.Now().In(&timeZone).Year(), time.Now().In(&timeZone).Month(), time.Now().In(&timeZone).Day(), 12, 25, 0, 0, &timeZone),
 		EntryEndTime:    time.Date(time.Now().In(&timeZone).Year(), time.Now().In(&timeZone).Month(), time.Now().In(&timeZone).Day(), 15, 20, 0, 0, &timeZone),
@@ -46,6 +139,15 @@ func NewTwelveThirtyStrategy(broker broker.Broker, timeZone time.Location, watch
 		Watcher:         watcher,
 		ProductType:     productType,
 		StopLossVariant: stopLossVariant,
+		RunID:           time.Now().In(&timeZone).Format("20060102"),
+		Account:         account,
+		Filters:         filters,
+		LotScale:        1,
+		Sizer:           sizer,
+		Lots:            1,
+		Reconciler:      positionsReconciler,
+		Clock:           duration.SystemClock{},
+		Mail:            utils.SendEmailTo,
 	}, nil
 }
 
@@ -59,85 +161,94 @@ func (t *TwelveThirtyStrategy) Start() error {
 		log.Println("Market is closed")
 		return nil
 	}
+	_, err = t.reconcile("startup", false)
+	if err != nil {
+		return err
+	}
 
 	log.Printf("Waiting for 12:25 pm to 15:20 pm....")
 	for {
-		if !duration.ValidateTime(t.EntryStartTime, t.EntryEndTime, t.TimeZone) {
-			time.Sleep(1 * time.Minute)
-			log.Printf("Time : %v", time.Now().In(&t.TimeZone))
+		if !duration.ValidateTimeAt(t.Clock.Now().In(&t.TimeZone), t.EntryStartTime, t.EntryEndTime) {
+			t.Clock.Sleep(1 * time.Minute)
+			log.Printf("Time : %v", t.Clock.Now().In(&t.TimeZone))
 		} else {
-			log.Printf("Time : %v", time.Now().In(&t.TimeZone))
+			log.Printf("Time : %v", t.Clock.Now().In(&t.TimeZone))
 			break
 		}
 	}
 	log.Printf("Entering 12:25 pm to 15:20 pm.")
 
-	strikePrice, err := options.GetATM("NIFTY 50", t.Broker)
+	decision, err := t.Filters.Evaluate(filter.Market{
+		Broker:     t.Broker,
+		Underlying: "NIFTY",
+		TimeZone:   t.TimeZone,
+		Now:        t.Clock.Now(),
+	})
 	if err != nil {
 		return err
 	}
-
-	t.Data.BuyCEOptionPosition, err = t.calculateLeg("CE", strikePrice+500, kiteconnect.TransactionTypeBuy)
-	if err != nil {
-		return err
-	}
-	log.Printf("Calculating Buy CE Leg.... %s %d", t.Data.BuyCEOptionPosition.TradingSymbol, t.Data.BuyCEOptionPosition.Quantity)
-	err = t.Broker.PlaceOrder(&t.Data.BuyCEOptionPosition)
-	if err != nil {
-		return err
+	if decision.Skip {
+		t.Skipped = true
+		message := fmt.Sprintf("Skipped the entry because %s", decision.Reason)
+		log.Println(message)
+		return t.sendEmail("Twelve Thirty PM Trade Update", message)
 	}
-	log.Printf("Placing Buy CE Leg with Avg Price %f", t.Data.BuyCEOptionPosition.AveragePrice)
-	err = utils.SendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed Buy CE Leg with Avg Price %f", t.Data.BuyCEOptionPosition.AveragePrice))
-	if err != nil {
-		return err
+	t.LotScale = decision.Scale
+	if t.LotScale != 1 {
+		log.Printf("Scaling the lots by %f because %s", t.LotScale, decision.Reason)
 	}
 
-	t.Data.BuyPEOptionPoistion, err = t.calculateLeg("PE", strikePrice-500, kiteconnect.TransactionTypeBuy)
+	indexSymbol, err := options.GetIndexSymbol("NIFTY", t.Broker)
 	if err != nil {
 		return err
 	}
-	log.Printf("Calculating Buy PE Leg.... %s %d", t.Data.BuyPEOptionPoistion.TradingSymbol, t.Data.BuyPEOptionPoistion.Quantity)
-	err = t.Broker.PlaceOrder(&t.Data.BuyPEOptionPoistion)
+	strikePrice, err := options.GetATM(indexSymbol, t.Broker)
 	if err != nil {
 		return err
 	}
-	log.Printf("Placing Buy PE Leg with Avg Price %f", t.Data.BuyPEOptionPoistion.AveragePrice)
-	err = utils.SendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed Buy PE Leg with Avg Price %f", t.Data.BuyPEOptionPoistion.AveragePrice))
+
+	t.Data.BuyCEOptionPosition, err = t.calculateLeg("CE", strikePrice+500, models.TransactionTypeBuy, "buyce")
 	if err != nil {
 		return err
 	}
-
-	t.Data.SellCEOptionPosition, err = t.calculateLeg("CE", strikePrice, kiteconnect.TransactionTypeSell)
+	t.Data.BuyPEOptionPoistion, err = t.calculateLeg("PE", strikePrice-500, models.TransactionTypeBuy, "buype")
 	if err != nil {
 		return err
 	}
-	log.Printf("Calculating CE Leg.... %s %d", t.Data.SellCEOptionPosition.TradingSymbol, t.Data.SellCEOptionPosition.Quantity)
-	err = t.Broker.PlaceOrder(&t.Data.SellCEOptionPosition)
+	t.Data.SellCEOptionPosition, err = t.calculateLeg("CE", strikePrice, models.TransactionTypeSell, "sellce")
 	if err != nil {
 		return err
 	}
-	log.Printf("Placing CE Leg with Avg Price %f", t.Data.SellCEOptionPosition.AveragePrice)
-	err = utils.SendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed CE Leg with Avg Price %f", t.Data.SellCEOptionPosition.AveragePrice))
+	t.Data.SellPEOptionPoistion, err = t.calculateLeg("PE", strikePrice, models.TransactionTypeSell, "sellpe")
 	if err != nil {
 		return err
 	}
-
-	t.Data.SellPEOptionPoistion, err = t.calculateLeg("PE", strikePrice, kiteconnect.TransactionTypeSell)
+	err = t.size(&t.Data.BuyCEOptionPosition, &t.Data.BuyPEOptionPoistion, &t.Data.SellCEOptionPosition, &t.Data.SellPEOptionPoistion)
 	if err != nil {
 		return err
 	}
+	log.Printf("Calculating Buy CE Leg.... %s %d", t.Data.BuyCEOptionPosition.TradingSymbol, t.Data.BuyCEOptionPosition.Quantity)
+	log.Printf("Calculating Buy PE Leg.... %s %d", t.Data.BuyPEOptionPoistion.TradingSymbol, t.Data.BuyPEOptionPoistion.Quantity)
+	log.Printf("Calculating CE Leg.... %s %d", t.Data.SellCEOptionPosition.TradingSymbol, t.Data.SellCEOptionPosition.Quantity)
 	log.Printf("Calculating PE Leg.... %s %d", t.Data.SellPEOptionPoistion.TradingSymbol, t.Data.SellPEOptionPoistion.Quantity)
-	err = t.Broker.PlaceOrder(&t.Data.SellPEOptionPoistion)
+
+	log.Printf("Placing basket of Buy CE, Buy PE, CE and PE Legs....")
+	basket := models.RefOrders{&t.Data.BuyCEOptionPosition, &t.Data.BuyPEOptionPoistion, &t.Data.SellCEOptionPosition, &t.Data.SellPEOptionPoistion}
+	err = t.Broker.PlaceBasketOrder(basket, TwelveThirtyBasketTimeout)
 	if err != nil {
 		return err
 	}
-	log.Printf("Placing PE Leg with Avg Price %f", t.Data.SellPEOptionPoistion.AveragePrice)
-	err = utils.SendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed PE Leg with Avg Price %f", t.Data.SellPEOptionPoistion.AveragePrice))
+	log.Printf("Placed Buy CE Leg with Avg Price %f", t.Data.BuyCEOptionPosition.AveragePrice)
+	log.Printf("Placed Buy PE Leg with Avg Price %f", t.Data.BuyPEOptionPoistion.AveragePrice)
+	log.Printf("Placed CE Leg with Avg Price %f", t.Data.SellCEOptionPosition.AveragePrice)
+	log.Printf("Placed PE Leg with Avg Price %f", t.Data.SellPEOptionPoistion.AveragePrice)
+	err = t.sendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed Buy CE Leg with Avg Price %f, Buy PE Leg with Avg Price %f, CE Leg with Avg Price %f and PE Leg with Avg Price %f",
+		t.Data.BuyCEOptionPosition.AveragePrice, t.Data.BuyPEOptionPoistion.AveragePrice, t.Data.SellCEOptionPosition.AveragePrice, t.Data.SellPEOptionPoistion.AveragePrice))
 	if err != nil {
 		return err
 	}
 
-	t.Data.SellCEStopLossOptionPosition, err = t.calculateStopLossLeg(t.Data.SellCEOptionPosition)
+	t.Data.SellCEStopLossOptionPosition, err = t.calculateStopLossLeg(t.Data.SellCEOptionPosition, "slce")
 	if err != nil {
 		return err
 	}
@@ -146,12 +257,12 @@ func (t *TwelveThirtyStrategy) Start() error {
 		return err
 	}
 	log.Printf("Placing CE StopLoss Leg with Trigger Price %f", t.Data.SellCEStopLossOptionPosition.TriggerPrice)
-	err = utils.SendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed CE Stop Loss Leg with Trigger Price %f", t.Data.SellCEStopLossOptionPosition.TriggerPrice))
+	err = t.sendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed CE Stop Loss Leg with Trigger Price %f", t.Data.SellCEStopLossOptionPosition.TriggerPrice))
 	if err != nil {
 		return err
 	}
 
-	t.Data.SellPEStopLossOptionPosition, err = t.calculateStopLossLeg(t.Data.SellPEOptionPoistion)
+	t.Data.SellPEStopLossOptionPosition, err = t.calculateStopLossLeg(t.Data.SellPEOptionPoistion, "slpe")
 	if err != nil {
 		return err
 	}
@@ -160,7 +271,7 @@ func (t *TwelveThirtyStrategy) Start() error {
 		return err
 	}
 	log.Printf("Placing PE StopLoss Leg with Trigger Price %f", t.Data.SellPEStopLossOptionPosition.TriggerPrice)
-	err = utils.SendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed PE Stop Loss Leg with Trigger Price %f", t.Data.SellPEStopLossOptionPosition.TriggerPrice))
+	err = t.sendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Placed PE Stop Loss Leg with Trigger Price %f", t.Data.SellPEStopLossOptionPosition.TriggerPrice))
 	if err != nil {
 		return err
 	}
@@ -183,35 +294,56 @@ func (t *TwelveThirtyStrategy) Stop() error {
 		log.Println("Market is closed")
 		return nil
 	}
+	if t.Skipped {
+		log.Println("No positions as the entry was skipped")
+		return nil
+	}
 	log.Printf("Cancelling all pending orders...")
-	stopLossLegs := models.RefPositions{&t.Data.SellCEStopLossOptionPosition, &t.Data.SellPEStopLossOptionPosition}
+	stopLossLegs := models.RefOrders{&t.Data.SellCEStopLossOptionPosition, &t.Data.SellPEStopLossOptionPosition}
+	t.Watcher.Remove(stopLossLegs...)
 	err = t.Broker.CancelOrders(stopLossLegs)
 	if err != nil {
 		return err
 	}
 	log.Printf("Cancelled all pending orders.")
-	err = utils.SendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Cancelled Stop Loss orders %s %s", t.Data.SellPEStopLossOptionPosition.TradingSymbol, t.Data.SellCEStopLossOptionPosition.TradingSymbol))
+	err = t.sendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Cancelled Stop Loss orders %s %s", t.Data.SellPEStopLossOptionPosition.TradingSymbol, t.Data.SellCEStopLossOptionPosition.TradingSymbol))
+	if err != nil {
+		return err
+	}
+
+	mismatches, err := t.reconcile("exit", true)
 	if err != nil {
 		return err
 	}
 
 	log.Printf("Exiting all current positions...")
-	positionList := models.Positions{}
-	if t.Data.SellCEStopLossOptionPosition.Status != kiteconnect.OrderStatusComplete {
+	positionList := models.Orders{}
+	if t.Data.SellCEStopLossOptionPosition.Status != models.StatusComplete {
 		positionList = append(positionList, t.Data.SellCEOptionPosition)
 	}
-	if t.Data.SellPEStopLossOptionPosition.Status != kiteconnect.OrderStatusComplete {
+	if t.Data.SellPEStopLossOptionPosition.Status != models.StatusComplete {
 		positionList = append(positionList, t.Data.SellPEOptionPoistion)
 	}
 	positionList = append(positionList, t.Data.BuyPEOptionPoistion)
 	positionList = append(positionList, t.Data.BuyCEOptionPosition)
+	// only what the broker still holds is squared off
+	heldList := models.Orders{}
+	for _, position := range positionList {
+		position.Quantity = mismatches.Held(position)
+		if position.Quantity <= 0 {
+			log.Printf("Not exiting %s as it isn't held anymore", position.TradingSymbol)
+			continue
+		}
+		heldList = append(heldList, position)
+	}
+	positionList = heldList
 	err = t.cancelPositions(positionList)
 	if err != nil {
 		return err
 	}
 	log.Printf("Exited all current positions.")
 	for _, position := range positionList {
-		err = utils.SendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Cancelled position %s", position.TradingSymbol))
+		err = t.sendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Cancelled position %s", position.TradingSymbol))
 		if err != nil {
 			return err
 		}
@@ -221,21 +353,25 @@ func (t *TwelveThirtyStrategy) Stop() error {
 }
 
 func (t *TwelveThirtyStrategy) WaitAndWatch() error {
+	t.Watcher.Add(t.stopLossHandlers(), &t.Data.SellCEStopLossOptionPosition, &t.Data.SellPEStopLossOptionPosition)
+
 	log.Printf("Waiting for 15:20 to 15:30 pm....")
 	for {
-		if !duration.ValidateTime(t.ExitStartTime, t.ExitEndTime, t.TimeZone) {
-			time.Sleep(1 * time.Minute)
-			err := t.Watcher.Watch(&t.Data.SellCEStopLossOptionPosition)
+		if !duration.ValidateTimeAt(t.Clock.Now().In(&t.TimeZone), t.ExitStartTime, t.ExitEndTime) {
+			t.Clock.Sleep(1 * time.Minute)
+			err := t.Watcher.Poll()
 			if err != nil {
 				return err
 			}
-			err = t.Watcher.Watch(&t.Data.SellPEStopLossOptionPosition)
-			if err != nil {
-				return err
+			if t.Reconciler.Due() {
+				_, err = t.reconcile("watch", true)
+				if err != nil {
+					return err
+				}
 			}
-			log.Printf("Time : %v", time.Now().In(&t.TimeZone))
+			log.Printf("Time : %v", t.Clock.Now().In(&t.TimeZone))
 		} else {
-			log.Printf("Time : %v", time.Now().In(&t.TimeZone))
+			log.Printf("Time : %v", t.Clock.Now().In(&t.TimeZone))
 			break
 		}
 	}
@@ -243,15 +379,63 @@ func (t *TwelveThirtyStrategy) WaitAndWatch() error {
 	return nil
 }
 
-func (t *TwelveThirtyStrategy) cancelPositions(positions models.Positions) error {
+// stopLossHandlers sends an email on every change of the stop loss
+// legs and completes the legs which stay open after getting triggered
+func (t *TwelveThirtyStrategy) stopLossHandlers() watcher.Handlers {
+	notify := func(event watcher.Event) error {
+		message := fmt.Sprintf("Order %s Changed from %s to %s", event.Order.TradingSymbol, event.PreviousStatus, event.Order.Status)
+		return t.sendEmail("12:30 pm Trade Update", message)
+	}
+
+	return watcher.Handlers{
+		watcher.EventTriggered: notify,
+		watcher.EventFilled:    notify,
+		watcher.EventRejected:  notify,
+		watcher.EventCancelled: notify,
+		watcher.EventStuckOpen: func(event watcher.Event) error {
+			event.Order.OrderType = models.OrderTypeLimit
+			err := t.Broker.PlaceOrder(event.Order)
+			if err != nil {
+				return err
+			}
+			message := fmt.Sprintf("Order %s Changed from OPEN to %s", event.Order.TradingSymbol, event.Order.Status)
+			log.Println(message)
+			return t.sendEmail("12:30 pm Trade Update", message)
+		},
+	}
+}
+
+// reconcile compares the legs with the positions of the broker
+// and emails the mismatches if there are any. Extra positions
+// are only squared off as configured once the legs are known.
+func (t *TwelveThirtyStrategy) reconcile(when string, known bool) (reconciler.Mismatches, error) {
+	holdings := reconciler.HoldingsOf(t.Data.BuyCEOptionPosition, t.Data.BuyPEOptionPoistion, t.Data.SellCEOptionPosition, t.Data.SellPEOptionPoistion,
+		t.Data.SellCEStopLossOptionPosition, t.Data.SellPEStopLossOptionPosition)
+	reconcile := t.Reconciler.Report
+	if known {
+		reconcile = t.Reconciler.Reconcile
+	}
+	mismatches, err := reconcile(holdings)
+	if err != nil {
+		return nil, err
+	}
+	if len(mismatches) <= 0 {
+		return mismatches, nil
+	}
+
+	return mismatches, t.sendEmail("Twelve Thirty PM Trade Update", fmt.Sprintf("Positions differ from the legs at %s\n%s", when, mismatches))
+}
+
+// sendEmail sends the update to the account
+func (t *TwelveThirtyStrategy) sendEmail(subject, body string) error {
+	return t.Mail(t.Account.Email, t.Account.Subject(subject), body)
+}
+
+func (t *TwelveThirtyStrategy) cancelPositions(positions models.Orders) error {
 	for _, position := range positions {
-		if position.TransactionType == kiteconnect.TransactionTypeBuy {
-			position.TransactionType = kiteconnect.TransactionTypeSell
-		} else if position.TransactionType == kiteconnect.TransactionTypeSell {
-			position.TransactionType = kiteconnect.TransactionTypeBuy
-		}
-		position.Status = ""
-		position.OrderID = ""
+		position = position.Unplaced()
+		position.TransactionType = position.TransactionType.Opposite()
+		position.Tag = broker.NewOrderTag(position.Tag, "exit")
 		err := t.Broker.PlaceOrder(&position)
 		if err != nil {
 			return err
@@ -261,51 +445,96 @@ func (t *TwelveThirtyStrategy) cancelPositions(positions models.Positions) error
 	return nil
 }
 
-func (t *TwelveThirtyStrategy) calculateLeg(optionType string, strikePrice float64, transactionType string) (models.Position, error) {
-	leg := models.Position{
-		Type:            optionType,
-		Exchange:        kiteconnect.ExchangeNFO,
+func (t *TwelveThirtyStrategy) calculateLeg(optionType string, strikePrice float64, transactionType models.TransactionType, legName string) (models.Order, error) {
+	leg := models.Order{
+		Instrument: models.Instrument{
+			Exchange:       models.ExchangeNFO,
+			InstrumentType: optionType,
+		},
+		Tag:             broker.NewOrderTag(TwelveThirtyStrategyDatabaseName, t.RunID, legName),
 		TransactionType: transactionType,
-		Product:         t.ProductType,
-		OrderType:       kiteconnect.OrderTypeLimit,
+		Product:         models.Product(t.ProductType),
+		OrderType:       models.OrderTypeLimit,
 	}
 
 	legSymbol, err := options.GetSymbol("NIFTY", options.WEEK, 0, strikePrice, optionType, t.Broker)
 	if err != nil {
-		return models.Position{}, err
+		return models.Order{}, err
 	}
 	leg.TradingSymbol = legSymbol
 
 	leg.LotSize, err = options.GetLotSize(legSymbol, t.Broker)
 	if err != nil {
-		return models.Position{}, err
+		return models.Order{}, err
 	}
 
-	lotQuantity, err := strconv.Atoi(os.Getenv("TWELVE_THIRTY_LOT_QUANTITY"))
-	if err != nil {
-		return models.Position{}, err
-	}
-	leg.Quantity = lotQuantity * leg.LotSize
+	leg.Quantity = t.Lots * leg.LotSize
 
 	leg.Expiry, err = options.GetExpiry("NIFTY", options.WEEK, 0, strikePrice, optionType, t.Broker)
 	if err != nil {
-		return models.Position{}, err
+		return models.Order{}, err
 	}
 
 	return leg, nil
 }
 
-func (t *TwelveThirtyStrategy) calculateStopLossLeg(leg models.Position) (models.Position, error) {
-	leg.TransactionType = kiteconnect.TransactionTypeBuy
-	leg.Product = t.ProductType
-	leg.OrderType = kiteconnect.OrderTypeSL
-	leg.OrderID = ""
-	leg.Status = ""
+// size consults the sizer for the lots of the legs. The
+// loss of a lot is both short legs getting stopped out.
+func (t *TwelveThirtyStrategy) size(legs ...*models.Order) error {
+	lotQuantity := 0
+	if value := os.Getenv("TWELVE_THIRTY_LOT_QUANTITY"); len(value) > 0 {
+		var err error
+		lotQuantity, err = strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+	}
+	lossPerLot := 0.0
+	for _, leg := range legs {
+		if leg.TransactionType != models.TransactionTypeSell {
+			continue
+		}
+		ltp, err := options.GetLTP(leg.TradingSymbol, t.Broker)
+		if err != nil {
+			return err
+		}
+		lossPerLot += ltp * float64(t.stopLossPercentage(leg.Expiry)) / 100 * float64(leg.LotSize)
+	}
+
+	lots, err := t.Sizer.SizeLegs(sizing.Trade{Lots: lotQuantity, LossPerLot: lossPerLot, Scale: t.LotScale}, legs...)
+	if err != nil {
+		return err
+	}
+	t.Lots = lots
+
+	return nil
+}
+
+// calculateStopLossLeg gives the stop loss of the filled leg
+// as a new order, without the fills of the leg
+func (t *TwelveThirtyStrategy) calculateStopLossLeg(leg models.Order, legName string) (models.Order, error) {
+	entryPrice := leg.AveragePrice
+	leg = leg.Unplaced()
+	leg.Tag = broker.NewOrderTag(TwelveThirtyStrategyDatabaseName, t.RunID, legName)
+	leg.TransactionType = models.TransactionTypeBuy
+	leg.Product = models.Product(t.ProductType)
+	leg.OrderType = models.OrderTypeSL
+
+	stopLossPercentage := t.stopLossPercentage(leg.Expiry)
+	stopLossPrice := entryPrice * float64(stopLossPercentage) / 100
+	stopLossPrice = stopLossPrice + entryPrice
+	leg.TriggerPrice = float64(int(stopLossPrice*10)) / 10
+	leg.Price = float64(int(leg.TriggerPrice) + 5)
+
+	return leg, nil
+}
 
+// stopLossPercentage gets wider closer to the expiry
+// unless the stop loss is fixed
+func (t *TwelveThirtyStrategy) stopLossPercentage(expiryDate time.Time) int {
 	stopLossPercentage := 30
 
-	expiryDate := leg.Expiry
-	now := time.Now().In(&t.TimeZone)
+	now := t.Clock.Now().In(&t.TimeZone)
 	diff := expiryDate.Sub(now)
 
 	if int(diff.Hours()) < 0 {
@@ -317,10 +546,6 @@ func (t *TwelveThirtyStrategy) calculateStopLossLeg(leg models.Position) (models
 	if t.StopLossVariant == "fixed" {
 		stopLossPercentage = 30
 	}
-	stopLossPrice := leg.AveragePrice * float64(stopLossPercentage) / 100
-	stopLossPrice = stopLossPrice + leg.AveragePrice
-	leg.TriggerPrice = float64(int(stopLossPrice*10)) / 10
-	leg.Price = float64(int(leg.TriggerPrice) + 5)
 
-	return leg, nil
+	return stopLossPercentage
 }
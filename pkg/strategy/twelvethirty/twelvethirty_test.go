@@ -0,0 +1,217 @@
+package twelvethirty
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/account"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/sizing"
+	"github.com/rohitsakala/strategies/pkg/strategy/filter"
+	"github.com/rohitsakala/strategies/pkg/watcher"
+)
+
+// fakeClock moves on by the time slept
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+type testLeg struct {
+	tradingSymbol string
+	strike        float64
+	optionType    string
+}
+
+var (
+	buyCE  = testLeg{"NIFTY24JAN22000CE", 22000, "CE"}
+	buyPE  = testLeg{"NIFTY24JAN21000PE", 21000, "PE"}
+	sellCE = testLeg{"NIFTY24JAN21500CE", 21500, "CE"}
+	sellPE = testLeg{"NIFTY24JAN21500PE", 21500, "PE"}
+)
+
+func newTestStrategy(t *testing.T) (*TwelveThirtyStrategy, *broker.MockBroker, *fakeClock) {
+	timeZone := *time.UTC
+	now := time.Now().In(&timeZone)
+	expiry := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, &timeZone).AddDate(0, 0, 7)
+
+	err := os.Setenv("TWELVE_THIRTY_LOT_QUANTITY", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Unsetenv("TWELVE_THIRTY_LOT_QUANTITY") })
+
+	mockBroker, err := broker.NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockBroker.SetLTP("NIFTY 50", 21520)
+	for _, leg := range []testLeg{buyCE, buyPE, sellCE, sellPE} {
+		mockBroker.Instruments = append(mockBroker.Instruments, models.Instrument{
+			TradingSymbol:  leg.tradingSymbol,
+			Exchange:       models.ExchangeNFO,
+			Name:           "NIFTY",
+			InstrumentType: leg.optionType,
+			StrikePrice:    leg.strike,
+			Expiry:         expiry,
+			LotSize:        50,
+		})
+	}
+	for _, leg := range []testLeg{buyCE, buyPE} {
+		mockBroker.Script(leg.tradingSymbol, broker.MockStep{Status: models.StatusComplete, AveragePrice: 20})
+	}
+	for _, leg := range []testLeg{sellCE, sellPE} {
+		mockBroker.SetLTP(leg.tradingSymbol, 100)
+	}
+
+	testAccount, err := account.NewAccount("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	positionsWatcher, err := watcher.NewWatcher(&mockBroker, timeZone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizer := sizing.Sizer{Config: sizing.DefaultSizingConfig(), Broker: &mockBroker, LotMultiplier: 1}
+	strategy, err := NewTwelveThirtyStrategy(testAccount, &mockBroker, timeZone, positionsWatcher, sizer, string(models.ProductMIS), "fixed", filter.Chain{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, legName := range []string{"sellce", "sellpe"} {
+		mockBroker.Script(broker.NewOrderTag(TwelveThirtyStrategyDatabaseName, strategy.RunID, legName), broker.MockStep{Status: models.StatusComplete, AveragePrice: 100})
+	}
+	clock := &fakeClock{now: time.Date(now.Year(), now.Month(), now.Day(), 12, 0, 0, 0, &timeZone)}
+	strategy.Clock = clock
+	strategy.Mail = func(to, subject, body string) error {
+		return nil
+	}
+
+	return &strategy, &mockBroker, clock
+}
+
+// placedOf gives the orders placed on the trading symbol
+func placedOf(orders models.Orders, tradingSymbol string) models.Orders {
+	result := models.Orders{}
+	for _, order := range orders {
+		if order.TradingSymbol == tradingSymbol {
+			result = append(result, order)
+		}
+	}
+
+	return result
+}
+
+func TestTwelveThirtyPlacesLegsAndStopLosses(t *testing.T) {
+	strategy, mockBroker, clock := newTestStrategy(t)
+	// the CE stop loss gets triggered but stays open
+	mockBroker.Script(broker.NewOrderTag(TwelveThirtyStrategyDatabaseName, strategy.RunID, "slce"),
+		broker.MockStep{Status: models.StatusTriggerPending},
+		broker.MockStep{Status: models.StatusOpen},
+	)
+
+	err := strategy.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clock.now.Before(strategy.ExitStartTime) {
+		t.Errorf("Start returned at %s, before the exit at %s", clock.now, strategy.ExitStartTime)
+	}
+
+	placed := mockBroker.PlacedOrders()
+	firstShort := len(placed)
+	for _, order := range placed {
+		if order.TransactionType == models.TransactionTypeSell {
+			number, err := strconv.Atoi(order.OrderID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if number < firstShort {
+				firstShort = number
+			}
+		}
+	}
+	for _, leg := range []testLeg{buyCE, buyPE} {
+		orders := placedOf(placed, leg.tradingSymbol)
+		if len(orders) != 1 || orders[0].TransactionType != models.TransactionTypeBuy || orders[0].Quantity != 100 {
+			t.Fatalf("got orders %v of %s, want a buy of 100", orders, leg.tradingSymbol)
+		}
+		number, err := strconv.Atoi(orders[0].OrderID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if number > firstShort {
+			t.Errorf("hedge %s was placed after a short", leg.tradingSymbol)
+		}
+	}
+	for _, leg := range []testLeg{sellCE, sellPE} {
+		orders := placedOf(placed, leg.tradingSymbol)
+		if len(orders) != 2 {
+			t.Fatalf("got %d orders of %s, want the short and its stop loss", len(orders), leg.tradingSymbol)
+		}
+		short, stopLoss := orders[0], orders[1]
+		if short.TransactionType != models.TransactionTypeSell || short.Quantity != 100 {
+			t.Errorf("got short %s %d of %s, want SELL 100", short.TransactionType, short.Quantity, leg.tradingSymbol)
+		}
+		if stopLoss.TransactionType != models.TransactionTypeBuy || stopLoss.Quantity != 100 || stopLoss.TriggerPrice != 130 || stopLoss.Price != 135 {
+			t.Errorf("got stop loss %s %d of %s triggering at %f, want BUY 100 triggering at 130", stopLoss.TransactionType, stopLoss.Quantity, leg.tradingSymbol, stopLoss.TriggerPrice)
+		}
+	}
+
+	// the stuck CE stop loss is converted to a limit order in place
+	ceStopLoss := strategy.Data.SellCEStopLossOptionPosition
+	if ceStopLoss.OrderType != models.OrderTypeLimit || ceStopLoss.Status != models.StatusComplete {
+		t.Errorf("got CE stop loss %s %s, want a COMPLETE LIMIT order", ceStopLoss.OrderType, ceStopLoss.Status)
+	}
+	if len(mockBroker.ModifiedOrders()) != 1 {
+		t.Errorf("got %d modified orders, want the CE stop loss modified once", len(mockBroker.ModifiedOrders()))
+	}
+	peStopLoss := strategy.Data.SellPEStopLossOptionPosition
+	if peStopLoss.Status != models.StatusTriggerPending || peStopLoss.FilledQuantity != 0 || peStopLoss.AveragePrice != 0 {
+		t.Errorf("got PE stop loss %s with %d filled at %f, want %s without fills", peStopLoss.Status, peStopLoss.FilledQuantity, peStopLoss.AveragePrice, models.StatusTriggerPending)
+	}
+}
+
+func TestTwelveThirtyExitsHeldLegs(t *testing.T) {
+	strategy, mockBroker, _ := newTestStrategy(t)
+	mockBroker.Script(broker.NewOrderTag(TwelveThirtyStrategyDatabaseName, strategy.RunID, "slce"),
+		broker.MockStep{Status: models.StatusTriggerPending},
+		broker.MockStep{Status: models.StatusComplete, AveragePrice: 130},
+	)
+
+	err := strategy.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = strategy.Stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancelled := mockBroker.CancelledOrders()
+	if len(cancelled) != 1 || cancelled[0].TradingSymbol != sellPE.tradingSymbol {
+		t.Errorf("got cancelled orders %v, want the PE stop loss", cancelled)
+	}
+	positions, err := mockBroker.GetPositions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, position := range positions {
+		if position.Quantity != 0 {
+			t.Errorf("got %d of %s after the exit, want 0", position.Quantity, position.TradingSymbol)
+		}
+	}
+	// the stopped out CE short is not bought back again
+	if orders := placedOf(mockBroker.PlacedOrders(), sellCE.tradingSymbol); len(orders) != 2 {
+		t.Errorf("got %d orders of %s, want the short and its stop loss", len(orders), sellCE.tradingSymbol)
+	}
+}
@@ -0,0 +1,14 @@
+package symbol
+
+func GetMapper(name string) Mapper {
+	switch name {
+	case "zerodha":
+		kiteMapper := NewKiteMapper()
+		return &kiteMapper
+	case "fyer":
+		fyerMapper := NewFyerMapper()
+		return &fyerMapper
+	}
+
+	return nil
+}
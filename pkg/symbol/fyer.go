@@ -0,0 +1,79 @@
+package symbol
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// FyerIndices are the symbols of the indices on fyers
+// without the exchange
+var FyerIndices = map[string]string{
+	"NIFTY":      "NIFTY50-INDEX",
+	"BANKNIFTY":  "NIFTYBANK-INDEX",
+	"FINNIFTY":   "FINNIFTY-INDEX",
+	"MIDCPNIFTY": "MIDCPNIFTY-INDEX",
+	"SENSEX":     "SENSEX-INDEX",
+	"BANKEX":     "BANKEX-INDEX",
+	"INDIAVIX":   "INDIAVIX-INDEX",
+}
+
+type FyerMapper struct{}
+
+func NewFyerMapper() FyerMapper {
+	return FyerMapper{}
+}
+
+// Format gives the fyers symbol like NSE:NIFTY50-INDEX,
+// NSE:RELIANCE-EQ or NSE:NIFTY24JAN21500CE. Fyers lists
+// the derivatives under the exchange of the underlying.
+func (f *FyerMapper) Format(s Symbol) (string, error) {
+	exchange := fyerExchange(s)
+	switch {
+	case s.IsDerivative():
+		tradingSymbol, err := formatDerivative(s)
+		if err != nil {
+			return "", err
+		}
+		return exchange + ":" + tradingSymbol, nil
+	case s.InstrumentType == InstrumentTypeIndex:
+		index, ok := FyerIndices[s.Underlying]
+		if !ok {
+			return "", fmt.Errorf("index %s is not known on fyers", s.Underlying)
+		}
+		return exchange + ":" + index, nil
+	case s.InstrumentType == InstrumentTypeEquity:
+		return exchange + ":" + s.Underlying + "-EQ", nil
+	}
+
+	return "", fmt.Errorf("instrument type %s of %s is not supported on fyers", s.InstrumentType, s.Underlying)
+}
+
+func (f *FyerMapper) Parse(tradingSymbol string) (Symbol, error) {
+	parts := strings.SplitN(strings.TrimSpace(tradingSymbol), ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Symbol{}, fmt.Errorf("%s is not a fyers symbol", tradingSymbol)
+	}
+	name := parts[1]
+
+	for underlying, index := range FyerIndices {
+		if index == name {
+			return NewIndex(underlying), nil
+		}
+	}
+	if strings.HasSuffix(name, "-EQ") {
+		return NewEquity(strings.TrimSuffix(name, "-EQ")), nil
+	}
+
+	return parseDerivative(name)
+}
+
+func fyerExchange(s Symbol) string {
+	switch s.Exchange() {
+	case models.ExchangeBSE, models.ExchangeBFO:
+		return string(models.ExchangeBSE)
+	}
+
+	return string(models.ExchangeNSE)
+}
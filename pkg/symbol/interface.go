@@ -0,0 +1,8 @@
+package symbol
+
+// Mapper converts symbols to and from the trading symbols
+// of a broker
+type Mapper interface {
+	Format(symbol Symbol) (string, error)
+	Parse(tradingSymbol string) (Symbol, error)
+}
@@ -0,0 +1,64 @@
+package symbol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// KiteIndices are the trading symbols of the indices on kite
+var KiteIndices = map[string]string{
+	"NIFTY":      "NIFTY 50",
+	"BANKNIFTY":  "NIFTY BANK",
+	"FINNIFTY":   "NIFTY FIN SERVICE",
+	"MIDCPNIFTY": "NIFTY MID SELECT",
+	"SENSEX":     "SENSEX",
+	"BANKEX":     "BANKEX",
+	"INDIAVIX":   "INDIA VIX",
+}
+
+type KiteMapper struct{}
+
+func NewKiteMapper() KiteMapper {
+	return KiteMapper{}
+}
+
+// Format gives the kite trading symbol like NIFTY 50, RELIANCE
+// or NIFTY24JAN21500CE
+func (k *KiteMapper) Format(s Symbol) (string, error) {
+	switch {
+	case s.IsDerivative():
+		return formatDerivative(s)
+	case s.InstrumentType == InstrumentTypeIndex:
+		index, ok := KiteIndices[s.Underlying]
+		if !ok {
+			return "", fmt.Errorf("index %s is not known on kite", s.Underlying)
+		}
+		return index, nil
+	case s.InstrumentType == InstrumentTypeEquity:
+		return s.Underlying, nil
+	}
+
+	return "", fmt.Errorf("instrument type %s of %s is not supported on kite", s.InstrumentType, s.Underlying)
+}
+
+// Parse reads a kite trading symbol. Symbols which are neither
+// indices nor derivatives are taken as stocks.
+func (k *KiteMapper) Parse(tradingSymbol string) (Symbol, error) {
+	tradingSymbol = strings.TrimSpace(tradingSymbol)
+	if i := strings.Index(tradingSymbol, ":"); i >= 0 {
+		tradingSymbol = tradingSymbol[i+1:]
+	}
+	for underlying, index := range KiteIndices {
+		if index == tradingSymbol {
+			return NewIndex(underlying), nil
+		}
+	}
+	if s, err := parseDerivative(tradingSymbol); err == nil {
+		return s, nil
+	}
+	if tradingSymbol == "" || strings.ContainsAny(tradingSymbol, " ") {
+		return Symbol{}, fmt.Errorf("%s is not a kite trading symbol", tradingSymbol)
+	}
+
+	return NewEquity(tradingSymbol), nil
+}
@@ -0,0 +1,203 @@
+package symbol
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+const (
+	InstrumentTypeIndex  = "INDEX"
+	InstrumentTypeEquity = "EQ"
+	InstrumentTypeFuture = "FUT"
+	InstrumentTypeCall   = "CE"
+	InstrumentTypePut    = "PE"
+)
+
+// weeklyMonths are the month codes of weekly expiry symbols
+var weeklyMonths = []string{"", "1", "2", "3", "4", "5", "6", "7", "8", "9", "O", "N", "D"}
+
+// derivativePattern matches NIFTY24JAN21500CE, NIFTY2412521500CE
+// and NIFTY24JANFUT
+var derivativePattern = regexp.MustCompile(`^([A-Z&-]+?)(\d{2})([A-Z]{3}|[1-9OND]\d{2})(\d+(?:\.\d+)?)?(CE|PE|FUT)$`)
+
+// Symbol is the broker independent identity of an instrument
+type Symbol struct {
+	Underlying     string
+	InstrumentType string
+	Expiry         time.Time
+	Strike         float64
+	// Monthly tells whether the expiry is the monthly one, which
+	// is written with the month name instead of the expiry date.
+	// Monthly symbols parsed from a broker only carry the month
+	// of the expiry.
+	Monthly bool
+}
+
+// NewIndex gives the symbol of an index like NIFTY or BANKNIFTY
+func NewIndex(underlying string) Symbol {
+	return Symbol{
+		Underlying:     underlying,
+		InstrumentType: InstrumentTypeIndex,
+	}
+}
+
+// NewEquity gives the symbol of a stock
+func NewEquity(underlying string) Symbol {
+	return Symbol{
+		Underlying:     underlying,
+		InstrumentType: InstrumentTypeEquity,
+	}
+}
+
+// NewFuture gives the symbol of a future of the underlying
+func NewFuture(underlying string, expiry time.Time) Symbol {
+	return Symbol{
+		Underlying:     underlying,
+		InstrumentType: InstrumentTypeFuture,
+		Expiry:         expiry,
+		Monthly:        true,
+	}
+}
+
+// NewOption gives the symbol of a CE or PE option of the underlying
+func NewOption(underlying string, expiry time.Time, strike float64, optionType string, monthly bool) Symbol {
+	return Symbol{
+		Underlying:     underlying,
+		InstrumentType: optionType,
+		Expiry:         expiry,
+		Strike:         strike,
+		Monthly:        monthly,
+	}
+}
+
+// FromInstrument gives the symbol of an instrument of a broker
+func FromInstrument(instrument models.Instrument, monthly bool) Symbol {
+	return Symbol{
+		Underlying:     instrument.Name,
+		InstrumentType: instrument.InstrumentType,
+		Expiry:         instrument.Expiry,
+		Strike:         instrument.StrikePrice,
+		Monthly:        monthly,
+	}
+}
+
+func (s Symbol) IsDerivative() bool {
+	return s.InstrumentType == InstrumentTypeFuture || s.IsOption()
+}
+
+func (s Symbol) IsOption() bool {
+	return s.InstrumentType == InstrumentTypeCall || s.InstrumentType == InstrumentTypePut
+}
+
+// Exchange gives the exchange the underlying is listed on
+func (s Symbol) Exchange() models.Exchange {
+	if s.Underlying == "SENSEX" || s.Underlying == "BANKEX" {
+		if s.IsDerivative() {
+			return models.ExchangeBFO
+		}
+		return models.ExchangeBSE
+	}
+	if s.IsDerivative() {
+		return models.ExchangeNFO
+	}
+
+	return models.ExchangeNSE
+}
+
+func (s Symbol) String() string {
+	switch {
+	case s.IsOption():
+		return fmt.Sprintf("%s %s %s %s", s.Underlying, s.Expiry.Format("2006-01-02"), formatStrike(s.Strike), s.InstrumentType)
+	case s.IsDerivative():
+		return fmt.Sprintf("%s %s %s", s.Underlying, s.Expiry.Format("2006-01-02"), s.InstrumentType)
+	}
+
+	return s.Underlying
+}
+
+// formatDerivative writes the symbol in the NSE format shared by
+// the brokers like NIFTY24JAN21500CE for monthly, NIFTY2412521500CE
+// for weekly options and NIFTY24JANFUT for futures
+func formatDerivative(s Symbol) (string, error) {
+	if s.Underlying == "" || s.Expiry.IsZero() {
+		return "", fmt.Errorf("symbol %s is missing the underlying or expiry", s)
+	}
+
+	var builder strings.Builder
+	builder.WriteString(s.Underlying)
+	builder.WriteString(s.Expiry.Format("06"))
+	if s.Monthly {
+		builder.WriteString(strings.ToUpper(s.Expiry.Format("Jan")))
+	} else {
+		builder.WriteString(weeklyMonths[s.Expiry.Month()])
+		builder.WriteString(s.Expiry.Format("02"))
+	}
+	if s.IsOption() {
+		if s.Strike <= 0 {
+			return "", fmt.Errorf("option %s is missing the strike", s)
+		}
+		builder.WriteString(formatStrike(s.Strike))
+	}
+	builder.WriteString(s.InstrumentType)
+
+	return builder.String(), nil
+}
+
+// parseDerivative reads a symbol written in the NSE format
+func parseDerivative(tradingSymbol string) (Symbol, error) {
+	match := derivativePattern.FindStringSubmatch(tradingSymbol)
+	if match == nil {
+		return Symbol{}, fmt.Errorf("%s is not a derivative symbol", tradingSymbol)
+	}
+
+	s := Symbol{
+		Underlying:     match[1],
+		InstrumentType: match[5],
+	}
+	year, err := strconv.Atoi(match[2])
+	if err != nil {
+		return Symbol{}, err
+	}
+	if !strings.ContainsAny(match[3], "0123456789") {
+		month, err := time.Parse("Jan", match[3][:1]+strings.ToLower(match[3][1:]))
+		if err != nil {
+			return Symbol{}, fmt.Errorf("%s has an invalid expiry month", tradingSymbol)
+		}
+		s.Expiry = time.Date(2000+year, month.Month(), 1, 0, 0, 0, 0, time.UTC)
+		s.Monthly = true
+	} else {
+		month := 0
+		for i, code := range weeklyMonths {
+			if code != "" && code == match[3][:1] {
+				month = i
+			}
+		}
+		day, err := strconv.Atoi(match[3][1:])
+		if err != nil || month == 0 || day < 1 || day > 31 {
+			return Symbol{}, fmt.Errorf("%s has an invalid expiry date", tradingSymbol)
+		}
+		s.Expiry = time.Date(2000+year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	}
+	if s.IsOption() {
+		if match[4] == "" {
+			return Symbol{}, fmt.Errorf("%s is missing the strike", tradingSymbol)
+		}
+		s.Strike, err = strconv.ParseFloat(match[4], 64)
+		if err != nil {
+			return Symbol{}, err
+		}
+	} else if match[4] != "" {
+		return Symbol{}, fmt.Errorf("%s is not a derivative symbol", tradingSymbol)
+	}
+
+	return s, nil
+}
+
+func formatStrike(strike float64) string {
+	return strconv.FormatFloat(strike, 'f', -1, 64)
+}
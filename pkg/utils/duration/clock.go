@@ -0,0 +1,21 @@
+package duration
+
+import "time"
+
+// Clock tells the time and waits, so that the strategies
+// can be run through a day without the wall clock in tests
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+}
+
+// SystemClock is the wall clock
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (SystemClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
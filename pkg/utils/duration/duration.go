@@ -1,6 +1,9 @@
 package duration
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 func GetNextMonthOffset(value string, offset int) string {
 	var result string
@@ -44,8 +47,11 @@ func GetNext(value string) string {
 }
 
 func ValidateTime(start time.Time, end time.Time, timeZone time.Location) bool {
-	now := time.Now().In(&timeZone)
+	return ValidateTimeAt(time.Now().In(&timeZone), start, end)
+}
 
+// ValidateTimeAt tells whether now is between the start and end
+func ValidateTimeAt(now time.Time, start time.Time, end time.Time) bool {
 	if (start != time.Time{}) && (end != time.Time{}) {
 		// compare hours
 		if end.Sub(now).Hours() >= 0 {
@@ -64,3 +70,15 @@ func ValidateTime(start time.Time, end time.Time, timeZone time.Location) bool {
 
 	return false
 }
+
+// TodayAt gives the time of the clock, e.g. 12:25, on the
+// current day of the time zone
+func TodayAt(clock string, timeZone time.Location) (time.Time, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %s, expected HH:MM", clock)
+	}
+	now := time.Now().In(&timeZone)
+
+	return time.Date(now.Year(), now.Month(), now.Day(), parsed.Hour(), parsed.Minute(), 0, 0, &timeZone), nil
+}
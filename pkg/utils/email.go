@@ -8,10 +8,16 @@ import (
 )
 
 func SendEmail(subject, body string) error {
+	return SendEmailTo(os.Getenv("EMAIL_ADDRESS"), subject, body)
+}
+
+// SendEmailTo sends the email to the given address
+// instead of EMAIL_ADDRESS
+func SendEmailTo(to, subject, body string) error {
 	m := gomail.NewMessage()
 
 	m.SetHeader("From", os.Getenv("SENDER_EMAIL_ADDRESS"))
-	m.SetHeader("To", os.Getenv("EMAIL_ADDRESS"))
+	m.SetHeader("To", to)
 	m.SetHeader("Subject", subject)
 	m.SetBody("text/plain", body)
 
@@ -0,0 +1,139 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// ATR is the average true range of the candles
+// smoothed the way Wilder does
+type ATR struct {
+	Period    int
+	count     int
+	lastClose float64
+	value     float64
+}
+
+func NewATR(period int) (ATR, error) {
+	err := checkPeriod(period)
+	if err != nil {
+		return ATR{}, err
+	}
+
+	return ATR{
+		Period: period,
+	}, nil
+}
+
+// TrueRange is the range of the candle stretched
+// to the close before it
+func TrueRange(candle models.Candle, lastClose float64) float64 {
+	return math.Max(candle.High-candle.Low, math.Max(math.Abs(candle.High-lastClose), math.Abs(candle.Low-lastClose)))
+}
+
+func (a *ATR) Update(candle models.Candle) {
+	trueRange := candle.High - candle.Low
+	if a.count > 0 {
+		trueRange = TrueRange(candle, a.lastClose)
+	}
+	a.count++
+	a.lastClose = candle.Close
+
+	period := float64(a.Period)
+	if a.count <= a.Period {
+		// the first average is a simple one
+		a.value += trueRange / period
+		return
+	}
+	a.value = (a.value*(period-1) + trueRange) / period
+}
+
+func (a *ATR) Value() float64 {
+	return a.value
+}
+
+func (a *ATR) Ready() bool {
+	return a.count >= a.Period
+}
+
+// SuperTrend trails the price by a multiple of the ATR
+// and flips side when the close crosses it
+type SuperTrend struct {
+	Multiplier float64
+	atr        ATR
+	upper      float64
+	lower      float64
+	lastClose  float64
+	up         bool
+	ready      bool
+}
+
+func NewSuperTrend(period int, multiplier float64) (SuperTrend, error) {
+	if multiplier <= 0 {
+		return SuperTrend{}, fmt.Errorf("multiplier %f of the super trend is not positive", multiplier)
+	}
+	atr, err := NewATR(period)
+	if err != nil {
+		return SuperTrend{}, err
+	}
+
+	return SuperTrend{
+		Multiplier: multiplier,
+		atr:        atr,
+	}, nil
+}
+
+func (s *SuperTrend) Update(candle models.Candle) {
+	s.atr.Update(candle)
+	if !s.atr.Ready() {
+		s.lastClose = candle.Close
+		return
+	}
+
+	middle := (candle.High + candle.Low) / 2
+	upper := middle + s.Multiplier*s.atr.Value()
+	lower := middle - s.Multiplier*s.atr.Value()
+	if !s.ready {
+		s.upper, s.lower = upper, lower
+		s.up = candle.Close >= middle
+		s.lastClose = candle.Close
+		s.ready = true
+		return
+	}
+
+	// the bands only move towards the price unless
+	// the close before has crossed them
+	if upper < s.upper || s.lastClose > s.upper {
+		s.upper = upper
+	}
+	if lower > s.lower || s.lastClose < s.lower {
+		s.lower = lower
+	}
+	if s.up && candle.Close < s.lower {
+		s.up = false
+	} else if !s.up && candle.Close > s.upper {
+		s.up = true
+	}
+	s.lastClose = candle.Close
+}
+
+// Value is the lower band in an up trend
+// and the upper band in a down trend
+func (s *SuperTrend) Value() float64 {
+	if s.up {
+		return s.lower
+	}
+
+	return s.upper
+}
+
+func (s *SuperTrend) Ready() bool {
+	return s.ready
+}
+
+// Up tells whether the trend is up
+func (s *SuperTrend) Up() bool {
+	return s.up
+}
@@ -0,0 +1,62 @@
+package indicators
+
+import (
+	"fmt"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// Bollinger are the bands a multiple of the standard
+// deviation of the closes around their simple average
+type Bollinger struct {
+	Period     int
+	Multiplier float64
+	window     window
+}
+
+func NewBollinger(period int, multiplier float64) (Bollinger, error) {
+	err := checkPeriod(period)
+	if err != nil {
+		return Bollinger{}, err
+	}
+	if multiplier <= 0 {
+		return Bollinger{}, fmt.Errorf("multiplier %f of the bollinger bands is not positive", multiplier)
+	}
+
+	return Bollinger{
+		Period:     period,
+		Multiplier: multiplier,
+		window:     newWindow(period),
+	}, nil
+}
+
+func (b *Bollinger) Update(candle models.Candle) {
+	b.window.add(candle.Close)
+}
+
+// Value is the middle band
+func (b *Bollinger) Value() float64 {
+	return b.window.mean()
+}
+
+func (b *Bollinger) Ready() bool {
+	return b.window.full
+}
+
+func (b *Bollinger) Upper() float64 {
+	return b.window.mean() + b.Multiplier*b.window.deviation()
+}
+
+func (b *Bollinger) Lower() float64 {
+	return b.window.mean() - b.Multiplier*b.window.deviation()
+}
+
+// Width is the distance between the bands
+// relative to the middle band
+func (b *Bollinger) Width() float64 {
+	if b.window.mean() == 0 {
+		return 0
+	}
+
+	return (b.Upper() - b.Lower()) / b.window.mean()
+}
@@ -0,0 +1,90 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// Indicator is updated with the candles as they close, one after
+// another, and gives its value once it has seen enough of them
+type Indicator interface {
+	Update(candle models.Candle)
+	Value() float64
+	Ready() bool
+}
+
+// Calculate runs the indicator over the candles in batch and gives
+// its value after every candle, NaN while it isn't ready yet
+func Calculate(indicator Indicator, candles models.Candles) []float64 {
+	values := make([]float64, len(candles))
+	for i, candle := range candles {
+		indicator.Update(candle)
+		values[i] = math.NaN()
+		if indicator.Ready() {
+			values[i] = indicator.Value()
+		}
+	}
+
+	return values
+}
+
+// Last runs the indicator over the candles and gives
+// its value after the last one
+func Last(indicator Indicator, candles models.Candles) (float64, error) {
+	for _, candle := range candles {
+		indicator.Update(candle)
+	}
+	if !indicator.Ready() {
+		return 0, fmt.Errorf("%d candles are too few for the indicator", len(candles))
+	}
+
+	return indicator.Value(), nil
+}
+
+func checkPeriod(period int) error {
+	if period < 1 {
+		return fmt.Errorf("period %d of the indicator is not positive", period)
+	}
+
+	return nil
+}
+
+// window keeps the last period values along with their sum
+type window struct {
+	values []float64
+	next   int
+	full   bool
+	sum    float64
+}
+
+func newWindow(period int) window {
+	return window{
+		values: make([]float64, period),
+	}
+}
+
+func (w *window) add(value float64) {
+	w.sum += value - w.values[w.next]
+	w.values[w.next] = value
+	w.next = (w.next + 1) % len(w.values)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+func (w *window) mean() float64 {
+	return w.sum / float64(len(w.values))
+}
+
+// deviation is the population standard deviation of the window
+func (w *window) deviation() float64 {
+	mean := w.mean()
+	variance := 0.0
+	for _, value := range w.values {
+		variance += (value - mean) * (value - mean)
+	}
+
+	return math.Sqrt(variance / float64(len(w.values)))
+}
@@ -0,0 +1,312 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// rsiCloses are the closes of the 14 day RSI example
+// commonly worked through in the literature
+var rsiCloses = []float64{
+	44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+	45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64,
+	46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.44, 44.18, 44.22,
+	44.57, 43.42, 42.66, 43.13,
+}
+
+// atrCandles are the high, low and close of the 14 day
+// ATR example commonly worked through in the literature
+var atrCandles = [][3]float64{
+	{48.70, 47.79, 48.16}, {48.72, 48.14, 48.61}, {48.90, 48.39, 48.75}, {48.87, 48.37, 48.63},
+	{48.82, 48.24, 48.74}, {49.05, 48.64, 49.03}, {49.20, 48.94, 49.07}, {49.35, 48.86, 49.32},
+	{49.92, 49.50, 49.91}, {50.19, 49.87, 50.13}, {50.12, 49.20, 49.53}, {49.66, 48.90, 49.50},
+	{49.88, 49.43, 49.75}, {50.19, 49.73, 50.03}, {50.36, 49.26, 50.31}, {50.57, 50.09, 50.52},
+	{50.65, 50.30, 50.41}, {50.43, 49.21, 49.34}, {49.63, 48.98, 49.37}, {50.33, 49.61, 50.23},
+	{50.29, 49.20, 49.24}, {50.17, 49.43, 49.93}, {49.32, 48.08, 48.43}, {48.50, 47.64, 48.18},
+	{48.32, 41.55, 46.57}, {46.80, 44.28, 45.41}, {47.80, 47.31, 47.77}, {48.39, 47.20, 47.72},
+	{48.66, 47.90, 48.62}, {48.79, 47.73, 47.85},
+}
+
+func closeCandles(closes []float64) models.Candles {
+	candles := models.Candles{}
+	start := time.Date(2024, 1, 1, 3, 45, 0, 0, time.UTC)
+	for i, close := range closes {
+		candles = append(candles, models.Candle{
+			Time:   start.AddDate(0, 0, i),
+			Open:   close,
+			High:   close,
+			Low:    close,
+			Close:  close,
+			Volume: 1000,
+		})
+	}
+
+	return candles
+}
+
+func rangeCandles(ranges [][3]float64) models.Candles {
+	candles := models.Candles{}
+	start := time.Date(2024, 1, 1, 3, 45, 0, 0, time.UTC)
+	for i, hlc := range ranges {
+		candles = append(candles, models.Candle{
+			Time:   start.AddDate(0, 0, i),
+			Open:   hlc[2],
+			High:   hlc[0],
+			Low:    hlc[1],
+			Close:  hlc[2],
+			Volume: 1000 + 100*i,
+		})
+	}
+
+	return candles
+}
+
+// checkValues compares the values from the first ready
+// candle on with the expected ones within the tolerance
+func checkValues(t *testing.T, name string, values []float64, first int, expected []float64, tolerance float64) {
+	t.Helper()
+	for i := 0; i < first; i++ {
+		if !math.IsNaN(values[i]) {
+			t.Errorf("got %s %f at candle %d, want it not ready", name, values[i], i)
+		}
+	}
+	for i, want := range expected {
+		if got := values[first+i]; math.IsNaN(got) || math.Abs(got-want) > tolerance {
+			t.Errorf("got %s %f at candle %d, want %f", name, got, first+i, want)
+		}
+	}
+}
+
+func TestRSI(t *testing.T) {
+	rsi, err := NewRSI(14)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := Calculate(&rsi, closeCandles(rsiCloses))
+	checkValues(t, "RSI", values, 14, []float64{
+		70.46, 66.25, 66.48, 69.35, 66.29, 57.92, 62.88, 63.21, 56.01, 62.34,
+		54.67, 50.39, 40.02, 43.88, 42.03, 42.44, 45.96, 37.77, 33.52, 38.16,
+	}, 0.01)
+}
+
+func TestRSIFlat(t *testing.T) {
+	tests := []struct {
+		name   string
+		closes []float64
+		want   float64
+	}{
+		{"unchanged", []float64{10, 10, 10, 10}, 50},
+		{"only gains", []float64{10, 11, 12, 13}, 100},
+		{"only losses", []float64{13, 12, 11, 10}, 0},
+	}
+	for _, test := range tests {
+		rsi, err := NewRSI(3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Last(&rsi, closeCandles(test.closes))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("got RSI %f when %s, want %f", got, test.name, test.want)
+		}
+	}
+}
+
+func TestATR(t *testing.T) {
+	atr, err := NewATR(14)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := Calculate(&atr, rangeCandles(atrCandles))
+	checkValues(t, "ATR", values, 13, []float64{
+		0.55, 0.59, 0.59, 0.57, 0.61, 0.62, 0.64, 0.67, 0.69,
+		0.77, 0.78, 1.21, 1.30, 1.38, 1.37, 1.34, 1.32,
+	}, 0.005)
+}
+
+func TestSuperTrend(t *testing.T) {
+	superTrend, err := NewSuperTrend(14, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candles := rangeCandles(atrCandles)
+	ups := []bool{}
+	values := []float64{}
+	for _, candle := range candles {
+		superTrend.Update(candle)
+		if superTrend.Ready() {
+			ups = append(ups, superTrend.Up())
+			values = append(values, superTrend.Value())
+		}
+	}
+
+	// the lower band only rises in the up trend till the close
+	// falls through it, then the upper band only falls till the
+	// close rises through it
+	wantUps := []bool{
+		true, true, true, true, true, true, true, true, true,
+		false, false, false, false, false, false, true, true,
+	}
+	wantValues := []float64{
+		48.2971, 48.2971, 48.5745, 48.7699, 48.7699, 48.7699, 48.7699, 48.7699, 48.7699,
+		51.0247, 50.4129, 48.5613, 48.5613, 48.5613, 48.5613, 44.2718, 44.3110,
+	}
+	if len(values) != len(wantValues) {
+		t.Fatalf("got %d super trend values, want %d", len(values), len(wantValues))
+	}
+	for i := range wantValues {
+		if ups[i] != wantUps[i] || math.Abs(values[i]-wantValues[i]) > 0.0001 {
+			t.Errorf("got super trend up %t at %f at candle %d, want up %t at %f", ups[i], values[i], 13+i, wantUps[i], wantValues[i])
+		}
+	}
+}
+
+func TestBollinger(t *testing.T) {
+	bollinger, err := NewBollinger(20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candles := rangeCandles(atrCandles)
+	tests := []struct {
+		middle float64
+		upper  float64
+		lower  float64
+	}{
+		{49.4670, 50.8019, 48.1321},
+		{49.5210, 50.7206, 48.3214},
+		{49.5870, 50.7224, 48.4516},
+		{49.5710, 50.7608, 48.3812},
+		{49.5485, 50.8227, 48.2743},
+		{49.4400, 51.2344, 47.6456},
+		{49.2590, 51.7697, 46.7483},
+		{49.1940, 51.7869, 46.6011},
+		{49.1140, 51.7840, 46.4440},
+		{49.0495, 51.7017, 46.3973},
+		{48.9355, 51.5881, 46.2829},
+	}
+	for i, candle := range candles {
+		bollinger.Update(candle)
+		if i < 19 {
+			if bollinger.Ready() {
+				t.Errorf("got the bollinger bands ready at candle %d, want them after 20", i)
+			}
+			continue
+		}
+		want := tests[i-19]
+		if math.Abs(bollinger.Value()-want.middle) > 0.0001 || math.Abs(bollinger.Upper()-want.upper) > 0.0001 || math.Abs(bollinger.Lower()-want.lower) > 0.0001 {
+			t.Errorf("got bollinger bands %f %f %f at candle %d, want %f %f %f", bollinger.Lower(), bollinger.Value(), bollinger.Upper(), i, want.lower, want.middle, want.upper)
+		}
+	}
+}
+
+func TestVWAP(t *testing.T) {
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	candles := models.Candles{
+		{Time: time.Date(2024, 1, 1, 9, 15, 0, 0, ist), High: 10, Low: 8, Close: 9, Volume: 100},
+		{Time: time.Date(2024, 1, 1, 9, 16, 0, 0, ist), High: 12, Low: 10, Close: 11, Volume: 300},
+		// the next trading day starts at midnight in India,
+		// which is still the day before in UTC
+		{Time: time.Date(2024, 1, 1, 19, 0, 0, 0, time.UTC), High: 21, Low: 19, Close: 20, Volume: 50},
+		{Time: time.Date(2024, 1, 2, 9, 16, 0, 0, ist), High: 32, Low: 28, Close: 30, Volume: 150},
+	}
+
+	vwap, err := NewVWAP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := Calculate(&vwap, candles)
+	checkValues(t, "VWAP", values, 0, []float64{9, 10.5, 20, 27.5}, 1e-9)
+
+	index, err := NewVWAP()
+	if err != nil {
+		t.Fatal(err)
+	}
+	index.Update(models.Candle{Time: candles[0].Time, High: 10, Low: 8, Close: 9})
+	if index.Ready() {
+		t.Error("got the VWAP of candles without volume ready")
+	}
+}
+
+// TestUpdateMatchesCalculate checks that updating an indicator
+// candle by candle gives the values of running it in batch over
+// the candles so far, as the strategies do either
+func TestUpdateMatchesCalculate(t *testing.T) {
+	candles := rangeCandles(atrCandles)
+	tests := map[string]func() (Indicator, error){
+		"SMA": func() (Indicator, error) {
+			sma, err := NewSMA(5)
+			return &sma, err
+		},
+		"EMA": func() (Indicator, error) {
+			ema, err := NewEMA(5)
+			return &ema, err
+		},
+		"RSI": func() (Indicator, error) {
+			rsi, err := NewRSI(14)
+			return &rsi, err
+		},
+		"ATR": func() (Indicator, error) {
+			atr, err := NewATR(14)
+			return &atr, err
+		},
+		"SuperTrend": func() (Indicator, error) {
+			superTrend, err := NewSuperTrend(10, 3)
+			return &superTrend, err
+		},
+		"Bollinger": func() (Indicator, error) {
+			bollinger, err := NewBollinger(20, 2)
+			return &bollinger, err
+		},
+		"VWAP": func() (Indicator, error) {
+			vwap, err := NewVWAP()
+			return &vwap, err
+		},
+	}
+	for name, newIndicator := range tests {
+		t.Run(name, func(t *testing.T) {
+			batch, err := newIndicator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			values := Calculate(batch, candles)
+
+			streaming, err := newIndicator()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, candle := range candles {
+				streaming.Update(candle)
+				if streaming.Ready() != !math.IsNaN(values[i]) {
+					t.Fatalf("got ready %t at candle %d, want %t", streaming.Ready(), i, !math.IsNaN(values[i]))
+				}
+
+				prefix, err := newIndicator()
+				if err != nil {
+					t.Fatal(err)
+				}
+				last, err := Last(prefix, candles[:i+1])
+				if !streaming.Ready() {
+					if err == nil {
+						t.Errorf("got %f from the first %d candles, want too few candles", last, i+1)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				if streaming.Value() != values[i] || last != values[i] {
+					t.Errorf("got %f streaming and %f from the first %d candles, want %f", streaming.Value(), last, i+1, values[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package indicators
+
+import "github.com/rohitsakala/strategies/pkg/models"
+
+// SMA is the simple moving average of the closes
+type SMA struct {
+	Period int
+	window window
+}
+
+func NewSMA(period int) (SMA, error) {
+	err := checkPeriod(period)
+	if err != nil {
+		return SMA{}, err
+	}
+
+	return SMA{
+		Period: period,
+		window: newWindow(period),
+	}, nil
+}
+
+func (s *SMA) Update(candle models.Candle) {
+	s.window.add(candle.Close)
+}
+
+func (s *SMA) Value() float64 {
+	return s.window.mean()
+}
+
+func (s *SMA) Ready() bool {
+	return s.window.full
+}
+
+// EMA is the exponential moving average of the closes
+// seeded with the simple average of the first period
+type EMA struct {
+	Period int
+	seed   SMA
+	value  float64
+	ready  bool
+}
+
+func NewEMA(period int) (EMA, error) {
+	seed, err := NewSMA(period)
+	if err != nil {
+		return EMA{}, err
+	}
+
+	return EMA{
+		Period: period,
+		seed:   seed,
+	}, nil
+}
+
+func (e *EMA) Update(candle models.Candle) {
+	if !e.ready {
+		e.seed.Update(candle)
+		if e.seed.Ready() {
+			e.value = e.seed.Value()
+			e.ready = true
+		}
+		return
+	}
+	k := 2 / float64(e.Period+1)
+	e.value += k * (candle.Close - e.value)
+}
+
+func (e *EMA) Value() float64 {
+	return e.value
+}
+
+func (e *EMA) Ready() bool {
+	return e.ready
+}
@@ -0,0 +1,65 @@
+package indicators
+
+import (
+	"math"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// RSI is the relative strength index of the closes
+// with the averages smoothed the way Wilder does
+type RSI struct {
+	Period    int
+	count     int
+	lastClose float64
+	avgGain   float64
+	avgLoss   float64
+}
+
+func NewRSI(period int) (RSI, error) {
+	err := checkPeriod(period)
+	if err != nil {
+		return RSI{}, err
+	}
+
+	return RSI{
+		Period: period,
+	}, nil
+}
+
+func (r *RSI) Update(candle models.Candle) {
+	r.count++
+	if r.count == 1 {
+		r.lastClose = candle.Close
+		return
+	}
+	change := candle.Close - r.lastClose
+	r.lastClose = candle.Close
+	gain := math.Max(change, 0)
+	loss := math.Max(-change, 0)
+
+	period := float64(r.Period)
+	if r.count <= r.Period+1 {
+		// the first averages are simple ones
+		r.avgGain += gain / period
+		r.avgLoss += loss / period
+		return
+	}
+	r.avgGain = (r.avgGain*(period-1) + gain) / period
+	r.avgLoss = (r.avgLoss*(period-1) + loss) / period
+}
+
+func (r *RSI) Value() float64 {
+	if r.avgLoss == 0 {
+		if r.avgGain == 0 {
+			return 50
+		}
+		return 100
+	}
+
+	return 100 - 100/(1+r.avgGain/r.avgLoss)
+}
+
+func (r *RSI) Ready() bool {
+	return r.count > r.Period
+}
@@ -0,0 +1,48 @@
+package indicators
+
+import (
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+// sessionTimeZone is where the trading day of the VWAP starts
+var sessionTimeZone = time.FixedZone("IST", 5*60*60+30*60)
+
+// VWAP is the volume weighted average of the typical price
+// since the start of the trading day of the last candle
+type VWAP struct {
+	day         string
+	priceVolume float64
+	volume      float64
+}
+
+func NewVWAP() (VWAP, error) {
+	return VWAP{}, nil
+}
+
+func (v *VWAP) Update(candle models.Candle) {
+	day := candle.Time.In(sessionTimeZone).Format("2006-01-02")
+	if day != v.day {
+		v.day = day
+		v.priceVolume = 0
+		v.volume = 0
+	}
+	typical := (candle.High + candle.Low + candle.Close) / 3
+	v.priceVolume += typical * float64(candle.Volume)
+	v.volume += float64(candle.Volume)
+}
+
+func (v *VWAP) Value() float64 {
+	if v.volume <= 0 {
+		return 0
+	}
+
+	return v.priceVolume / v.volume
+}
+
+// Ready tells whether the day has traded volume, the
+// candles of an index don't have any
+func (v *VWAP) Ready() bool {
+	return v.volume > 0
+}
@@ -0,0 +1,57 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/avast/retry-go"
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/symbol"
+)
+
+// GetFutureSymbol gives the trading symbol of the future of the
+// underlying, the current month one for offset 0, the next month
+// one for 1 and so on
+func GetFutureSymbol(underlying string, expiryOffset int, broker broker.Broker) (string, error) {
+	var futures models.Instruments
+
+	err := retry.Do(
+		func() error {
+			instruments, err := broker.GetInstruments(models.ExchangeNFO)
+			if err != nil {
+				return err
+			}
+
+			futures = models.Instruments{}
+			for _, instrument := range instruments {
+				future := symbol.FromInstrument(instrument, true)
+				if future.Underlying == underlying && future.InstrumentType == symbol.InstrumentTypeFuture {
+					futures = append(futures, instrument)
+				}
+			}
+			if len(futures) <= 0 {
+				return errors.New("futures are empty")
+			}
+
+			return nil
+		},
+		retry.OnRetry(func(n uint, err error) {
+			log.Println(fmt.Sprintf("%s %s because %s", "Retrying getting futures of", underlying, err))
+		}),
+		retry.Delay(5*time.Second),
+		retry.Attempts(5),
+	)
+	if err != nil {
+		return "", err
+	}
+	sort.Sort(InstrumentSorter(futures))
+	if expiryOffset < 0 || expiryOffset >= len(futures) {
+		return "", fmt.Errorf("no future of %s at expiry offset %d", underlying, expiryOffset)
+	}
+
+	return broker.GetSymbolMapper().Format(symbol.FromInstrument(futures[expiryOffset], true))
+}
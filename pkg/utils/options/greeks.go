@@ -0,0 +1,182 @@
+package options
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+var (
+	// RiskFreeRate is the yearly rate used to price the options
+	RiskFreeRate = 0.07
+
+	// expiryTimeZone is where the options expire at 15:30
+	expiryTimeZone = time.FixedZone("IST", 5*60*60+30*60)
+)
+
+const (
+	minVolatility = 0.001
+	maxVolatility = 5.0
+	// minTimeToExpiry keeps the greeks finite in the
+	// last minutes of the expiry day
+	minTimeToExpiry = 5.0 / (365 * 24 * 60)
+)
+
+// Greeks of an option for one unit of the underlying. Theta is
+// per calendar day and Vega per percent of volatility.
+type Greeks struct {
+	ImpliedVolatility float64
+	Delta             float64
+	Gamma             float64
+	Theta             float64
+	Vega              float64
+}
+
+// GetGreeks gives the greeks of the option instrument from its LTP
+// and the spot of the underlying. The instrument needs its strike,
+// expiry and instrument type.
+func GetGreeks(instrument models.Instrument, spot float64, broker broker.Broker) (Greeks, error) {
+	price, err := GetLTP(instrument.TradingSymbol, broker)
+	if err != nil {
+		return Greeks{}, err
+	}
+
+	return NewGreeks(instrument.InstrumentType, spot, instrument.StrikePrice, price, TimeToExpiry(instrument.Expiry, time.Now()))
+}
+
+// NewGreeks gives the greeks at the volatility implied by the price
+func NewGreeks(optionType string, spot, strike, price, timeToExpiry float64) (Greeks, error) {
+	volatility, err := ImpliedVolatility(optionType, spot, strike, price, timeToExpiry)
+	if err != nil {
+		return Greeks{}, err
+	}
+
+	return BlackScholesGreeks(optionType, spot, strike, timeToExpiry, volatility)
+}
+
+// TimeToExpiry gives the years left till 15:30 of the expiry day
+func TimeToExpiry(expiry time.Time, now time.Time) float64 {
+	expiresAt := time.Date(expiry.Year(), expiry.Month(), expiry.Day(), 15, 30, 0, 0, expiryTimeZone)
+	years := expiresAt.Sub(now).Hours() / (365 * 24)
+
+	return math.Max(years, minTimeToExpiry)
+}
+
+// BlackScholesPrice gives the price of an european option
+func BlackScholesPrice(optionType string, spot, strike, timeToExpiry, volatility float64) (float64, error) {
+	d1, d2 := blackScholesD(spot, strike, timeToExpiry, volatility)
+	discount := math.Exp(-RiskFreeRate * timeToExpiry)
+	switch optionType {
+	case "CE":
+		return spot*normalCDF(d1) - strike*discount*normalCDF(d2), nil
+	case "PE":
+		return strike*discount*normalCDF(-d2) - spot*normalCDF(-d1), nil
+	}
+
+	return 0, fmt.Errorf("unknown option type %s", optionType)
+}
+
+// BlackScholesGreeks gives the greeks of an european option
+func BlackScholesGreeks(optionType string, spot, strike, timeToExpiry, volatility float64) (Greeks, error) {
+	if spot <= 0 || strike <= 0 || timeToExpiry <= 0 || volatility <= 0 {
+		return Greeks{}, errors.New("spot, strike, time to expiry and volatility must be positive")
+	}
+	d1, d2 := blackScholesD(spot, strike, timeToExpiry, volatility)
+	discount := math.Exp(-RiskFreeRate * timeToExpiry)
+	sqrtTime := math.Sqrt(timeToExpiry)
+
+	greeks := Greeks{
+		ImpliedVolatility: volatility,
+		Gamma:             normalPDF(d1) / (spot * volatility * sqrtTime),
+		Vega:              spot * normalPDF(d1) * sqrtTime / 100,
+	}
+	decay := -spot * normalPDF(d1) * volatility / (2 * sqrtTime)
+	switch optionType {
+	case "CE":
+		greeks.Delta = normalCDF(d1)
+		greeks.Theta = (decay - RiskFreeRate*strike*discount*normalCDF(d2)) / 365
+	case "PE":
+		greeks.Delta = normalCDF(d1) - 1
+		greeks.Theta = (decay + RiskFreeRate*strike*discount*normalCDF(-d2)) / 365
+	default:
+		return Greeks{}, fmt.Errorf("unknown option type %s", optionType)
+	}
+
+	return greeks, nil
+}
+
+// ImpliedVolatility finds the volatility giving the price by bisection,
+// which always converges as the price grows with the volatility
+func ImpliedVolatility(optionType string, spot, strike, price, timeToExpiry float64) (float64, error) {
+	if spot <= 0 || strike <= 0 || price <= 0 || timeToExpiry <= 0 {
+		return 0, fmt.Errorf("can't imply volatility of price %f at spot %f and strike %f", price, spot, strike)
+	}
+	low, high := minVolatility, maxVolatility
+	lowPrice, err := BlackScholesPrice(optionType, spot, strike, timeToExpiry, low)
+	if err != nil {
+		return 0, err
+	}
+	if price <= lowPrice {
+		// below the time value the model allows, e.g. a
+		// stale LTP of a deep option, so the least one
+		return low, nil
+	}
+	highPrice, _ := BlackScholesPrice(optionType, spot, strike, timeToExpiry, high)
+	if price >= highPrice {
+		return 0, fmt.Errorf("price %f of %s %f is above any volatility", price, optionType, strike)
+	}
+
+	for i := 0; i < 100 && high-low > 1e-6; i++ {
+		middle := (low + high) / 2
+		middlePrice, _ := BlackScholesPrice(optionType, spot, strike, timeToExpiry, middle)
+		if middlePrice < price {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+
+	return (low + high) / 2, nil
+}
+
+func blackScholesD(spot, strike, timeToExpiry, volatility float64) (float64, float64) {
+	volatilityTime := volatility * math.Sqrt(timeToExpiry)
+	d1 := (math.Log(spot/strike) + (RiskFreeRate+volatility*volatility/2)*timeToExpiry) / volatilityTime
+
+	return d1, d1 - volatilityTime
+}
+
+func normalCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+func normalPDF(x float64) float64 {
+	return math.Exp(-x*x/2) / math.Sqrt(2*math.Pi)
+}
+
+// StrikeForDelta estimates the strike whose absolute delta is the
+// given one at the volatility, e.g. of the ATM option. As the
+// volatility differs across the strikes the strike is only a
+// starting point.
+func StrikeForDelta(optionType string, spot, delta, timeToExpiry, volatility float64) (float64, error) {
+	if delta <= 0 || delta >= 1 {
+		return 0, fmt.Errorf("delta %f is not between 0 and 1", delta)
+	}
+	callDelta := delta
+	switch optionType {
+	case "CE":
+	case "PE":
+		// put delta is the call delta minus one
+		callDelta = 1 - delta
+	default:
+		return 0, fmt.Errorf("unknown option type %s", optionType)
+	}
+	d1 := math.Sqrt2 * math.Erfinv(2*callDelta-1)
+	volatilityTime := volatility * math.Sqrt(timeToExpiry)
+
+	return spot * math.Exp(-(d1*volatilityTime - (RiskFreeRate+volatility*volatility/2)*timeToExpiry)), nil
+}
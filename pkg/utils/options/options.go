@@ -5,12 +5,12 @@ import (
 	"fmt"
 	"log"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/avast/retry-go"
 	"github.com/rohitsakala/strategies/pkg/broker"
 	"github.com/rohitsakala/strategies/pkg/models"
+	"github.com/rohitsakala/strategies/pkg/symbol"
 	"github.com/rohitsakala/strategies/pkg/utils/maths"
 )
 
@@ -19,84 +19,112 @@ const (
 	MONTH = "month"
 )
 
-type PositionSorter []models.Position
+type InstrumentSorter []models.Instrument
 
-func (s PositionSorter) Len() int      { return len(s) }
-func (s PositionSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
-func (s PositionSorter) Less(i, j int) bool {
+func (s InstrumentSorter) Len() int      { return len(s) }
+func (s InstrumentSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s InstrumentSorter) Less(i, j int) bool {
 	return s[i].Expiry.Before(s[j].Expiry)
 }
 
 // GetSymbol will construct the symbol of the
-// option according to the parameters given
-func GetSymbol(symbol, expiryType string, expiryOffset int, strikePrice float64, optionType string, broker broker.Broker) (string, error) {
-	var instruments models.Positions
-	var filteredInstruments models.Positions
-	var err error
+// option according to the parameters given. The
+// expiry offset of a weekly option counts the
+// expiries of the underlying, 0 being the nearest.
+func GetSymbol(underlying, expiryType string, expiryOffset int, strikePrice float64, optionType string, broker broker.Broker) (string, error) {
+	instruments, expiries, err := getOptionInstruments(underlying, strikePrice, optionType, broker)
+	if err != nil {
+		return "", err
+	}
 
-	err = retry.Do(
-		func() error {
-			instruments, err = broker.GetInstruments("NFO")
-			if err != nil {
-				return err
-			}
-			if len(instruments) <= 0 {
-				return errors.New("instruments is empty")
-			}
+	switch expiryType {
+	case MONTH:
+		i := 0
+		for i+1 < len(instruments) && instruments[i+1].Expiry.Month() == instruments[0].Expiry.Month() {
+			i++
+		}
+		return tradingSymbolOf(instruments[i], true, broker)
+	case WEEK:
+		i, err := weeklyIndex(instruments, expiries, expiryOffset)
+		if err != nil {
+			return "", fmt.Errorf("no symbol of %s %v %s because %s", underlying, strikePrice, optionType, err)
+		}
+		return tradingSymbolOf(instruments[i], isMonthlyExpiry(expiries, instruments[i].Expiry), broker)
+	}
 
-			filteredInstruments = models.Positions{}
-			for _, instrument := range instruments {
-				if strings.HasPrefix(instrument.TradingSymbol, symbol) && instrument.Segment == "NFO-OPT" && instrument.StrikePrice == strikePrice && instrument.Exchange == "NFO" && instrument.InstrumentType == optionType {
-					filteredInstruments = append(filteredInstruments, instrument)
-				}
-			}
-			sort.Sort(PositionSorter(filteredInstruments))
+	return "", nil
+}
 
-			if len(filteredInstruments) <= 0 {
-				return errors.New("filtered instruments is empty")
-			}
+// tradingSymbolOf gives the trading symbol of the option instrument on
+// the broker. An instrument listed by the broker already carries it, so
+// it is only formatted from the symbol when the broker can't parse it.
+// The monthly flag is a guess from the strikes listed and is wrong for
+// strikes only listed for the current week.
+func tradingSymbolOf(instrument models.Instrument, monthly bool, broker broker.Broker) (string, error) {
+	mapper := broker.GetSymbolMapper()
+	if len(instrument.TradingSymbol) > 0 {
+		if option, err := mapper.Parse(instrument.TradingSymbol); err == nil && option.IsOption() {
+			return instrument.TradingSymbol, nil
+		}
+	}
 
-			return nil
-		},
-		retry.OnRetry(func(n uint, err error) {
-			log.Println(fmt.Sprintf("%s because %s", "Retrying getting instruments from NFO", err))
-		}),
-		retry.Delay(5*time.Second),
-		retry.Attempts(5),
-	)
+	return mapper.Format(symbol.FromInstrument(instrument, monthly))
+}
+
+// GetIndexSymbol gives the trading symbol of the index
+// like NIFTY or BANKNIFTY on the broker
+func GetIndexSymbol(underlying string, broker broker.Broker) (string, error) {
+	return broker.GetSymbolMapper().Format(symbol.NewIndex(underlying))
+}
+
+// GetExpiry will return expiry date according to
+// the parameters passed in the function
+func GetExpiry(underlying, expiryType string, expiryOffset int, strikePrice float64, optionType string, broker broker.Broker) (time.Time, error) {
+	filteredInstruments, expiries, err := getOptionInstruments(underlying, strikePrice, optionType, broker)
 	if err != nil {
-		return "", err
+		return time.Time{}, err
 	}
 
 	switch expiryType {
 	case MONTH:
-		resultSymbol := filteredInstruments[0].TradingSymbol
+		expiry := filteredInstruments[0].Expiry
 		month := filteredInstruments[0].Expiry.Month()
 		for i := 1; i < len(filteredInstruments); i++ {
 			if filteredInstruments[i].Expiry.Month() != month {
-				return symbol, nil
+				if expiryOffset == 0 {
+					return expiry, nil
+				} else {
+					expiry = filteredInstruments[i].Expiry
+					month = filteredInstruments[i].Expiry.Month()
+					expiryOffset--
+				}
 			}
-			resultSymbol = filteredInstruments[i].TradingSymbol
 		}
 
-		return resultSymbol, nil
+		return expiry, nil
 	case WEEK:
-		return filteredInstruments[0].TradingSymbol, nil
+		i, err := weeklyIndex(filteredInstruments, expiries, expiryOffset)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("no expiry of %s %v %s because %s", underlying, strikePrice, optionType, err)
+		}
+		return filteredInstruments[i].Expiry, nil
 	}
 
-	return "", nil
+	return time.Time{}, nil
 }
 
-// GetExpiry will return expiry date according to
-// the parameters passed in the function
-func GetExpiry(symbol, expiryType string, expiryOffset int, strikePrice float64, optionType string, broker broker.Broker) (time.Time, error) {
-	var instruments models.Positions
-	var filteredInstruments models.Positions
+// getOptionInstruments gives the options of the underlying at the
+// strike price sorted by their expiry, along with the sorted expiries
+// of all the options of the underlying and option type
+func getOptionInstruments(underlying string, strikePrice float64, optionType string, broker broker.Broker) (models.Instruments, []time.Time, error) {
+	var instruments models.Instruments
+	var filteredInstruments models.Instruments
+	var expiries []time.Time
 	var err error
 
 	err = retry.Do(
 		func() error {
-			instruments, err = broker.GetInstruments("NFO")
+			instruments, err = broker.GetInstruments(models.ExchangeNFO)
 			if err != nil {
 				return err
 			}
@@ -104,13 +132,22 @@ func GetExpiry(symbol, expiryType string, expiryOffset int, strikePrice float64,
 				return errors.New("instruments is empty")
 			}
 
-			filteredInstruments = models.Positions{}
+			filteredInstruments = models.Instruments{}
+			expiries = []time.Time{}
 			for _, instrument := range instruments {
-				if strings.HasPrefix(instrument.TradingSymbol, symbol) && instrument.Segment == "NFO-OPT" && instrument.StrikePrice == strikePrice && instrument.Exchange == "NFO" && instrument.InstrumentType == optionType {
+				option := symbol.FromInstrument(instrument, false)
+				if option.Underlying != underlying || !option.IsOption() || option.InstrumentType != optionType {
+					continue
+				}
+				expiries = appendExpiry(expiries, instrument.Expiry)
+				if option.Strike == strikePrice {
 					filteredInstruments = append(filteredInstruments, instrument)
 				}
 			}
-			sort.Sort(PositionSorter(filteredInstruments))
+			sort.Sort(InstrumentSorter(filteredInstruments))
+			sort.Slice(expiries, func(i, j int) bool {
+				return expiries[i].Before(expiries[j])
+			})
 
 			if len(filteredInstruments) <= 0 {
 				return errors.New("filtered instruments is empty")
@@ -125,41 +162,59 @@ func GetExpiry(symbol, expiryType string, expiryOffset int, strikePrice float64,
 		retry.Attempts(5),
 	)
 	if err != nil {
-		return time.Time{}, err
+		return nil, nil, err
 	}
 
-	switch expiryType {
-	case MONTH:
-		expiry := filteredInstruments[0].Expiry
-		month := filteredInstruments[0].Expiry.Month()
-		for i := 1; i < len(filteredInstruments); i++ {
-			if filteredInstruments[i].Expiry.Month() != month {
-				if expiryOffset == 0 {
-					return expiry, nil
-				} else {
-					expiry = filteredInstruments[i].Expiry
-					month = filteredInstruments[i].Expiry.Month()
-					expiryOffset--
-				}
-			}
+	return filteredInstruments, expiries, nil
+}
+
+// appendExpiry adds the expiry unless it is already there
+func appendExpiry(expiries []time.Time, expiry time.Time) []time.Time {
+	for _, existing := range expiries {
+		if existing.Equal(expiry) {
+			return expiries
 		}
+	}
 
-		return expiry, nil
-	case WEEK:
-		return filteredInstruments[0].Expiry, nil
+	return append(expiries, expiry)
+}
+
+// weeklyIndex gives the index of the instrument expiring on
+// the expiry at the offset among the sorted expiries
+func weeklyIndex(instruments models.Instruments, expiries []time.Time, expiryOffset int) (int, error) {
+	if expiryOffset < 0 || expiryOffset >= len(expiries) {
+		return -1, fmt.Errorf("expiry offset %d is out of the %d expiries listed", expiryOffset, len(expiries))
+	}
+	expiry := expiries[expiryOffset]
+	for i, instrument := range instruments {
+		if instrument.Expiry.Equal(expiry) {
+			return i, nil
+		}
 	}
 
-	return time.Time{}, nil
+	return -1, fmt.Errorf("the strike is not listed for the expiry %s", expiry.Format("2006-01-02"))
+}
+
+// isMonthlyExpiry tells whether the expiry is the
+// last one in its month among the sorted expiries
+func isMonthlyExpiry(expiries []time.Time, expiry time.Time) bool {
+	for _, other := range expiries {
+		if other.After(expiry) && other.Month() == expiry.Month() && other.Year() == expiry.Year() {
+			return false
+		}
+	}
+
+	return true
 }
 
 // GetLotSize will return lotsize of the symbol
 func GetLotSize(symbol string, broker broker.Broker) (int, error) {
-	var instrument models.Position
+	var instrument models.Instrument
 	var err error
 
 	err = retry.Do(
 		func() error {
-			instrument, err = broker.GetInstrument(symbol, "NFO")
+			instrument, err = broker.GetInstrument(symbol, models.ExchangeNFO)
 			if err != nil {
 				return err
 			}
@@ -233,3 +288,30 @@ func GetLTP(symbol string, broker broker.Broker) (float64, error) {
 
 	return ltp, nil
 }
+
+// GetCandles gives the historical candles of the
+// symbol between the times, retrying on errors
+func GetCandles(symbol string, interval models.Interval, from, to time.Time, broker broker.Broker) (models.Candles, error) {
+	var candles models.Candles
+	var err error
+
+	err = retry.Do(
+		func() error {
+			candles, err = broker.GetHistoricalCandles(symbol, interval, from, to)
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+		retry.OnRetry(func(_ uint, err error) {
+			log.Println(fmt.Sprintf("%s %s because %s", "Retrying getting candles for symbol", symbol, err))
+		}),
+		retry.Delay(5*time.Second),
+		retry.Attempts(5),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return candles, nil
+}
@@ -0,0 +1,103 @@
+package options
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+func newOption(tradingSymbol string, expiry time.Time, strike float64, optionType string) models.Instrument {
+	return models.Instrument{
+		TradingSymbol:  tradingSymbol,
+		Exchange:       models.ExchangeNFO,
+		Name:           "NIFTY",
+		InstrumentType: optionType,
+		StrikePrice:    strike,
+		Expiry:         expiry,
+		LotSize:        50,
+	}
+}
+
+func newMockBroker(t *testing.T, instruments ...models.Instrument) *broker.MockBroker {
+	mockBroker, err := broker.NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockBroker.Instruments = instruments
+
+	return &mockBroker
+}
+
+func TestGetSymbolOfStrikeListedOnlyForTheWeek(t *testing.T) {
+	firstWeek := time.Date(2024, time.January, 4, 0, 0, 0, 0, time.UTC)
+	monthly := time.Date(2024, time.January, 25, 0, 0, 0, 0, time.UTC)
+	mockBroker := newMockBroker(t,
+		newOption("NIFTY2410421500CE", firstWeek, 21500, "CE"),
+		newOption("NIFTY24JAN21500CE", monthly, 21500, "CE"),
+		newOption("NIFTY2410422000CE", firstWeek, 22000, "CE"),
+	)
+
+	tests := []struct {
+		strike float64
+		want   string
+	}{
+		{strike: 21500, want: "NIFTY2410421500CE"},
+		{strike: 22000, want: "NIFTY2410422000CE"},
+	}
+	for _, test := range tests {
+		got, err := GetSymbol("NIFTY", WEEK, 0, test.strike, "CE", mockBroker)
+		if err != nil {
+			t.Fatalf("strike %v: %s", test.strike, err)
+		}
+		if got != test.want {
+			t.Errorf("strike %v: got %s, want %s", test.strike, got, test.want)
+		}
+	}
+}
+
+func TestWeeklyExpiryOffset(t *testing.T) {
+	firstWeek := time.Date(2024, time.January, 4, 0, 0, 0, 0, time.UTC)
+	secondWeek := time.Date(2024, time.January, 11, 0, 0, 0, 0, time.UTC)
+	mockBroker := newMockBroker(t,
+		newOption("NIFTY2411121500CE", secondWeek, 21500, "CE"),
+		newOption("NIFTY2410421500CE", firstWeek, 21500, "CE"),
+		newOption("NIFTY2410422000CE", firstWeek, 22000, "CE"),
+	)
+
+	tests := []struct {
+		strike       float64
+		expiryOffset int
+		wantSymbol   string
+		wantExpiry   time.Time
+		wantErr      bool
+	}{
+		{strike: 21500, expiryOffset: 0, wantSymbol: "NIFTY2410421500CE", wantExpiry: firstWeek},
+		{strike: 21500, expiryOffset: 1, wantSymbol: "NIFTY2411121500CE", wantExpiry: secondWeek},
+		{strike: 21500, expiryOffset: 2, wantErr: true},
+		{strike: 22000, expiryOffset: 1, wantErr: true},
+	}
+	for _, test := range tests {
+		gotSymbol, err := GetSymbol("NIFTY", WEEK, test.expiryOffset, test.strike, "CE", mockBroker)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("strike %v offset %d: got symbol %s, want an error", test.strike, test.expiryOffset, gotSymbol)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("strike %v offset %d: %s", test.strike, test.expiryOffset, err)
+		}
+		if gotSymbol != test.wantSymbol {
+			t.Errorf("strike %v offset %d: got symbol %s, want %s", test.strike, test.expiryOffset, gotSymbol, test.wantSymbol)
+		}
+		gotExpiry, err := GetExpiry("NIFTY", WEEK, test.expiryOffset, test.strike, "CE", mockBroker)
+		if err != nil {
+			t.Fatalf("strike %v offset %d: %s", test.strike, test.expiryOffset, err)
+		}
+		if !gotExpiry.Equal(test.wantExpiry) {
+			t.Errorf("strike %v offset %d: got expiry %s, want %s", test.strike, test.expiryOffset, gotExpiry, test.wantExpiry)
+		}
+	}
+}
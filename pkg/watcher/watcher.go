@@ -1,90 +1,225 @@
 package watcher
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rohitsakala/strategies/pkg/broker"
 	"github.com/rohitsakala/strategies/pkg/models"
-	"github.com/rohitsakala/strategies/pkg/utils"
-	kiteconnect "github.com/zerodha/gokiteconnect/v4"
 )
 
+const (
+	// DefaultStuckOpenAfter sends the stuck open event on the first
+	// poll an order is seen open, like a triggered stop loss which did
+	// not fill, so that it is converted before the price runs away
+	DefaultStuckOpenAfter time.Duration = 0
+)
+
+type EventType string
+
+const (
+	// EventTriggered is sent when a stop loss order gets triggered
+	EventTriggered EventType = "triggered"
+	EventFilled    EventType = "filled"
+	EventRejected  EventType = "rejected"
+	EventCancelled EventType = "cancelled"
+	// EventStuckOpen is sent when an order stays open without
+	// getting filled for longer than StuckOpenAfter
+	EventStuckOpen EventType = "stuck-open"
+)
+
+// Event is an update of a watched order. Order already
+// carries the latest status and fills from the broker.
+type Event struct {
+	Type           EventType
+	Order          *models.Order
+	PreviousStatus models.Status
+}
+
+type Handler func(event Event) error
+
+// Handlers maps the events of an order to the functions handling them
+type Handlers map[EventType]Handler
+
+type watchedOrder struct {
+	order     *models.Order
+	handlers  Handlers
+	status    models.Status
+	openSince time.Time
+}
+
 type Watcher struct {
-	Broker   broker.Broker
-	TimeZone time.Location
+	Broker         broker.Broker
+	TimeZone       time.Location
+	StuckOpenAfter time.Duration
+	watched        map[*models.Order]*watchedOrder
+	mutex          *sync.Mutex
 }
 
 func NewWatcher(broker broker.Broker, timeZone time.Location) (Watcher, error) {
 	return Watcher{
-		Broker:   broker,
-		TimeZone: timeZone,
+		Broker:         broker,
+		TimeZone:       timeZone,
+		StuckOpenAfter: DefaultStuckOpenAfter,
+		watched:        map[*models.Order]*watchedOrder{},
+		mutex:          &sync.Mutex{},
 	}, nil
 }
 
-// Watch ensures that any order which is being
-// watched gets to completed status.
+// Add starts watching the orders and dispatches their events
+// to the handlers. Sliced orders are watched per child order.
+func (w *Watcher) Add(handlers Handlers, orders ...*models.Order) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, order := range broker.FlattenSlices(orders) {
+		w.watched[order] = &watchedOrder{
+			order:    order,
+			handlers: handlers,
+			status:   order.Status,
+		}
+	}
+}
+
+// Remove stops watching the orders
+func (w *Watcher) Remove(orders ...*models.Order) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	for _, order := range broker.FlattenSlices(orders) {
+		delete(w.watched, order)
+	}
+}
+
+// Poll fetches the orders once, updates every watched order
+// and dispatches the events concurrently to their handlers.
 // This method is meant to run in a loop.
-func (w *Watcher) Watch(position *models.Position) error {
-	var orderP models.Position
-	orders, err := w.Broker.GetOrders()
+func (w *Watcher) Poll() error {
+	events, handlers, err := w.collectEvents()
 	if err != nil {
 		return err
 	}
 
+	var wg sync.WaitGroup
+	errs := make([]error, len(events))
+	for i := range events {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = handlers[i](events[i])
+		}(i)
+	}
+	wg.Wait()
+
+	messages := []string{}
+	for i, err := range errs {
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("handling %s event of %s failed because %s", events[i].Type, events[i].Order.TradingSymbol, err))
+		}
+	}
+	if len(messages) > 0 {
+		return errors.New(strings.Join(messages, "; "))
+	}
+
+	return nil
+}
+
+// collectEvents works out the events of all watched orders
+// along with the handler of each event
+func (w *Watcher) collectEvents() ([]Event, []Handler, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	events := []Event{}
+	handlers := []Handler{}
+	if len(w.watched) <= 0 {
+		return events, handlers, nil
+	}
+	orders, err := w.Broker.GetOrders()
+	if err != nil {
+		return nil, nil, err
+	}
+	ordersByID := map[string]models.Order{}
 	for _, order := range orders {
-		if order.OrderID == position.OrderID {
-			orderP = order
+		ordersByID[order.OrderID] = order
+	}
+
+	for _, watched := range w.watched {
+		latest, ok := ordersByID[watched.order.OrderID]
+		if !ok {
+			continue
 		}
+		event, ok := w.nextEvent(watched, latest)
+		if !ok {
+			continue
+		}
+		handler, ok := watched.handlers[event.Type]
+		if !ok {
+			continue
+		}
+		events = append(events, event)
+		handlers = append(handlers, handler)
+	}
+
+	return events, handlers, nil
+}
+
+// nextEvent records the latest state of the order from the broker
+// on the watched order and works out the event of the change if any
+func (w *Watcher) nextEvent(watched *watchedOrder, latest models.Order) (Event, bool) {
+	now := time.Now().In(&w.TimeZone)
+	order := watched.order
+	previousStatus := watched.status
+
+	order.Status = latest.Status
+	order.FilledQuantity = latest.FilledQuantity
+	order.PendingQuantity = latest.PendingQuantity
+	if latest.FilledQuantity > 0 {
+		order.AveragePrice = latest.AveragePrice
 	}
+	watched.status = latest.Status
 
-	switch position.OrderType {
-	// TRIGGER PENDING - Send Email once if Status changes
-	// COMPLETE - Send Email once if Status changes
-	// OPEN - Convert to Limit Order
-	case kiteconnect.OrderTypeSL:
-		switch position.Status {
-		case "TRIGGER PENDING", kiteconnect.OrderStatusComplete:
-			if orderP.Status != position.Status {
-				message := fmt.Sprintf("Order %s Changed from %s to %s", position.TradingSymbol, position.Status, orderP.Status)
-				log.Println(message)
-				err = utils.SendEmail("12:30 pm Trade Update", message)
-				if err != nil {
-					return err
-				}
-				position.Status = orderP.Status
+	event := Event{
+		Order:          order,
+		PreviousStatus: previousStatus,
+	}
+
+	if latest.Status != previousStatus {
+		log.Printf("Order %s Changed from %s to %s", order.TradingSymbol, previousStatus, latest.Status)
+		switch latest.Status {
+		case models.StatusComplete:
+			event.Type = EventFilled
+			return event, true
+		case models.StatusRejected:
+			event.Type = EventRejected
+			return event, true
+		case models.StatusCancelled:
+			event.Type = EventCancelled
+			return event, true
+		case models.StatusOpen:
+			watched.openSince = now
+			if previousStatus == models.StatusTriggerPending {
+				event.Type = EventTriggered
+				return event, true
 			}
-		case "OPEN":
-			position.OrderType = kiteconnect.OrderTypeLimit
 		}
-	// COMPLETE - Send Email once if Status changes
-	// OPEN - Complete the order
-	case kiteconnect.OrderTypeLimit:
-		switch position.Status {
-		case kiteconnect.OrderStatusComplete:
-			if orderP.Status != position.Status {
-				message := fmt.Sprintf("Order %s Changed from %s to %s", position.TradingSymbol, position.Status, orderP.Status)
-				log.Println(message)
-				err = utils.SendEmail("12:30 pm Trade Update", message)
-				if err != nil {
-					return err
-				}
-				position.Status = orderP.Status
-			}
-		case "OPEN":
-			err = w.Broker.PlaceOrder(position)
-			if err != nil {
-				return err
-			}
-			message := fmt.Sprintf("Order %s Changed from OPEN to %s", position.TradingSymbol, position.Status)
-			log.Println(message)
-			err = utils.SendEmail("12:30 pm Trade Update", message)
-			if err != nil {
-				return err
-			}
+		return Event{}, false
+	}
+
+	if latest.Status == models.StatusOpen {
+		if watched.openSince.IsZero() {
+			watched.openSince = now
+		}
+		if now.Sub(watched.openSince) >= w.StuckOpenAfter {
+			watched.openSince = now
+			event.Type = EventStuckOpen
+			return event, true
 		}
 	}
 
-	return nil
+	return Event{}, false
 }
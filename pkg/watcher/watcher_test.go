@@ -0,0 +1,136 @@
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rohitsakala/strategies/pkg/broker"
+	"github.com/rohitsakala/strategies/pkg/models"
+)
+
+func newStopLoss() *models.Order {
+	return &models.Order{
+		Instrument: models.Instrument{
+			TradingSymbol: "NIFTY2410421500CE",
+			Exchange:      models.ExchangeNFO,
+			LotSize:       50,
+		},
+		Tag:             broker.NewOrderTag("test", "slce"),
+		Product:         models.ProductMIS,
+		OrderType:       models.OrderTypeSL,
+		TransactionType: models.TransactionTypeBuy,
+		Quantity:        50,
+		TriggerPrice:    130,
+		Price:           135,
+	}
+}
+
+// newTestWatcher places the stop loss on a mock broker scripted
+// with the steps and watches it, recording the events
+func newTestWatcher(t *testing.T, stopLoss *models.Order, steps ...broker.MockStep) (*broker.MockBroker, *Watcher, *[]EventType) {
+	mockBroker, err := broker.NewMockBroker()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockBroker.Script(stopLoss.TradingSymbol, steps...)
+	err = mockBroker.PlaceOrder(stopLoss)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := NewWatcher(&mockBroker, *time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []EventType{}
+	record := func(event Event) error {
+		events = append(events, event.Type)
+		return nil
+	}
+	watcher.Add(Handlers{
+		EventTriggered: record,
+		EventFilled:    record,
+		EventCancelled: record,
+		EventStuckOpen: func(event Event) error {
+			events = append(events, event.Type)
+			event.Order.OrderType = models.OrderTypeLimit
+			return mockBroker.PlaceOrder(event.Order)
+		},
+	}, stopLoss)
+
+	return &mockBroker, &watcher, &events
+}
+
+func TestWatcherConvertsTriggeredStopLossOnNextPoll(t *testing.T) {
+	stopLoss := newStopLoss()
+	mockBroker, watcher, events := newTestWatcher(t, stopLoss,
+		broker.MockStep{Status: models.StatusTriggerPending},
+		broker.MockStep{Status: models.StatusOpen},
+	)
+
+	for i := 0; i < 3; i++ {
+		err := watcher.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []EventType{EventTriggered, EventStuckOpen, EventFilled}
+	if len(*events) != len(want) {
+		t.Fatalf("got events %v, want %v", *events, want)
+	}
+	for i := range want {
+		if (*events)[i] != want[i] {
+			t.Errorf("got events %v, want %v", *events, want)
+			break
+		}
+	}
+	placed := mockBroker.PlacedOrders()
+	if len(placed) != 1 {
+		t.Fatalf("got %d placed orders, want the stop loss modified and not placed again", len(placed))
+	}
+	if placed[0].OrderType != models.OrderTypeLimit || placed[0].Status != models.StatusComplete {
+		t.Errorf("got %s order %s, want a COMPLETE LIMIT order", placed[0].OrderType, placed[0].Status)
+	}
+	if stopLoss.Status != models.StatusComplete || stopLoss.FilledQuantity != stopLoss.Quantity {
+		t.Errorf("got stop loss %s with %d filled, want COMPLETE with %d filled", stopLoss.Status, stopLoss.FilledQuantity, stopLoss.Quantity)
+	}
+}
+
+func TestWatcherWaitsForStuckOpenAfter(t *testing.T) {
+	stopLoss := newStopLoss()
+	_, watcher, events := newTestWatcher(t, stopLoss,
+		broker.MockStep{Status: models.StatusTriggerPending},
+		broker.MockStep{Status: models.StatusOpen},
+	)
+	watcher.StuckOpenAfter = time.Hour
+
+	for i := 0; i < 3; i++ {
+		err := watcher.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(*events) != 1 || (*events)[0] != EventTriggered {
+		t.Errorf("got events %v, want only %v before StuckOpenAfter", *events, EventTriggered)
+	}
+}
+
+func TestWatcherStopsWatchingRemovedOrders(t *testing.T) {
+	stopLoss := newStopLoss()
+	_, watcher, events := newTestWatcher(t, stopLoss,
+		broker.MockStep{Status: models.StatusTriggerPending},
+		broker.MockStep{Status: models.StatusComplete},
+	)
+	watcher.Remove(stopLoss)
+
+	err := watcher.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*events) != 0 {
+		t.Errorf("got events %v of a removed order, want none", *events)
+	}
+}